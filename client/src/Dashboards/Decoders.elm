@@ -86,6 +86,7 @@ configDetailDecoder =
         |> required "name" Decode.string
         |> required "abbreviation" Decode.string
         |> required "isRunning" Decode.bool
+        |> optional "isPaused" Decode.bool False
         |> optional "branches" (Decode.list branchDecoder) []
 
 
@@ -115,6 +116,9 @@ buildStatusDecoder =
         |> Decode.andThen
             (\statusInt ->
                 case statusInt of
+                    0 ->
+                        Decode.succeed Unknown
+
                     1 ->
                         Decode.succeed Success
 
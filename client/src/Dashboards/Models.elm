@@ -122,6 +122,7 @@ type alias ConfigDetail =
     , name : String
     , abbreviation : String
     , isRunning : Bool
+    , isPaused : Bool
     , branches : List Branch
     }
 
@@ -1,7 +1,7 @@
 module Dashboards.View exposing (..)
 
 import Dashboards.Lib exposing (findVisibleBranch, getDate)
-import Dashboards.Models exposing (Branch, Build, BuildStatus(Failure, Running, Success), ConfigDetail, DashboardDetails)
+import Dashboards.Models exposing (Branch, Build, BuildStatus(Failure, Running, Success, Unknown), ConfigDetail, DashboardDetails)
 import Date exposing (Date)
 import Date.Distance as DateDistance
 import Date.Extra.Config.Config_en_us exposing (config)
@@ -89,7 +89,9 @@ configItem model details cd =
 
         wrapperClass =
             "bi-wrapper "
-                ++ (if isLastBuildError branch.builds then
+                ++ (if cd.isPaused then
+                        "paused"
+                    else if isLastBuildError branch.builds then
                         "error"
                     else
                         "success"
@@ -212,7 +214,10 @@ buildItem build sIcon fIcon rIcon =
         Running ->
             runningItem rIcon
 
-        _ ->
+        Unknown ->
+            unknownItem fIcon
+
+        Failure ->
             failureItem fIcon
 
 
@@ -226,6 +231,11 @@ failureItem icon_ =
     div [ class "column is-1 bhLabel bh-fail" ] [ i [ class icon_ ] [] ]
 
 
+unknownItem : String -> Html Msg
+unknownItem icon_ =
+    div [ class "column is-1 bhLabel bh-unknown" ] [ i [ class icon_ ] [] ]
+
+
 runningItem : String -> Html Msg
 runningItem icon_ =
     div [ class "column is-1 bhLabel bh-succ" ] [ i [ class icon_ ] [] ]
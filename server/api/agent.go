@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+
+	"build-monitor-v2/server/db"
+
+	"github.com/labstack/echo"
+)
+
+// AgentPool is a pool of build agents, builds queued for a stuck pool wait until one of its agents is back
+type AgentPool struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	// Available counts the agents that are connected, authorized and enabled
+	Available int        `json:"available"`
+	Stuck     bool       `json:"stuck"`
+	Agents    []db.Agent `json:"agents"`
+}
+
+// Agents lists the build agents of every server grouped by pool
+func (s *Server) Agents(ctx echo.Context) error {
+	appDb := getAppDb(ctx)
+
+	agents, err := appDb.AgentList()
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, agentPools(agents))
+}
+
+func agentPools(agents []db.Agent) []AgentPool {
+	pools := []AgentPool{}
+	poolIndex := make(map[string]int)
+
+	for _, a := range agents {
+		index, ok := poolIndex[a.PoolId]
+		if !ok {
+			index = len(pools)
+			poolIndex[a.PoolId] = index
+			pools = append(pools, AgentPool{Id: a.PoolId, Name: a.PoolName, Source: a.Source, Agents: []db.Agent{}})
+		}
+
+		pools[index].Agents = append(pools[index].Agents, a)
+		if a.Connected && a.Authorized && a.Enabled {
+			pools[index].Available++
+		}
+	}
+
+	for i := range pools {
+		pools[i].Stuck = pools[i].Available == 0
+	}
+
+	return pools
+}
@@ -0,0 +1,79 @@
+package api_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"build-monitor-v2/server/api"
+	"build-monitor-v2/server/cfg"
+	"build-monitor-v2/server/db"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestServer_Agents(t *testing.T) {
+	Convey("Given a server", t, func() {
+		config := cfg.Config{JwtSecret: "this world"}
+		s := api.Server{Config: &config}
+
+		c, rec := createTestGetRequest("/api/agents")
+
+		mockDb := new(IAppDbMock)
+		c.Set(dbKey, mockDb)
+
+		Convey("When there are agents in a few pools", func() {
+			agents := []db.Agent{
+				{Id: "1", Name: "linux-01", PoolId: "0", PoolName: "Default", Connected: true, Authorized: true, Enabled: true, Build: &db.AgentBuild{Id: 512, BuildTypeId: "bt1"}},
+				{Id: "2", Name: "linux-02", PoolId: "0", PoolName: "Default", Connected: false, Authorized: true, Enabled: true},
+				{Id: "7", Name: "mac-01", PoolId: "3", PoolName: "macOS", Connected: false, Authorized: true, Enabled: true},
+				{Id: "8", Name: "mac-02", PoolId: "3", PoolName: "macOS", Connected: true, Authorized: true, Enabled: false},
+			}
+
+			mockDb.On("AgentList").Return(agents, nil)
+
+			resultErr := s.Agents(c)
+			So(resultErr, ShouldBeNil)
+
+			Convey("It should return http.StatusOK and the agents grouped by pool", func() {
+				mockDb.AssertExpectations(t)
+				So(rec.Code, ShouldEqual, http.StatusOK)
+
+				var result []api.AgentPool
+				err := json.Unmarshal(rec.Body.Bytes(), &result)
+				So(err, ShouldBeNil)
+
+				So(len(result), ShouldEqual, 2)
+				So(result[0].Name, ShouldEqual, "Default")
+				So(len(result[0].Agents), ShouldEqual, 2)
+				So(result[0].Agents[0].Build.Id, ShouldEqual, 512)
+				So(result[1].Name, ShouldEqual, "macOS")
+			})
+
+			Convey("It should mark the pools without an agent that can take a build as stuck", func() {
+				var result []api.AgentPool
+				json.Unmarshal(rec.Body.Bytes(), &result)
+
+				So(result[0].Available, ShouldEqual, 1)
+				So(result[0].Stuck, ShouldBeFalse)
+				So(result[1].Available, ShouldEqual, 0)
+				So(result[1].Stuck, ShouldBeTrue)
+			})
+		})
+
+		Convey("When the database errors", func() {
+			expectedError := errors.New("this is some bad mojo")
+			mockDb.On("AgentList").Return(nil, expectedError)
+
+			resultErr := s.Agents(c)
+			So(resultErr, ShouldBeNil)
+
+			Convey("It should return http.StatusInternalServerError and an error", func() {
+				So(rec.Code, ShouldEqual, http.StatusInternalServerError)
+				errorResult, _ := json.Marshal(api.ErrorResponse{Message: expectedError.Error()})
+				So(rec.Body.String(), ShouldEqual, string(errorResult))
+			})
+		})
+	})
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"build-monitor-v2/server/cfg"
+	"build-monitor-v2/server/ci"
 	"build-monitor-v2/server/db"
 
 	"fmt"
@@ -56,10 +57,19 @@ type IAppDb interface {
 	AddDashboardToBuildTypes(buildTypeIds []string, dashboardId string) error
 	RemoveDashboardFromBuildTypes(dashboardId string) error
 	DashboardBuildTypeList(dashboardId string) ([]db.BuildType, error)
+
+	AgentList() ([]db.Agent, error)
 }
 
 type ITcServer interface {
-	Refresh()
+	Refresh() error
+	Resync() error
+	RefreshDashboard(dashboardId string) (ci.SyncSummary, error)
+	RefreshBuildType(buildTypeId string) (ci.SyncSummary, error)
+	Status() []ci.MonitorStatus
+	Webhook(source string, payload []byte, signature string) error
+	BuildDetails(buildTypeId string, buildId int) (ci.BuildDetails, error)
+	BuildChain(buildTypeId string, buildId int) (ci.BuildChain, error)
 }
 
 type Server struct {
@@ -100,10 +110,49 @@ func (s *Server) Shutdown() {
 	s.Log.Info("Stopped")
 }
 
+// Refresh refreshes everything, or only syncs the builds of the dashboardId or buildTypeId it is given
 func (s *Server) Refresh(ctx echo.Context) error {
+	if id := ctx.QueryParam("dashboardId"); id != "" {
+		s.Log.Infof("Refreshing dashboard %s", id)
+		summary, err := s.TcServer.RefreshDashboard(id)
+		return refreshResponse(ctx, summary, err)
+	}
+
+	if id := ctx.QueryParam("buildTypeId"); id != "" {
+		s.Log.Infof("Refreshing build type %s", id)
+		summary, err := s.TcServer.RefreshBuildType(id)
+		return refreshResponse(ctx, summary, err)
+	}
+
 	s.Log.Info("Refreshing")
 
-	s.TcServer.Refresh()
+	if err := s.TcServer.Refresh(); err != nil {
+		return refreshResponse(ctx, ci.SyncSummary{}, err)
+	}
+
+	return nil
+}
+
+// Resync reads the whole build history again, a normal refresh only reads the builds that are new
+func (s *Server) Resync(ctx echo.Context) error {
+	s.Log.Info("Resyncing")
+
+	if err := s.TcServer.Resync(); err != nil {
+		return refreshResponse(ctx, ci.SyncSummary{}, err)
+	}
 
 	return nil
 }
+
+func refreshResponse(ctx echo.Context, summary ci.SyncSummary, err error) error {
+	switch err {
+	case nil:
+		return ctx.JSON(http.StatusOK, summary)
+	case ci.ErrDashboardNotFound, ci.ErrBuildTypeNotFound:
+		return ctx.JSON(http.StatusNotFound, ErrorResponse{Message: err.Error()})
+	case ci.ErrNotRunning, ci.ErrCircuitOpen:
+		return ctx.JSON(http.StatusServiceUnavailable, ErrorResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusBadGateway, ErrorResponse{Message: err.Error()})
+}
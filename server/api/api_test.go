@@ -5,9 +5,12 @@ import (
 
 	"build-monitor-v2/server/api"
 	"build-monitor-v2/server/cfg"
+	"build-monitor-v2/server/ci"
 	"build-monitor-v2/server/tc"
 
+	"encoding/json"
 	"errors"
+	"net/http"
 
 	"github.com/sirupsen/logrus"
 	. "github.com/smartystreets/goconvey/convey"
@@ -21,10 +24,10 @@ func TestCreate(t *testing.T) {
 		conf := cfg.Config{}
 		log := logrus.WithField("test", "TestCreate")
 		session := mgo.Session{}
-		tcServer := tc.Server{}
+		monitors := tc.Monitors{&tc.Server{}}
 
 		Convey("It should return a new s object", func() {
-			server := api.Create(log, &conf, &session, &tcServer)
+			server := api.Create(log, &conf, &session, monitors)
 
 			So(server, ShouldNotBeNil)
 			So(server.Config, ShouldEqual, &conf)
@@ -100,3 +103,71 @@ func TestServer_Shutdown(t *testing.T) {
 		})
 	})
 }
+
+func TestServer_Resync(t *testing.T) {
+	Convey("Given a server", t, func() {
+		tcServer := new(ITcServerMock)
+		s := api.Server{Log: logrus.WithField("test", "TestServer_Resync"), TcServer: tcServer}
+
+		c, _ := createTestPostRequest("/api/resync", nil)
+
+		Convey("It should ask the monitors for a full resync", func() {
+			tcServer.On("Resync").Return(nil)
+
+			So(s.Resync(c), ShouldBeNil)
+			tcServer.AssertExpectations(t)
+		})
+	})
+}
+
+func TestServer_Refresh(t *testing.T) {
+	Convey("Given a server", t, func() {
+		tcServer := new(ITcServerMock)
+		s := api.Server{Log: logrus.WithField("test", "TestServer_Refresh"), TcServer: tcServer}
+
+		Convey("When no dashboard or build type is given", func() {
+			c, rec := createTestPostRequest("/api/refresh", nil)
+			tcServer.On("Refresh").Return(nil)
+
+			Convey("It should refresh everything", func() {
+				So(s.Refresh(c), ShouldBeNil)
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				tcServer.AssertExpectations(t)
+			})
+		})
+
+		Convey("When a dashboard is given", func() {
+			c, rec := createTestPostRequest("/api/refresh?dashboardId=d1", nil)
+			tcServer.On("RefreshDashboard", "d1").Return(ci.SyncSummary{BuildTypes: 2, Synced: 2, Errors: []ci.SyncError{}}, nil)
+
+			Convey("It should only sync the dashboard and return the summary", func() {
+				So(s.Refresh(c), ShouldBeNil)
+				So(rec.Code, ShouldEqual, http.StatusOK)
+				tcServer.AssertExpectations(t)
+
+				var summary ci.SyncSummary
+				So(json.Unmarshal(rec.Body.Bytes(), &summary), ShouldBeNil)
+				So(summary.BuildTypes, ShouldEqual, 2)
+				So(summary.Synced, ShouldEqual, 2)
+			})
+		})
+
+		Convey("When a build type is given", func() {
+			status := func(err error) int {
+				c, rec := createTestPostRequest("/api/refresh?buildTypeId=bt1", nil)
+				tcServer.On("RefreshBuildType", "bt1").Return(ci.SyncSummary{}, err).Once()
+
+				So(s.Refresh(c), ShouldBeNil)
+				return rec.Code
+			}
+
+			Convey("It should map the outcome of the sync to a status", func() {
+				So(status(nil), ShouldEqual, http.StatusOK)
+				So(status(ci.ErrBuildTypeNotFound), ShouldEqual, http.StatusNotFound)
+				So(status(ci.ErrCircuitOpen), ShouldEqual, http.StatusServiceUnavailable)
+				So(status(ci.ErrNotRunning), ShouldEqual, http.StatusServiceUnavailable)
+				So(status(errors.New("the provider failed")), ShouldEqual, http.StatusBadGateway)
+			})
+		})
+	})
+}
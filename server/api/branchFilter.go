@@ -0,0 +1,111 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"build-monitor-v2/server/db"
+)
+
+// branchMatcher is a compiled db.BranchFilter
+type branchMatcher struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+func newBranchMatcher(f db.BranchFilter) (*branchMatcher, error) {
+	include, err := compileBranchPatterns(f.Include)
+	if err != nil {
+		return nil, err
+	}
+
+	exclude, exErr := compileBranchPatterns(f.Exclude)
+	if exErr != nil {
+		return nil, exErr
+	}
+
+	return &branchMatcher{include: include, exclude: exclude}, nil
+}
+
+// Filter returns the branches the filter lets through, a nil matcher lets them all through
+func (m *branchMatcher) Filter(branches []db.Branch) []db.Branch {
+	if m == nil || (len(m.include) == 0 && len(m.exclude) == 0) {
+		return branches
+	}
+
+	filtered := []db.Branch{}
+	for _, b := range branches {
+		if m.shows(b.Name) {
+			filtered = append(filtered, b)
+		}
+	}
+
+	return filtered
+}
+
+func (m *branchMatcher) shows(name string) bool {
+	return (len(m.include) == 0 || matchesAny(m.include, name)) && !matchesAny(m.exclude, name)
+}
+
+func matchesAny(patterns []*regexp.Regexp, name string) bool {
+	for _, p := range patterns {
+		if p.MatchString(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func compileBranchPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := []*regexp.Regexp{}
+	for _, p := range patterns {
+		re, err := compileBranchPattern(p)
+		if err != nil {
+			return nil, err
+		}
+
+		compiled = append(compiled, re)
+	}
+
+	return compiled, nil
+}
+
+func compileBranchPattern(p string) (*regexp.Regexp, error) {
+	if len(p) > 1 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") {
+		re, err := regexp.Compile(p[1 : len(p)-1])
+		if err != nil {
+			return nil, fmt.Errorf("the branch pattern %s is not a valid regular expression: %v", p, err)
+		}
+
+		return re, nil
+	}
+
+	glob := regexp.QuoteMeta(p)
+	glob = strings.Replace(glob, `\*`, ".*", -1)
+	glob = strings.Replace(glob, `\?`, ".", -1)
+
+	return regexp.Compile("^" + glob + "$")
+}
+
+// validBranchFilters checks every pattern of a dashboard can be compiled before it is stored
+func validBranchFilters(dashboardFilter *db.BranchFilter, configs []db.BuildConfig) error {
+	if dashboardFilter != nil {
+		if _, err := newBranchMatcher(*dashboardFilter); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range configs {
+		if c.BranchFilter == nil {
+			continue
+		}
+
+		if _, err := newBranchMatcher(*c.BranchFilter); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
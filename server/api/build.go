@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"build-monitor-v2/server/ci"
+
+	"github.com/labstack/echo"
+)
+
+// BuildDetails lets a dashboard show why a build failed without going to the CI server
+func (s *Server) BuildDetails(ctx echo.Context) error {
+	buildId, err := strconv.Atoi(ctx.Param("buildId"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ErrorResponse{Message: "the build id must be a number"})
+	}
+
+	details, err := s.TcServer.BuildDetails(ctx.Param("id"), buildId)
+	switch err {
+	case nil:
+		return ctx.JSON(http.StatusOK, details)
+	case ci.ErrBuildNotFound:
+		return ctx.JSON(http.StatusNotFound, ErrorResponse{Message: err.Error()})
+	case ci.ErrNoBuildDetails:
+		return ctx.JSON(http.StatusNotImplemented, ErrorResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusBadGateway, ErrorResponse{Message: err.Error()})
+}
+
+// BuildChain shows the builds a build waited for and the ones waiting for it, with the steps that broke
+func (s *Server) BuildChain(ctx echo.Context) error {
+	buildId, err := strconv.Atoi(ctx.Param("buildId"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ErrorResponse{Message: "the build id must be a number"})
+	}
+
+	chain, err := s.TcServer.BuildChain(ctx.Param("id"), buildId)
+	switch err {
+	case nil:
+		return ctx.JSON(http.StatusOK, chain)
+	case ci.ErrBuildNotFound:
+		return ctx.JSON(http.StatusNotFound, ErrorResponse{Message: err.Error()})
+	case ci.ErrNoBuildChain:
+		return ctx.JSON(http.StatusNotImplemented, ErrorResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusBadGateway, ErrorResponse{Message: err.Error()})
+}
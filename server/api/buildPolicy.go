@@ -0,0 +1,135 @@
+package api
+
+import (
+	"fmt"
+
+	"build-monitor-v2/server/db"
+)
+
+// BranchDetail is a branch as a dashboard shows it. Status comes from the newest build that counts,
+// so a personal or canceled build on top does not change the color of the branch.
+type BranchDetail struct {
+	db.Branch
+	Status db.BuildStatus `json:"status"`
+}
+
+// buildPolicies are the server policies with the ones a build config sets on top
+func (s *Server) buildPolicies(c db.BuildConfig) db.BuildPolicies {
+	p := db.BuildPolicies{
+		Personal:      db.BuildPolicy(s.Config.PersonalBuilds),
+		Canceled:      db.BuildPolicy(s.Config.CanceledBuilds),
+		FailedToStart: db.BuildPolicy(s.Config.FailedToStartBuilds),
+	}
+
+	if c.BuildPolicies == nil {
+		return p
+	}
+
+	if c.BuildPolicies.Personal != "" {
+		p.Personal = c.BuildPolicies.Personal
+	}
+
+	if c.BuildPolicies.Canceled != "" {
+		p.Canceled = c.BuildPolicies.Canceled
+	}
+
+	if c.BuildPolicies.FailedToStart != "" {
+		p.FailedToStart = c.BuildPolicies.FailedToStart
+	}
+
+	return p
+}
+
+// policyFor counts normal builds and any kind without a policy
+func policyFor(p db.BuildPolicies, kind db.BuildKind) db.BuildPolicy {
+	var policy db.BuildPolicy
+	switch kind {
+	case db.KindPersonal:
+		policy = p.Personal
+	case db.KindCanceled:
+		policy = p.Canceled
+	case db.KindFailedToStart:
+		policy = p.FailedToStart
+	}
+
+	if policy == "" {
+		return db.PolicyCount
+	}
+
+	return policy
+}
+
+// ignoreBuilds drops the builds the policies ignore. A branch left with no builds is dropped too,
+// unless it has something queued.
+func ignoreBuilds(branches []db.Branch, p db.BuildPolicies) []db.Branch {
+	kept := []db.Branch{}
+	for _, b := range branches {
+		builds := []db.Build{}
+		for _, build := range b.Builds {
+			if policyFor(p, build.Kind) != db.PolicyIgnore {
+				builds = append(builds, build)
+			}
+		}
+
+		if len(builds) == len(b.Builds) {
+			kept = append(kept, b)
+			continue
+		}
+
+		if len(builds) == 0 && len(b.Queued) == 0 {
+			continue
+		}
+
+		b.Builds = builds
+		b.IsRunning = hasRunningBuild(builds)
+		kept = append(kept, b)
+	}
+
+	return kept
+}
+
+func branchDetails(branches []db.Branch, p db.BuildPolicies) []BranchDetail {
+	details := []BranchDetail{}
+	for _, b := range branches {
+		details = append(details, BranchDetail{Branch: b, Status: branchStatus(b.Builds, p)})
+	}
+
+	return details
+}
+
+func branchStatus(builds []db.Build, p db.BuildPolicies) db.BuildStatus {
+	for _, b := range builds {
+		if policyFor(p, b.Kind) == db.PolicyCount {
+			return b.Status
+		}
+	}
+
+	return db.StatusUnknown
+}
+
+func hasRunningBuild(builds []db.Build) bool {
+	for _, b := range builds {
+		if b.Status == db.StatusRunning {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validBuildPolicies checks the policies of a dashboard's build configs before they are stored
+func validBuildPolicies(configs []db.BuildConfig) error {
+	for _, c := range configs {
+		if c.BuildPolicies == nil {
+			continue
+		}
+
+		for _, policy := range []db.BuildPolicy{c.BuildPolicies.Personal, c.BuildPolicies.Canceled, c.BuildPolicies.FailedToStart} {
+			if !policy.Valid() {
+				return fmt.Errorf("the build policy %s is not one of ignore, show or count", policy)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package api_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"build-monitor-v2/server/api"
+	"build-monitor-v2/server/cfg"
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestServer_BuildDetails(t *testing.T) {
+	Convey("Given a server", t, func() {
+		config := cfg.Config{JwtSecret: "this world"}
+		tcServer := new(ITcServerMock)
+		s := api.Server{Config: &config, TcServer: tcServer}
+
+		requestCode := func(buildId string) int {
+			c, rec := createTestGetRequest("/api/buildTypes/cloud:bt1/builds/" + buildId)
+			c.SetParamNames("id", "buildId")
+			c.SetParamValues("cloud:bt1", buildId)
+
+			So(s.BuildDetails(c), ShouldBeNil)
+			return rec.Code
+		}
+
+		Convey("When the build failed", func() {
+			details := ci.BuildDetails{
+				Id:          511,
+				BuildTypeId: "cloud:bt1",
+				Status:      db.StatusFailure,
+				StatusText:  "Tests failed: 2 (1 new)",
+				Problems:    []ci.BuildProblem{{Type: "TC_FAILED_TESTS", Details: "2 tests failed"}},
+				FailedTests: []ci.FailedTest{{Name: "AuthTest.login", NewFailure: true, Details: "expected 200 but was 401"}},
+			}
+			tcServer.On("BuildDetails", "cloud:bt1", 511).Return(details, nil)
+
+			c, rec := createTestGetRequest("/api/buildTypes/cloud:bt1/builds/511")
+			c.SetParamNames("id", "buildId")
+			c.SetParamValues("cloud:bt1", "511")
+
+			resultErr := s.BuildDetails(c)
+			So(resultErr, ShouldBeNil)
+
+			Convey("It should return http.StatusOK and why it failed", func() {
+				tcServer.AssertExpectations(t)
+				So(rec.Code, ShouldEqual, http.StatusOK)
+
+				var result ci.BuildDetails
+				err := json.Unmarshal(rec.Body.Bytes(), &result)
+				So(err, ShouldBeNil)
+				So(result, ShouldResemble, details)
+			})
+		})
+
+		Convey("When the build id is not a number", func() {
+			code := requestCode("latest")
+
+			Convey("It should return http.StatusBadRequest", func() {
+				So(code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When the monitor can not explain the build", func() {
+			cases := map[error]int{
+				ci.ErrBuildNotFound:             http.StatusNotFound,
+				ci.ErrNoBuildDetails:            http.StatusNotImplemented,
+				errors.New("502 from TeamCity"): http.StatusBadGateway,
+			}
+
+			Convey("It should return the matching status", func() {
+				for err, status := range cases {
+					tcServer := new(ITcServerMock)
+					tcServer.On("BuildDetails", "cloud:bt1", 7).Return(ci.BuildDetails{}, err)
+					s.TcServer = tcServer
+
+					So(requestCode("7"), ShouldEqual, status)
+				}
+			})
+		})
+	})
+}
+
+func TestServer_BuildChain(t *testing.T) {
+	Convey("Given a server", t, func() {
+		config := cfg.Config{JwtSecret: "this world"}
+		tcServer := new(ITcServerMock)
+		s := api.Server{Config: &config, TcServer: tcServer}
+
+		requestCode := func(buildId string) int {
+			c, rec := createTestGetRequest("/api/buildTypes/cloud:deploy/builds/" + buildId + "/chain")
+			c.SetParamNames("id", "buildId")
+			c.SetParamValues("cloud:deploy", buildId)
+
+			So(s.BuildChain(c), ShouldBeNil)
+			return rec.Code
+		}
+
+		Convey("When the build is part of a chain", func() {
+			chain := ci.BuildChain{
+				Build: ci.ChainBuild{Id: 520, BuildTypeId: "cloud:deploy", Status: db.StatusUnknown, DependsOn: []int{519}},
+				Upstream: []ci.ChainBuild{
+					{Id: 518, BuildTypeId: "cloud:build", Status: db.StatusSuccess, DependsOn: []int{}},
+					{Id: 519, BuildTypeId: "cloud:test", Status: db.StatusFailure, DependsOn: []int{518}},
+				},
+				Downstream:     []ci.ChainBuild{},
+				BrokenBuildIds: []int{519},
+			}
+			tcServer.On("BuildChain", "cloud:deploy", 520).Return(chain, nil)
+
+			c, rec := createTestGetRequest("/api/buildTypes/cloud:deploy/builds/520/chain")
+			c.SetParamNames("id", "buildId")
+			c.SetParamValues("cloud:deploy", "520")
+
+			So(s.BuildChain(c), ShouldBeNil)
+
+			Convey("It should return http.StatusOK and the chain", func() {
+				tcServer.AssertExpectations(t)
+				So(rec.Code, ShouldEqual, http.StatusOK)
+
+				var result ci.BuildChain
+				err := json.Unmarshal(rec.Body.Bytes(), &result)
+				So(err, ShouldBeNil)
+				So(result, ShouldResemble, chain)
+			})
+		})
+
+		Convey("When the build id is not a number", func() {
+			code := requestCode("latest")
+
+			Convey("It should return http.StatusBadRequest", func() {
+				So(code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When the monitor can not read the chain", func() {
+			cases := map[error]int{
+				ci.ErrBuildNotFound:             http.StatusNotFound,
+				ci.ErrNoBuildChain:              http.StatusNotImplemented,
+				errors.New("502 from TeamCity"): http.StatusBadGateway,
+			}
+
+			Convey("It should return the matching status", func() {
+				for err, status := range cases {
+					tcServer := new(ITcServerMock)
+					tcServer.On("BuildChain", "cloud:deploy", 7).Return(ci.BuildChain{}, err)
+					s.TcServer = tcServer
+
+					So(requestCode("7"), ShouldEqual, status)
+				}
+			})
+		})
+	})
+}
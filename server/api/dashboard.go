@@ -2,10 +2,12 @@ package api
 
 import (
 	"net/http"
+	"time"
 
 	"build-monitor-v2/server/db"
 
 	"github.com/labstack/echo"
+	"github.com/sirupsen/logrus"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -34,11 +36,18 @@ type DashboardDetails struct {
 }
 
 type BuildTypeDetail struct {
-	Id           string      `json:"id"`
-	Name         string      `json:"name"`
-	Abbreviation string      `json:"abbreviation"`
-	IsRunning    bool        `bson:"isRunning" json:"isRunning"`
-	Branches     []db.Branch `json:"branches"`
+	Id           string         `json:"id"`
+	Name         string         `json:"name"`
+	Abbreviation string         `json:"abbreviation"`
+	IsRunning    bool           `bson:"isRunning" json:"isRunning"`
+	IsQueued     bool           `json:"isQueued"`
+	IsPaused     bool           `json:"isPaused"`
+	Branches     []BranchDetail `json:"branches"`
+	// Dependencies are the build types this one has a snapshot dependency on, they make up its build chain
+	Dependencies []string `json:"dependencies"`
+	// Investigation says who is fixing the build type, the wall can stop nagging while it is set
+	Investigation *db.Investigation `json:"investigation"`
+	MutedTests    []db.MutedTest    `json:"mutedTests"`
 }
 
 func (s *Server) DashboardDetails(ctx echo.Context) error {
@@ -71,14 +80,41 @@ func (s *Server) DashboardDetails(ctx echo.Context) error {
 		RightDateFormat:  dashboard.RightDateFormat,
 	}
 
+	// The patterns were checked when the dashboard was saved, one that still fails to compile shows every branch
+	dashboardBranches, _ := newBranchMatcher(dashboard.BranchFilter)
+
+	depth := dashboard.HistoryDepth
+	if depth == 0 {
+		depth = s.Config.HistoryDepth
+	}
+
+	now := time.Now()
 	for _, c := range dashboard.BuildConfigs {
 		buildType := findBuildType(c.Id, buildTypes)
+		if buildType != nil && buildType.Paused && dashboard.HidePaused {
+			continue
+		}
+
 		detail := BuildTypeDetail{Id: c.Id, Abbreviation: c.Abbreviation}
 
 		if buildType != nil {
+			branches := dashboardBranches
+			if c.BranchFilter != nil {
+				branches, _ = newBranchMatcher(*c.BranchFilter)
+			}
+
+			policies := s.buildPolicies(c)
+			estimated := estimateRunning(buildType.Branches, now)
+			shown := limitBuilds(ignoreBuilds(branches.Filter(estimated), policies), depth)
+
 			detail.Name = buildType.Name
-			detail.Branches = buildType.Branches
-			detail.IsRunning = buildType.IsRunning
+			detail.Branches = branchDetails(shown, policies)
+			detail.IsRunning = isRunning(shown)
+			detail.IsQueued = isQueued(shown)
+			detail.IsPaused = buildType.Paused
+			detail.Dependencies = buildType.Dependencies
+			detail.Investigation = buildType.Investigation
+			detail.MutedTests = buildType.MutedTests
 		}
 
 		details.Details = append(details.Details, detail)
@@ -97,6 +133,12 @@ type UpdateDashboardRequest struct {
 	CenterDateFormat string           `json:"centerDateFormat"`
 	RightDateFormat  string           `json:"rightDateFormat"`
 	BuildConfigs     []db.BuildConfig `json:"buildConfigs"`
+	// HidePaused keeps the stored value when a save leaves it out
+	HidePaused *bool `json:"hidePaused,omitempty"`
+	// BranchFilter keeps the stored filter when a save leaves it out, so do the filters of the build configs
+	BranchFilter *db.BranchFilter `json:"branchFilter,omitempty"`
+	// HistoryDepth keeps the stored depth when a save leaves it out, 0 goes back to the server default
+	HistoryDepth *int `json:"historyDepth,omitempty"`
 }
 
 func (s *Server) CreateDashboard(ctx echo.Context) error {
@@ -105,6 +147,14 @@ func (s *Server) CreateDashboard(ctx echo.Context) error {
 		return ctx.JSON(http.StatusInternalServerError, nil)
 	}
 
+	if err := validBranchFilters(r.BranchFilter, r.BuildConfigs); err != nil {
+		return ctx.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+	}
+
+	if err := validBuildPolicies(r.BuildConfigs); err != nil {
+		return ctx.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+	}
+
 	log := getLogger(ctx)
 	claims := getClaims(ctx)
 	appDb := getAppDb(ctx)
@@ -123,6 +173,18 @@ func (s *Server) CreateDashboard(ctx echo.Context) error {
 		BuildConfigs:     r.BuildConfigs,
 	}
 
+	if r.HidePaused != nil {
+		dashboard.HidePaused = *r.HidePaused
+	}
+
+	if r.BranchFilter != nil {
+		dashboard.BranchFilter = *r.BranchFilter
+	}
+
+	if r.HistoryDepth != nil {
+		dashboard.HistoryDepth = *r.HistoryDepth
+	}
+
 	dbDashboard, err := appDb.UpsertDashboard(dashboard)
 	if err != nil {
 		log.Error("Failed to insert dashboard into database", err)
@@ -130,8 +192,7 @@ func (s *Server) CreateDashboard(ctx echo.Context) error {
 	}
 
 	addDashboardToBuildTypes(appDb, dbDashboard)
-
-	s.TcServer.Refresh()
+	refreshDashboard(log, s.TcServer, dbDashboard.Id)
 
 	return ctx.JSON(http.StatusCreated, dbDashboard)
 }
@@ -167,6 +228,14 @@ func (s *Server) UpdateDashboard(ctx echo.Context) error {
 		return ctx.JSON(http.StatusInternalServerError, nil)
 	}
 
+	if err := validBranchFilters(r.BranchFilter, r.BuildConfigs); err != nil {
+		return ctx.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+	}
+
+	if err := validBuildPolicies(r.BuildConfigs); err != nil {
+		return ctx.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+	}
+
 	log := getLogger(ctx)
 	claims := getClaims(ctx)
 	appDb := getAppDb(ctx)
@@ -194,7 +263,22 @@ func (s *Server) UpdateDashboard(ctx echo.Context) error {
 		CenterDateFormat: r.CenterDateFormat,
 		RightDateFormat:  r.RightDateFormat,
 		Owner:            db.Owner{Id: bson.ObjectIdHex(claims.UserId), Username: claims.Username},
-		BuildConfigs:     getValidBuildConfigs(appDb, r.BuildConfigs),
+		BuildConfigs:     keepBuildConfigSettings(dbCheck.BuildConfigs, getValidBuildConfigs(appDb, r.BuildConfigs)),
+		HidePaused:       dbCheck.HidePaused,
+		BranchFilter:     dbCheck.BranchFilter,
+		HistoryDepth:     dbCheck.HistoryDepth,
+	}
+
+	if r.HidePaused != nil {
+		dashboard.HidePaused = *r.HidePaused
+	}
+
+	if r.BranchFilter != nil {
+		dashboard.BranchFilter = *r.BranchFilter
+	}
+
+	if r.HistoryDepth != nil {
+		dashboard.HistoryDepth = *r.HistoryDepth
 	}
 
 	dbDashboard, err := appDb.UpsertDashboard(dashboard)
@@ -204,12 +288,25 @@ func (s *Server) UpdateDashboard(ctx echo.Context) error {
 	}
 
 	addDashboardToBuildTypes(appDb, dbDashboard)
-
-	s.TcServer.Refresh()
+	refreshDashboard(log, s.TcServer, dbDashboard.Id)
 
 	return ctx.JSON(http.StatusOK, dbDashboard)
 }
 
+// refreshDashboard syncs the build types of the saved dashboard in the background, the save is answered without
+// waiting on the monitors and stands even when the sync fails
+func refreshDashboard(log *logrus.Entry, tcServer ITcServer, dashboardId string) {
+	go func() {
+		summary, err := tcServer.RefreshDashboard(dashboardId)
+		if err != nil {
+			log.Errorf("Failed to sync the builds of dashboard %s, Error: %v", dashboardId, err)
+			return
+		}
+
+		log.Infof("Synced %d of %d build types of dashboard %s", summary.Synced, summary.BuildTypes, dashboardId)
+	}()
+}
+
 func getValidBuildConfigs(appDb IAppDb, configs []db.BuildConfig) []db.BuildConfig {
 	projects, _ := appDb.ProjectList()
 
@@ -223,6 +320,27 @@ func getValidBuildConfigs(appDb IAppDb, configs []db.BuildConfig) []db.BuildConf
 	return validConfigs
 }
 
+// keepBuildConfigSettings gives the saved build configs the settings they had before when the save leaves them out
+func keepBuildConfigSettings(stored []db.BuildConfig, configs []db.BuildConfig) []db.BuildConfig {
+	for i, c := range configs {
+		for _, s := range stored {
+			if s.Id != c.Id {
+				continue
+			}
+
+			if c.BranchFilter == nil {
+				configs[i].BranchFilter = s.BranchFilter
+			}
+
+			if c.BuildPolicies == nil {
+				configs[i].BuildPolicies = s.BuildPolicies
+			}
+		}
+	}
+
+	return configs
+}
+
 func projectInList(projects []db.Project, id string) bool {
 	for _, p := range projects {
 		if p.Id == id {
@@ -250,3 +368,41 @@ func findBuildType(id string, buildTypes []db.BuildType) *db.BuildType {
 
 	return nil
 }
+
+// limitBuilds keeps the newest depth builds of each branch, the monitor stores more for the dashboards that ask for them
+func limitBuilds(branches []db.Branch, depth int) []db.Branch {
+	if depth <= 0 {
+		return branches
+	}
+
+	limited := []db.Branch{}
+	for _, b := range branches {
+		if len(b.Builds) > depth {
+			b.Builds = b.Builds[:depth]
+		}
+
+		limited = append(limited, b)
+	}
+
+	return limited
+}
+
+func isRunning(branches []db.Branch) bool {
+	for _, b := range branches {
+		if b.IsRunning {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isQueued(branches []db.Branch) bool {
+	for _, b := range branches {
+		if len(b.Queued) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
@@ -7,14 +7,16 @@ import (
 
 	"build-monitor-v2/server/cfg"
 
+	"build-monitor-v2/server/ci"
+
 	"build-monitor-v2/server/db"
 
 	"encoding/json"
 	"net/http"
 
 	"errors"
+	"time"
 
-	"github.com/pstuart2/go-teamcity"
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/stretchr/testify/mock"
 	"gopkg.in/mgo.v2/bson"
@@ -219,36 +221,41 @@ func TestServer_DashboardDetails(t *testing.T) {
 						{
 							Name: "branch-1",
 							Builds: []db.Build{
-								{Id: 3, Number: "tcb4", Status: teamcity.StatusSuccess, StatusText: "show it?", Progress: 4},
-								{Id: 1, Number: "tcb1", Status: teamcity.StatusFailure, StatusText: "always!", Progress: 6},
+								{Id: 3, Number: "tcb4", Status: db.StatusSuccess, StatusText: "show it?", Progress: 4, Tests: &db.TestCounts{Total: 20, Passed: 20}},
+								{Id: 1, Number: "tcb1", Status: db.StatusFailure, StatusText: "always!", Progress: 6},
 							},
 						},
 						{
 							Name: "branch-2",
 							Builds: []db.Build{
-								{Id: 5, Number: "tcb5", Status: teamcity.StatusSuccess, StatusText: "show it?", Progress: 0},
-								{Id: 6, Number: "tcb6", Status: teamcity.StatusFailure, StatusText: "always!", Progress: 8},
+								{Id: 5, Number: "tcb5", Status: db.StatusSuccess, StatusText: "show it?", Progress: 0},
+								{Id: 6, Number: "tcb6", Status: db.StatusFailure, StatusText: "always!", Progress: 8, Tests: &db.TestCounts{Total: 20, Passed: 17, Failed: 3, NewFailed: 3}},
 							},
 						},
 					},
 				}
 
 				bt2 := db.BuildType{
-					Id:   "bcfg2",
-					Name: "Build Type 2",
+					Id:            "bcfg2",
+					Name:          "Build Type 2",
+					Investigation: &db.Investigation{Assignee: "Alice", State: "taken", Comment: "Looking into the flaky login test"},
+					MutedTests:    []db.MutedTest{{Name: "ClockTest.timezone", MutedBy: "Bob"}},
 					Branches: []db.Branch{
 						{
 							Name: "branch-6",
 							Builds: []db.Build{
-								{Id: 3, Number: "tg1", Status: teamcity.StatusSuccess, StatusText: "show it?", Progress: 4},
-								{Id: 1, Number: "tg2", Status: teamcity.StatusFailure, StatusText: "always!", Progress: 6},
+								{Id: 3, Number: "tg1", Status: db.StatusSuccess, StatusText: "show it?", Progress: 4},
+								{Id: 1, Number: "tg2", Status: db.StatusFailure, StatusText: "always!", Progress: 6},
 							},
 						},
 						{
 							Name: "branch-7",
 							Builds: []db.Build{
-								{Id: 5, Number: "tg3", Status: teamcity.StatusSuccess, StatusText: "show it?", Progress: 0},
-								{Id: 6, Number: "tg4", Status: teamcity.StatusFailure, StatusText: "always!", Progress: 8},
+								{Id: 5, Number: "tg3", Status: db.StatusSuccess, StatusText: "show it?", Progress: 0},
+								{Id: 6, Number: "tg4", Status: db.StatusFailure, StatusText: "always!", Progress: 8},
+							},
+							Queued: []db.QueuedBuild{
+								{Id: 9, Position: 1, WaitReason: "Waiting for a compatible agent"},
 							},
 						},
 					},
@@ -266,13 +273,22 @@ func TestServer_DashboardDetails(t *testing.T) {
 							Id:           "bcfg1",
 							Name:         "Build Type 1",
 							Abbreviation: "BC-1",
-							Branches:     bt1.Branches,
+							Branches: []api.BranchDetail{
+								{Branch: bt1.Branches[0], Status: db.StatusSuccess},
+								{Branch: bt1.Branches[1], Status: db.StatusSuccess},
+							},
 						},
 						{
 							Id:           "bcfg2",
 							Name:         "Build Type 2",
 							Abbreviation: "BC-2",
-							Branches:     bt2.Branches,
+							IsQueued:     true,
+							Branches: []api.BranchDetail{
+								{Branch: bt2.Branches[0], Status: db.StatusSuccess},
+								{Branch: bt2.Branches[1], Status: db.StatusSuccess},
+							},
+							Investigation: bt2.Investigation,
+							MutedTests:    bt2.MutedTests,
 						},
 						{Id: "bcfg3", Abbreviation: "BC-3"},
 					},
@@ -297,6 +313,207 @@ func TestServer_DashboardDetails(t *testing.T) {
 					})
 				})
 			})
+
+			Convey("And one of the build types is paused", func() {
+				buildTypes := []db.BuildType{
+					{Id: "bcfg1", Name: "Build Type 1", Paused: true},
+					{Id: "bcfg2", Name: "Build Type 2"},
+				}
+				mockDb.On("DashboardBuildTypeList", dashboard.Id).Return(buildTypes, nil)
+
+				Convey("It should mark it so the dashboard can gray it out", func() {
+					So(s.DashboardDetails(c), ShouldBeNil)
+
+					var result api.DashboardDetails
+					json.Unmarshal(rec.Body.Bytes(), &result)
+
+					So(len(result.Details), ShouldEqual, 3)
+					So(result.Details[0].IsPaused, ShouldBeTrue)
+					So(result.Details[1].IsPaused, ShouldBeFalse)
+				})
+
+				Convey("It should leave it off a dashboard that hides paused build types", func() {
+					dashboard.HidePaused = true
+					So(s.DashboardDetails(c), ShouldBeNil)
+
+					var result api.DashboardDetails
+					json.Unmarshal(rec.Body.Bytes(), &result)
+
+					So(len(result.Details), ShouldEqual, 2)
+					So(result.Details[0].Id, ShouldEqual, "bcfg2")
+				})
+			})
+
+			Convey("And the dashboard filters the branches", func() {
+				branches := []db.Branch{
+					{Name: "main"},
+					{Name: "release/1.2", IsRunning: true},
+					{Name: "release/1.2-rc"},
+					{Name: "pull/42"},
+					{Name: "feature/auth", IsRunning: true},
+				}
+				buildTypes := []db.BuildType{
+					{Id: "bcfg1", Name: "Build Type 1", Branches: branches, IsRunning: true},
+					{Id: "bcfg2", Name: "Build Type 2", Branches: branches, IsRunning: true},
+				}
+				mockDb.On("DashboardBuildTypeList", dashboard.Id).Return(buildTypes, nil)
+
+				dashboard.BranchFilter = db.BranchFilter{Include: []string{"main", "release/*", `/^pull\/\d+$/`}, Exclude: []string{"*-rc"}}
+				dashboard.BuildConfigs[1].BranchFilter = &db.BranchFilter{Include: []string{"feature/*"}}
+
+				So(s.DashboardDetails(c), ShouldBeNil)
+
+				var result api.DashboardDetails
+				json.Unmarshal(rec.Body.Bytes(), &result)
+
+				names := func(branches []api.BranchDetail) []string {
+					list := []string{}
+					for _, b := range branches {
+						list = append(list, b.Name)
+					}
+					return list
+				}
+
+				Convey("It should only show the branches the dashboard filter lets through", func() {
+					So(names(result.Details[0].Branches), ShouldResemble, []string{"main", "release/1.2", "pull/42"})
+					So(result.Details[0].IsRunning, ShouldBeTrue)
+				})
+
+				Convey("It should use the filter of a build config over the dashboard one", func() {
+					So(names(result.Details[1].Branches), ShouldResemble, []string{"feature/auth"})
+				})
+			})
+
+			Convey("And the dashboard shows fewer builds than are stored", func() {
+				builds := []db.Build{{Id: 4}, {Id: 3}, {Id: 2}, {Id: 1}}
+				buildTypes := []db.BuildType{{Id: "bcfg1", Branches: []db.Branch{{Name: "main", Builds: builds}}}}
+				mockDb.On("DashboardBuildTypeList", dashboard.Id).Return(buildTypes, nil)
+
+				Convey("It should only return the newest of them", func() {
+					dashboard.HistoryDepth = 2
+					So(s.DashboardDetails(c), ShouldBeNil)
+
+					var result api.DashboardDetails
+					json.Unmarshal(rec.Body.Bytes(), &result)
+
+					So(result.Details[0].Branches[0].Builds, ShouldResemble, []db.Build{{Id: 4}, {Id: 3}})
+					So(len(buildTypes[0].Branches[0].Builds), ShouldEqual, 4)
+				})
+
+				Convey("It should use the server depth when the dashboard has none", func() {
+					config.HistoryDepth = 3
+					So(s.DashboardDetails(c), ShouldBeNil)
+
+					var result api.DashboardDetails
+					json.Unmarshal(rec.Body.Bytes(), &result)
+
+					So(len(result.Details[0].Branches[0].Builds), ShouldEqual, 3)
+				})
+			})
+
+			Convey("And some builds are running", func() {
+				now := time.Now()
+				finished := func(id int, minutes int) db.Build {
+					start := now.Add(-time.Duration(id) * time.Hour)
+					return db.Build{Id: id, Status: db.StatusSuccess, StartDate: start, FinishDate: start.Add(time.Duration(minutes) * time.Minute)}
+				}
+
+				providerEstimate := now.Add(time.Minute)
+				buildTypes := []db.BuildType{{Id: "bcfg1", Branches: []db.Branch{
+					{Name: "main", IsRunning: true, Builds: []db.Build{
+						{Id: 20, Status: db.StatusRunning, StartDate: now.Add(-5 * time.Minute)},
+						finished(3, 10),
+						{Id: 2, Status: db.StatusFailure, StartDate: now.Add(-2 * time.Hour), FinishDate: now.Add(-time.Hour)},
+						finished(1, 20),
+					}},
+					{Name: "feature/auth", IsRunning: true, Builds: []db.Build{
+						{Id: 21, Status: db.StatusRunning, StartDate: now.Add(-time.Minute)},
+					}},
+					{Name: "release", IsRunning: true, Builds: []db.Build{
+						{Id: 22, Status: db.StatusRunning, StartDate: now.Add(-30 * time.Minute), EstimatedFinishDate: providerEstimate},
+						finished(4, 5),
+					}},
+					{Name: "hotfix", IsRunning: true, Builds: []db.Build{
+						{Id: 23, Status: db.StatusRunning, StartDate: now.Add(-time.Hour)},
+						finished(5, 10),
+					}},
+				}}}
+				mockDb.On("DashboardBuildTypeList", dashboard.Id).Return(buildTypes, nil)
+
+				So(s.DashboardDetails(c), ShouldBeNil)
+
+				var result api.DashboardDetails
+				json.Unmarshal(rec.Body.Bytes(), &result)
+				branches := result.Details[0].Branches
+
+				Convey("It should estimate them from the recent successful builds of the branch", func() {
+					main := branches[0].Builds[0]
+					So(main.EstimatedFinishDate.Unix(), ShouldEqual, now.Add(-5*time.Minute).Add(15*time.Minute).Unix())
+					So(main.Overtime, ShouldBeFalse)
+					So(branches[0].Builds[1].EstimatedFinishDate.IsZero(), ShouldBeTrue)
+				})
+
+				Convey("It should fall back on the whole build type for a new branch", func() {
+					auth := branches[1].Builds[0]
+					So(auth.EstimatedFinishDate.Unix(), ShouldEqual, now.Add(-time.Minute).Add(45*time.Minute/4).Unix())
+				})
+
+				Convey("It should keep the provider estimate", func() {
+					release := branches[2].Builds[0]
+					So(release.EstimatedFinishDate.Unix(), ShouldEqual, providerEstimate.Unix())
+					So(release.Overtime, ShouldBeFalse)
+				})
+
+				Convey("It should mark a build past its estimate as overtime", func() {
+					So(branches[3].Builds[0].Overtime, ShouldBeTrue)
+					So(buildTypes[0].Branches[3].Builds[0].Overtime, ShouldBeFalse)
+				})
+			})
+
+			Convey("And the branches have personal and canceled builds", func() {
+				buildTypes := []db.BuildType{
+					{Id: "bcfg1", Branches: []db.Branch{
+						{Name: "main", IsRunning: true, Builds: []db.Build{
+							{Id: 5, Status: db.StatusRunning, Kind: db.KindPersonal},
+							{Id: 4, Status: db.StatusUnknown, Kind: db.KindCanceled},
+							{Id: 3, Status: db.StatusFailure, Kind: db.KindFailedToStart},
+							{Id: 2, Status: db.StatusSuccess},
+						}},
+						{Name: "remote-run", Builds: []db.Build{{Id: 1, Status: db.StatusFailure, Kind: db.KindPersonal}}},
+					}},
+					{Id: "bcfg2", Branches: []db.Branch{
+						{Name: "main", Builds: []db.Build{{Id: 7, Status: db.StatusFailure, Kind: db.KindPersonal}, {Id: 6, Status: db.StatusSuccess}}},
+					}},
+				}
+				mockDb.On("DashboardBuildTypeList", dashboard.Id).Return(buildTypes, nil)
+
+				config.PersonalBuilds = "ignore"
+				config.CanceledBuilds = "show"
+				config.FailedToStartBuilds = "count"
+				dashboard.BuildConfigs[1].BuildPolicies = &db.BuildPolicies{Personal: db.PolicyShow}
+
+				So(s.DashboardDetails(c), ShouldBeNil)
+
+				var result api.DashboardDetails
+				json.Unmarshal(rec.Body.Bytes(), &result)
+
+				Convey("It should leave off the ignored builds and the branches that only had those", func() {
+					So(len(result.Details[0].Branches), ShouldEqual, 1)
+					So(len(result.Details[0].Branches[0].Builds), ShouldEqual, 3)
+					So(result.Details[0].Branches[0].Builds[0].Id, ShouldEqual, 4)
+					So(result.Details[0].Branches[0].IsRunning, ShouldBeFalse)
+					So(result.Details[0].IsRunning, ShouldBeFalse)
+				})
+
+				Convey("It should take the branch status from the newest build that counts", func() {
+					So(result.Details[0].Branches[0].Status, ShouldEqual, db.StatusFailure)
+				})
+
+				Convey("It should use the policies of a build config over the server ones", func() {
+					So(len(result.Details[1].Branches[0].Builds), ShouldEqual, 2)
+					So(result.Details[1].Branches[0].Status, ShouldEqual, db.StatusSuccess)
+				})
+			})
 		})
 	})
 }
@@ -322,13 +539,56 @@ func TestServer_CreateDashboard(t *testing.T) {
 			})
 		})
 
+		Convey("With a branch pattern that is not a valid regular expression", func() {
+			request := api.UpdateDashboardRequest{
+				Name: "This is me new dashboard",
+				BuildConfigs: []db.BuildConfig{
+					{Id: "db1", BranchFilter: &db.BranchFilter{Include: []string{"/release/(/"}}},
+				},
+			}
+
+			requestJson, _ := json.Marshal(request)
+			c, rec := createTestPostRequest("/api/dashboards", requestJson)
+
+			err := s.CreateDashboard(c)
+
+			Convey("It should return http.StatusBadRequest without storing it", func() {
+				So(err, ShouldBeNil)
+				So(rec.Code, ShouldEqual, http.StatusBadRequest)
+				So(rec.Body.String(), ShouldContainSubstring, "/release/(/")
+			})
+		})
+
+		Convey("With a build policy that does not exist", func() {
+			request := api.UpdateDashboardRequest{
+				Name: "This is me new dashboard",
+				BuildConfigs: []db.BuildConfig{
+					{Id: "db1", BuildPolicies: &db.BuildPolicies{Personal: "hide"}},
+				},
+			}
+
+			requestJson, _ := json.Marshal(request)
+			c, rec := createTestPostRequest("/api/dashboards", requestJson)
+
+			err := s.CreateDashboard(c)
+
+			Convey("It should return http.StatusBadRequest without storing it", func() {
+				So(err, ShouldBeNil)
+				So(rec.Code, ShouldEqual, http.StatusBadRequest)
+				So(rec.Body.String(), ShouldContainSubstring, "hide")
+			})
+		})
+
 		Convey("With a valid request", func() {
+			hidePaused := true
 			request := api.UpdateDashboardRequest{
 				Name:        "This is me new dashboard",
 				ColumnCount: 8,
 				BuildConfigs: []db.BuildConfig{
 					{Id: "db1"}, {Id: "db2"},
 				},
+				HidePaused:   &hidePaused,
+				BranchFilter: &db.BranchFilter{Include: []string{"main"}},
 			}
 
 			requestJson, _ := json.Marshal(request)
@@ -358,9 +618,17 @@ func TestServer_CreateDashboard(t *testing.T) {
 			Convey("When the create succeeds", func() {
 				mockDb.On("UpsertDashboard", mock.AnythingOfType("db.Dashboard")).Return(&dbDashboard, nil)
 				mockDb.On("AddDashboardToBuildTypes", []string{"db1", "db2"}, dbDashboard.Id).Return(nil)
-				tcServer.On("Refresh").Return()
+				// The sync holds on until the dashboard is created, a save does not wait on the monitors
+				created := make(chan bool)
+				synced := make(chan bool)
+				tcServer.On("RefreshDashboard", dbDashboard.Id).Return(ci.SyncSummary{}, nil).Run(func(mock.Arguments) {
+					<-created
+					close(synced)
+				})
 
 				resultErr := s.CreateDashboard(c)
+				close(created)
+				<-synced
 
 				Convey("It should upsert the dashboard with the owner and a new id", func() {
 
@@ -377,6 +645,8 @@ func TestServer_CreateDashboard(t *testing.T) {
 					So(dashboardToDb.ColumnCount, ShouldEqual, 8)
 					So(dashboardToDb.BuildConfigs[0].Id, ShouldEqual, request.BuildConfigs[0].Id)
 					So(dashboardToDb.BuildConfigs[1].Id, ShouldEqual, request.BuildConfigs[1].Id)
+					So(dashboardToDb.HidePaused, ShouldBeTrue)
+					So(dashboardToDb.BranchFilter.Include, ShouldResemble, []string{"main"})
 
 					Convey("And return http.StatusCreated", func() {
 
@@ -617,9 +887,13 @@ func TestServer_UpdateDashboard(t *testing.T) {
 					mockDb.On("UpsertDashboard", mock.AnythingOfType("db.Dashboard")).Return(&dbDashboard, nil)
 					mockDb.On("AddDashboardToBuildTypes", []string{"db1", "db2"}, dbDashboard.Id).Return(nil)
 					mockDb.On("ProjectList").Return(projects, nil)
-					tcServer.On("Refresh").Return()
+					synced := make(chan bool)
+					tcServer.On("RefreshDashboard", dbDashboard.Id).Return(ci.SyncSummary{}, nil).Run(func(mock.Arguments) {
+						close(synced)
+					})
 
 					resultErr := s.UpdateDashboard(c)
+					<-synced
 
 					Convey("It should upsert the dashboard with the owner and a new id", func() {
 
@@ -691,6 +965,43 @@ func TestServer_UpdateDashboard(t *testing.T) {
 					})
 				})
 
+				Convey("And the save leaves out the settings the client does not edit", func() {
+					dbDashboard.HidePaused = true
+					dbDashboard.HistoryDepth = 30
+					dbDashboard.BranchFilter = db.BranchFilter{Include: []string{"main"}}
+					dbDashboard.BuildConfigs = []db.BuildConfig{
+						{Id: "db1", BranchFilter: &db.BranchFilter{Exclude: []string{"*-rc"}}},
+						{Id: "db2", BuildPolicies: &db.BuildPolicies{Personal: db.PolicyShow}},
+					}
+
+					mockDb.On("FindDashboardById", id).Return(&dbDashboard, nil)
+					mockDb.On("RemoveDashboardFromBuildTypes", id).Return(nil)
+					mockDb.On("UpsertDashboard", mock.AnythingOfType("db.Dashboard")).Return(&dbDashboard, nil)
+					mockDb.On("AddDashboardToBuildTypes", []string{"db1", "db2"}, dbDashboard.Id).Return(nil)
+					mockDb.On("ProjectList").Return(projects, nil)
+					synced := make(chan bool)
+					tcServer.On("RefreshDashboard", dbDashboard.Id).Return(ci.SyncSummary{}, nil).Run(func(mock.Arguments) {
+						close(synced)
+					})
+
+					resultErr := s.UpdateDashboard(c)
+					<-synced
+
+					Convey("It should keep the stored ones", func() {
+						So(resultErr, ShouldBeNil)
+
+						dashboardToDb := mockDb.Calls[3].Arguments[0].(db.Dashboard)
+
+						So(dashboardToDb.HidePaused, ShouldBeTrue)
+						So(dashboardToDb.HistoryDepth, ShouldEqual, 30)
+						So(dashboardToDb.BranchFilter.Include, ShouldResemble, []string{"main"})
+						So(dashboardToDb.BuildConfigs[0].BranchFilter.Exclude, ShouldResemble, []string{"*-rc"})
+						So(dashboardToDb.BuildConfigs[1].BranchFilter, ShouldBeNil)
+						So(dashboardToDb.BuildConfigs[0].BuildPolicies, ShouldBeNil)
+						So(dashboardToDb.BuildConfigs[1].BuildPolicies.Personal, ShouldEqual, db.PolicyShow)
+					})
+				})
+
 				Convey("And the RemoveDashboardFromBuildTypes fails", func() {
 					expectedErr := errors.New("what now")
 					mockDb.On("FindDashboardById", id).Return(&dbDashboard, nil)
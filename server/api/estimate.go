@@ -0,0 +1,78 @@
+package api
+
+import (
+	"time"
+
+	"build-monitor-v2/server/db"
+)
+
+// estimateSampleSize is how many recent successful builds the estimate of a running build is averaged over
+const estimateSampleSize = 5
+
+// estimateRunning fills in the finish date of the running builds the provider did not estimate, from the
+// recent successful builds on their branch or on the whole build type when the branch has none yet.
+// A running build past its estimate is marked overtime. The stored branches are left as they are.
+func estimateRunning(branches []db.Branch, now time.Time) []db.Branch {
+	buildTypeDuration, buildTypeOk := averageDuration(branches)
+
+	estimated := []db.Branch{}
+	for _, b := range branches {
+		if !hasRunningBuild(b.Builds) {
+			estimated = append(estimated, b)
+			continue
+		}
+
+		duration, ok := averageDuration([]db.Branch{b})
+		if !ok {
+			duration, ok = buildTypeDuration, buildTypeOk
+		}
+
+		builds := []db.Build{}
+		for _, build := range b.Builds {
+			if build.Status == db.StatusRunning {
+				if build.EstimatedFinishDate.IsZero() && ok && !build.StartDate.IsZero() {
+					build.EstimatedFinishDate = build.StartDate.Add(duration)
+				}
+
+				build.Overtime = !build.EstimatedFinishDate.IsZero() && now.After(build.EstimatedFinishDate)
+			}
+
+			builds = append(builds, build)
+		}
+
+		b.Builds = builds
+		estimated = append(estimated, b)
+	}
+
+	return estimated
+}
+
+// averageDuration averages the newest successful normal builds of the branches, ok is false when there are none
+func averageDuration(branches []db.Branch) (time.Duration, bool) {
+	var total time.Duration
+	count := 0
+
+	for _, b := range branches {
+		sampled := 0
+		for _, build := range b.Builds {
+			if sampled == estimateSampleSize {
+				break
+			}
+
+			if build.Status != db.StatusSuccess || build.Kind != db.KindNormal || build.StartDate.IsZero() || build.FinishDate.Before(build.StartDate) {
+				continue
+			}
+
+			total += build.FinishDate.Sub(build.StartDate)
+			sampled++
+		}
+
+		count += sampled
+	}
+
+	if count == 0 {
+		return 0, false
+	}
+
+	return total / time.Duration(count), true
+}
@@ -41,10 +41,11 @@ func TestMain(m *testing.M) {
 	db.Ensure(session, testLogger)
 
 	// tcDb := db.Create(session.Copy(), config, testLogger, time.Now)
-	// tcMonitor := tc.NewServer(testLogger, config, tcDb)
+	// tcServers, _ := config.TeamCityServers()
+	// tcMonitor := tc.NewTeamCityServer(testLogger, tcServers[0], tcDb)
 	tcServer := tc.Server{}
 
-	s = Create(testLogger, config, session, &tcServer)
+	s = Create(testLogger, config, session, tc.Monitors{&tcServer})
 	s.Setup()
 
 	os.Exit(m.Run())
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"build-monitor-v2/server/api"
+	"build-monitor-v2/server/ci"
 	"build-monitor-v2/server/db"
 
 	"net/http"
@@ -215,6 +216,16 @@ func (m *IAppDbMock) ProjectList() ([]db.Project, error) {
 	return args.Get(0).([]db.Project), args.Error(1)
 }
 
+func (m *IAppDbMock) AgentList() ([]db.Agent, error) {
+	args := m.Called()
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]db.Agent), args.Error(1)
+}
+
 func (m *IAppDbMock) BuildTypeList() ([]db.BuildType, error) {
 	args := m.Called()
 
@@ -286,6 +297,50 @@ type ITcServerMock struct {
 	mock.Mock
 }
 
-func (m *ITcServerMock) Refresh() {
-	m.Called()
+func (m *ITcServerMock) Refresh() error {
+	args := m.Called()
+
+	return args.Error(0)
+}
+
+func (m *ITcServerMock) Resync() error {
+	args := m.Called()
+
+	return args.Error(0)
+}
+
+func (m *ITcServerMock) RefreshDashboard(dashboardId string) (ci.SyncSummary, error) {
+	args := m.Called(dashboardId)
+
+	return args.Get(0).(ci.SyncSummary), args.Error(1)
+}
+
+func (m *ITcServerMock) RefreshBuildType(buildTypeId string) (ci.SyncSummary, error) {
+	args := m.Called(buildTypeId)
+
+	return args.Get(0).(ci.SyncSummary), args.Error(1)
+}
+
+func (m *ITcServerMock) Status() []ci.MonitorStatus {
+	args := m.Called()
+
+	return args.Get(0).([]ci.MonitorStatus)
+}
+
+func (m *ITcServerMock) Webhook(source string, payload []byte, signature string) error {
+	args := m.Called(source, payload, signature)
+
+	return args.Error(0)
+}
+
+func (m *ITcServerMock) BuildDetails(buildTypeId string, buildId int) (ci.BuildDetails, error) {
+	args := m.Called(buildTypeId, buildId)
+
+	return args.Get(0).(ci.BuildDetails), args.Error(1)
+}
+
+func (m *ITcServerMock) BuildChain(buildTypeId string, buildId int) (ci.BuildChain, error) {
+	args := m.Called(buildTypeId, buildId)
+
+	return args.Get(0).(ci.BuildChain), args.Error(1)
 }
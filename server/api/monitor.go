@@ -0,0 +1,12 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+)
+
+// Monitors reports how the last build history sync of every monitor went and the state of its circuit breaker
+func (s *Server) Monitors(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, s.TcServer.Status())
+}
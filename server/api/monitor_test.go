@@ -0,0 +1,58 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"build-monitor-v2/server/api"
+	"build-monitor-v2/server/cfg"
+	"build-monitor-v2/server/ci"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestServer_Monitors(t *testing.T) {
+	Convey("Given a server", t, func() {
+		config := cfg.Config{JwtSecret: "this world"}
+		tcServer := new(ITcServerMock)
+		s := api.Server{Config: &config, TcServer: tcServer}
+
+		c, rec := createTestGetRequest("/api/monitors")
+
+		Convey("When the monitors have synced", func() {
+			started := time.Date(2017, 10, 4, 18, 0, 0, 0, time.UTC)
+			tcServer.On("Status").Return([]ci.MonitorStatus{
+				{Source: "", LastSync: &ci.SyncSummary{
+					StartedAt:  started,
+					FinishedAt: started.Add(time.Second * 12),
+					BuildTypes: 3,
+					Synced:     2,
+					Errors:     []ci.SyncError{{BuildTypeId: "bt3", Message: "502 Bad Gateway"}},
+				}},
+				{Source: "jenkins", Breaker: &ci.BreakerStatus{State: ci.BreakerOpen, Failures: 3, LastError: "connection refused"}},
+			})
+
+			resultErr := s.Monitors(c)
+			So(resultErr, ShouldBeNil)
+
+			Convey("It should return http.StatusOK and each monitor's last sync and circuit breaker", func() {
+				tcServer.AssertExpectations(t)
+				So(rec.Code, ShouldEqual, http.StatusOK)
+
+				var result []ci.MonitorStatus
+				err := json.Unmarshal(rec.Body.Bytes(), &result)
+				So(err, ShouldBeNil)
+
+				So(len(result), ShouldEqual, 2)
+				So(result[0].LastSync.Synced, ShouldEqual, 2)
+				So(result[0].LastSync.Errors[0].BuildTypeId, ShouldEqual, "bt3")
+				So(result[1].Source, ShouldEqual, "jenkins")
+				So(result[1].LastSync, ShouldBeNil)
+				So(result[1].Breaker.State, ShouldEqual, "open")
+				So(result[1].Breaker.Failures, ShouldEqual, 3)
+			})
+		})
+	})
+}
@@ -2,6 +2,9 @@ package api
 
 import (
 	"net/http"
+	"strings"
+
+	"build-monitor-v2/server/db"
 
 	"github.com/labstack/echo"
 )
@@ -16,3 +19,141 @@ func (s *Server) Projects(ctx echo.Context) error {
 
 	return ctx.JSON(http.StatusOK, projects)
 }
+
+// ProjectNode is a project with its build types and subprojects
+type ProjectNode struct {
+	Id          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Source      string          `json:"source"`
+	BuildTypes  []BuildTypeNode `json:"buildTypes"`
+	Projects    []ProjectNode   `json:"projects"`
+}
+
+type BuildTypeNode struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Paused bool   `json:"paused"`
+}
+
+// ProjectTree nests the projects under their parents. The root query param only returns the subtree of that project
+// and search keeps the projects and build types whose name contains it, along with the projects above them.
+func (s *Server) ProjectTree(ctx echo.Context) error {
+	appDb := getAppDb(ctx)
+
+	projects, err := appDb.ProjectList()
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+	}
+
+	buildTypes, btErr := appDb.BuildTypeList()
+	if btErr != nil {
+		return ctx.JSON(http.StatusInternalServerError, ErrorResponse{Message: btErr.Error()})
+	}
+
+	tree := projectTree(projects, buildTypes)
+
+	if root := ctx.QueryParam("root"); root != "" {
+		node := findProjectNode(root, tree)
+		if node == nil {
+			return ctx.JSON(http.StatusNotFound, ErrorResponse{Message: "project not found"})
+		}
+
+		tree = []ProjectNode{*node}
+	}
+
+	if search := strings.ToLower(strings.TrimSpace(ctx.QueryParam("search"))); search != "" {
+		tree = searchProjectNodes(tree, search)
+	}
+
+	return ctx.JSON(http.StatusOK, tree)
+}
+
+// projectTree keeps the name order of the lists, projects whose parent is not listed, like _Root, are at the top
+func projectTree(projects []db.Project, buildTypes []db.BuildType) []ProjectNode {
+	known := make(map[string]bool)
+	for _, p := range projects {
+		known[p.Id] = true
+	}
+
+	children := make(map[string][]db.Project)
+	roots := []db.Project{}
+	for _, p := range projects {
+		if known[p.ParentProjectID] && p.ParentProjectID != p.Id {
+			children[p.ParentProjectID] = append(children[p.ParentProjectID], p)
+		} else {
+			roots = append(roots, p)
+		}
+	}
+
+	projectBuildTypes := make(map[string][]BuildTypeNode)
+	for _, bt := range buildTypes {
+		projectBuildTypes[bt.ProjectID] = append(projectBuildTypes[bt.ProjectID], BuildTypeNode{Id: bt.Id, Name: bt.Name, Paused: bt.Paused})
+	}
+
+	var nodes func(list []db.Project) []ProjectNode
+	nodes = func(list []db.Project) []ProjectNode {
+		result := []ProjectNode{}
+		for _, p := range list {
+			node := ProjectNode{
+				Id:          p.Id,
+				Name:        p.Name,
+				Description: p.Description,
+				Source:      p.Source,
+				BuildTypes:  projectBuildTypes[p.Id],
+				Projects:    nodes(children[p.Id]),
+			}
+
+			if node.BuildTypes == nil {
+				node.BuildTypes = []BuildTypeNode{}
+			}
+
+			result = append(result, node)
+		}
+
+		return result
+	}
+
+	return nodes(roots)
+}
+
+func findProjectNode(id string, nodes []ProjectNode) *ProjectNode {
+	for i := range nodes {
+		if nodes[i].Id == id {
+			return &nodes[i]
+		}
+
+		if found := findProjectNode(id, nodes[i].Projects); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// searchProjectNodes keeps the whole subtree of a matching project and only the matching build types of the others
+func searchProjectNodes(nodes []ProjectNode, search string) []ProjectNode {
+	result := []ProjectNode{}
+	for _, node := range nodes {
+		if strings.Contains(strings.ToLower(node.Name), search) {
+			result = append(result, node)
+			continue
+		}
+
+		buildTypes := []BuildTypeNode{}
+		for _, bt := range node.BuildTypes {
+			if strings.Contains(strings.ToLower(bt.Name), search) {
+				buildTypes = append(buildTypes, bt)
+			}
+		}
+
+		node.BuildTypes = buildTypes
+		node.Projects = searchProjectNodes(node.Projects, search)
+
+		if len(node.BuildTypes) > 0 || len(node.Projects) > 0 {
+			result = append(result, node)
+		}
+	}
+
+	return result
+}
@@ -80,3 +80,118 @@ func TestServer_Projects(t *testing.T) {
 		})
 	})
 }
+
+func TestServer_ProjectTree(t *testing.T) {
+	Convey("Given a server with nested projects", t, func() {
+		config := cfg.Config{JwtSecret: "this world"}
+		s := api.Server{Config: &config}
+
+		mockDb := new(IAppDbMock)
+
+		projects := []db.Project{
+			{Id: "Apps", Name: "Apps", ParentProjectID: "_Root"},
+			{Id: "Apps_Web", Name: "Web", ParentProjectID: "Apps"},
+			{Id: "Tools", Name: "Tools", ParentProjectID: "_Root"},
+			{Id: "Apps_Web_Admin", Name: "Admin", ParentProjectID: "Apps_Web"},
+		}
+		buildTypes := []db.BuildType{
+			{Id: "Apps_Web_Admin_Build", Name: "Build", ProjectID: "Apps_Web_Admin"},
+			{Id: "Apps_Web_Deploy", Name: "Deploy", ProjectID: "Apps_Web", Paused: true},
+			{Id: "Tools_Lint", Name: "Lint", ProjectID: "Tools"},
+		}
+
+		get := func(path string) ([]api.ProjectNode, int) {
+			c, rec := createTestGetRequest(path)
+			c.Set(dbKey, mockDb)
+
+			So(s.ProjectTree(c), ShouldBeNil)
+
+			var result []api.ProjectNode
+			json.Unmarshal(rec.Body.Bytes(), &result)
+
+			return result, rec.Code
+		}
+
+		Convey("When the whole tree is asked for", func() {
+			mockDb.On("ProjectList").Return(projects, nil)
+			mockDb.On("BuildTypeList").Return(buildTypes, nil)
+
+			tree, code := get("/api/projects/tree")
+
+			Convey("It should nest the subprojects and build types under their parents", func() {
+				So(code, ShouldEqual, http.StatusOK)
+				So(len(tree), ShouldEqual, 2)
+				So(tree[0].Id, ShouldEqual, "Apps")
+				So(tree[1].Id, ShouldEqual, "Tools")
+				So(tree[1].BuildTypes, ShouldResemble, []api.BuildTypeNode{{Id: "Tools_Lint", Name: "Lint"}})
+
+				web := tree[0].Projects[0]
+				So(web.Id, ShouldEqual, "Apps_Web")
+				So(web.BuildTypes, ShouldResemble, []api.BuildTypeNode{{Id: "Apps_Web_Deploy", Name: "Deploy", Paused: true}})
+				So(web.Projects[0].Id, ShouldEqual, "Apps_Web_Admin")
+				So(web.Projects[0].BuildTypes[0].Id, ShouldEqual, "Apps_Web_Admin_Build")
+			})
+		})
+
+		Convey("When a subtree is asked for", func() {
+			mockDb.On("ProjectList").Return(projects, nil)
+			mockDb.On("BuildTypeList").Return(buildTypes, nil)
+
+			tree, code := get("/api/projects/tree?root=Apps_Web")
+
+			Convey("It should only return that project and what is under it", func() {
+				So(code, ShouldEqual, http.StatusOK)
+				So(len(tree), ShouldEqual, 1)
+				So(tree[0].Id, ShouldEqual, "Apps_Web")
+				So(tree[0].Projects[0].Id, ShouldEqual, "Apps_Web_Admin")
+			})
+		})
+
+		Convey("When the subtree does not exist", func() {
+			mockDb.On("ProjectList").Return(projects, nil)
+			mockDb.On("BuildTypeList").Return(buildTypes, nil)
+
+			_, code := get("/api/projects/tree?root=Nope")
+
+			Convey("It should return http.StatusNotFound", func() {
+				So(code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("When the tree is searched", func() {
+			mockDb.On("ProjectList").Return(projects, nil)
+			mockDb.On("BuildTypeList").Return(buildTypes, nil)
+
+			byBuildType, _ := get("/api/projects/tree?search=deploy")
+			byProject, _ := get("/api/projects/tree?search=ADMIN")
+
+			Convey("It should keep the matching build types and the projects above them", func() {
+				So(len(byBuildType), ShouldEqual, 1)
+				So(byBuildType[0].Id, ShouldEqual, "Apps")
+
+				web := byBuildType[0].Projects[0]
+				So(web.BuildTypes, ShouldResemble, []api.BuildTypeNode{{Id: "Apps_Web_Deploy", Name: "Deploy", Paused: true}})
+				So(len(web.Projects), ShouldEqual, 0)
+			})
+
+			Convey("It should keep everything under a matching project", func() {
+				admin := byProject[0].Projects[0].Projects[0]
+				So(admin.Id, ShouldEqual, "Apps_Web_Admin")
+				So(len(admin.BuildTypes), ShouldEqual, 1)
+				So(len(byProject[0].Projects[0].BuildTypes), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When the database errors", func() {
+			expectedError := errors.New("this is some bad mojo")
+			mockDb.On("ProjectList").Return(projects, nil)
+			mockDb.On("BuildTypeList").Return(nil, expectedError)
+
+			_, code := get("/api/projects/tree")
+
+			Convey("It should return http.StatusInternalServerError", func() {
+				So(code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+}
@@ -32,9 +32,16 @@ func setupRoutes(s *Server) {
 	openApi.POST("/signup", s.SignUp)
 	openApi.POST("/login", s.Login)
 	openApi.GET("/projects", s.Projects)
+	openApi.GET("/projects/tree", s.ProjectTree)
 	openApi.GET("/buildTypes", s.BuildTypes)
+	openApi.GET("/buildTypes/:id/builds/:buildId", s.BuildDetails)
+	openApi.GET("/buildTypes/:id/builds/:buildId/chain", s.BuildChain)
 	openApi.GET("/dashboards", s.Dashboards)
 	openApi.GET("/dashboards/:id", s.DashboardDetails)
+	openApi.GET("/monitors", s.Monitors)
+	openApi.GET("/agents", s.Agents)
+	openApi.POST("/webhooks/teamcity", s.TeamCityWebhook)
+	openApi.POST("/webhooks/teamcity/:server", s.TeamCityWebhook)
 
 	requireClaims := middleware.JWTWithConfig(middleware.JWTConfig{
 		SigningMethod: jwt.SigningMethodHS256.Name,
@@ -49,6 +56,7 @@ func setupRoutes(s *Server) {
 	secureApi.PUT("/dashboards/:id", s.UpdateDashboard)
 	secureApi.DELETE("/dashboards/:id", s.DeleteDashboard)
 	secureApi.POST("/refresh", s.Refresh)
+	secureApi.POST("/resync", s.Resync)
 }
 
 func logRoutes(s *Server) {
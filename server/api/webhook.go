@@ -0,0 +1,42 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"build-monitor-v2/server/ci"
+
+	"github.com/labstack/echo"
+)
+
+// SignatureHeader carries the "sha256=<hex hmac>" of the payload made with the webhook secret. Senders that
+// can not sign the payload use "Authorization: Bearer <webhook secret>" instead.
+const SignatureHeader = "X-Build-Monitor-Signature"
+
+// TeamCityWebhook takes tcWebHooks build events, the server param is the TcServers name and is
+// left off for the default server
+func (s *Server) TeamCityWebhook(ctx echo.Context) error {
+	payload, err := ioutil.ReadAll(ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+	}
+
+	signature := ctx.Request().Header.Get(SignatureHeader)
+	if signature == "" {
+		signature = ctx.Request().Header.Get(echo.HeaderAuthorization)
+	}
+
+	err = s.TcServer.Webhook(ctx.Param("server"), payload, signature)
+	switch err {
+	case nil:
+		return ctx.NoContent(http.StatusAccepted)
+	case ci.ErrWebhooksDisabled:
+		return ctx.JSON(http.StatusNotFound, ErrorResponse{Message: err.Error()})
+	case ci.ErrBadSignature:
+		return ctx.JSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+	case ci.ErrNotRunning, ci.ErrWebhooksBusy:
+		return ctx.JSON(http.StatusServiceUnavailable, ErrorResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+}
@@ -0,0 +1,75 @@
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"build-monitor-v2/server/api"
+	"build-monitor-v2/server/cfg"
+	"build-monitor-v2/server/ci"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestServer_TeamCityWebhook(t *testing.T) {
+	Convey("Given a server", t, func() {
+		config := cfg.Config{JwtSecret: "this world"}
+		tcServer := new(ITcServerMock)
+		s := api.Server{Config: &config, TcServer: tcServer}
+
+		payload := []byte(`{"build": {"notifyType": "buildStarted"}}`)
+
+		c, rec := createTestPostRequest("/api/webhooks/teamcity/cloud", payload)
+		c.SetParamNames("server")
+		c.SetParamValues("cloud")
+		c.Request().Header.Set(api.SignatureHeader, "sha256=abc")
+
+		Convey("When the monitor takes the event", func() {
+			tcServer.On("Webhook", "cloud", payload, "sha256=abc").Return(nil)
+
+			resultErr := s.TeamCityWebhook(c)
+			So(resultErr, ShouldBeNil)
+
+			Convey("It should pass on the payload and signature and return http.StatusAccepted", func() {
+				tcServer.AssertExpectations(t)
+				So(rec.Code, ShouldEqual, http.StatusAccepted)
+			})
+		})
+
+		Convey("When the event carries the secret as a bearer token instead", func() {
+			c, _ := createTestPostRequest("/api/webhooks/teamcity", payload)
+			c.Request().Header.Set("Authorization", "Bearer s3cret")
+			tcServer.On("Webhook", "", payload, "Bearer s3cret").Return(nil)
+
+			resultErr := s.TeamCityWebhook(c)
+
+			Convey("It should pass on the authorization", func() {
+				So(resultErr, ShouldBeNil)
+				tcServer.AssertExpectations(t)
+			})
+		})
+
+		Convey("When the monitor refuses the event", func() {
+			cases := map[error]int{
+				ci.ErrWebhooksDisabled:               http.StatusNotFound,
+				ci.ErrBadSignature:                   http.StatusUnauthorized,
+				ci.ErrNotRunning:                     http.StatusServiceUnavailable,
+				ci.ErrWebhooksBusy:                   http.StatusServiceUnavailable,
+				errors.New("the payload is garbage"): http.StatusBadRequest,
+			}
+
+			Convey("It should return the matching status", func() {
+				for err, status := range cases {
+					c, rec := createTestPostRequest("/api/webhooks/teamcity", payload)
+					tcServer := new(ITcServerMock)
+					tcServer.On("Webhook", "", payload, "").Return(err)
+					s.TcServer = tcServer
+
+					So(s.TeamCityWebhook(c), ShouldBeNil)
+					So(rec.Code, ShouldEqual, status)
+				}
+			})
+		})
+	})
+}
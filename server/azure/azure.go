@@ -0,0 +1,223 @@
+package azure
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+)
+
+// Source is the monitor source and id prefix used for everything that comes from Azure DevOps
+const Source = "azure"
+
+// Azure is the ci.Provider for Azure DevOps Pipelines. The organization is the top level project,
+// its team projects sit under it and each pipeline definition becomes a build type.
+type Azure struct {
+	client       *client
+	organization string
+
+	mu       sync.Mutex
+	projects []string
+}
+
+func New(baseUrl, organization, token string) (*Azure, error) {
+	if token == "" {
+		return nil, errors.New("azure devops needs a personal access token")
+	}
+
+	return &Azure{client: newClient(baseUrl, organization, token), organization: organization}, nil
+}
+
+func (a *Azure) GetProjects() ([]db.Project, error) {
+	teamProjects, err := a.client.projects()
+	if err != nil {
+		return nil, err
+	}
+
+	projects := []db.Project{{Id: a.organizationId(), Name: a.organization, Description: "Azure DevOps"}}
+	for _, p := range teamProjects {
+		projects = append(projects, db.Project{
+			Id:              a.projectId(p.Id),
+			Name:            p.Name,
+			Description:     p.Description,
+			ParentProjectID: a.organizationId(),
+		})
+	}
+
+	return projects, nil
+}
+
+func (a *Azure) GetBuildTypes() ([]db.BuildType, error) {
+	teamProjects, err := a.client.projects()
+	if err != nil {
+		return nil, err
+	}
+
+	buildTypes := []db.BuildType{}
+	ids := []string{}
+	for _, p := range teamProjects {
+		projectId := p.Id
+		ids = append(ids, projectId)
+
+		definitions, err := a.client.definitions(projectId)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, d := range definitions {
+			buildTypes = append(buildTypes, db.BuildType{
+				Id:          a.definitionId(projectId, d.Id),
+				Name:        d.Name,
+				Description: d.Path,
+				ProjectID:   a.projectId(projectId),
+			})
+		}
+	}
+
+	a.mu.Lock()
+	a.projects = ids
+	a.mu.Unlock()
+
+	return buildTypes, nil
+}
+
+func (a *Azure) GetBuildsForBuildType(id string, count int) ([]ci.Build, error) {
+	projectId, definition := a.parseId(id)
+
+	query := url.Values{
+		"definitions": {definition},
+		"$top":        {strconv.Itoa(count)},
+		"queryOrder":  {"queueTimeDescending"},
+	}
+
+	builds, err := a.client.builds(projectId, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.buildsToCi(projectId, builds), nil
+}
+
+// GetRunningBuilds asks each team project for its in progress and queued builds since there is no organization
+// wide list
+func (a *Azure) GetRunningBuilds() ([]ci.Build, error) {
+	a.mu.Lock()
+	projects := a.projects
+	a.mu.Unlock()
+
+	running := []ci.Build{}
+	for _, projectId := range projects {
+		builds, err := a.client.builds(projectId, url.Values{"statusFilter": {"inProgress,notStarted"}})
+		if err != nil {
+			return nil, err
+		}
+
+		running = append(running, a.buildsToCi(projectId, builds)...)
+	}
+
+	return running, nil
+}
+
+func (a *Azure) GetBuild(b ci.Build) (ci.Build, error) {
+	projectId, _ := a.parseId(b.BuildTypeId)
+
+	build, err := a.client.build(projectId, b.Id)
+	if err != nil {
+		return ci.Build{}, err
+	}
+
+	return buildToCi(b.BuildTypeId, build), nil
+}
+
+func (a *Azure) buildsToCi(projectId string, builds []build) []ci.Build {
+	ciBuilds := []ci.Build{}
+	for _, b := range builds {
+		ciBuilds = append(ciBuilds, buildToCi(a.definitionId(projectId, b.Definition.Id), b))
+	}
+
+	return ciBuilds
+}
+
+func buildToCi(buildTypeId string, b build) ci.Build {
+	build := ci.Build{
+		Id:          b.Id,
+		BuildTypeId: buildTypeId,
+		BranchName:  strings.TrimPrefix(b.SourceBranch, "refs/heads/"),
+		Number:      b.BuildNumber,
+		Status:      StatusToDb(b.Status, b.Result),
+		Kind:        KindToDb(b.Status, b.Result),
+		StatusText:  b.Result,
+		StartDate:   b.QueueTime,
+	}
+
+	if build.StatusText == "" || build.StatusText == "none" {
+		build.StatusText = b.Status
+	}
+
+	if b.StartTime != nil {
+		build.StartDate = *b.StartTime
+	}
+
+	if b.Status == "completed" {
+		build.Progress = 100
+		if b.FinishTime != nil {
+			build.FinishDate = *b.FinishTime
+		}
+	}
+
+	return build
+}
+
+// StatusToDb treats every build that has not completed as running, queued builds included. A completed build
+// succeeded when its result is succeeded, canceled builds and builds without a result are unknown and a partial
+// success, a failure or any other result fails.
+func StatusToDb(status, result string) db.BuildStatus {
+	if status != "completed" {
+		return db.StatusRunning
+	}
+
+	switch result {
+	case "succeeded":
+		return db.StatusSuccess
+	case "canceled", "none":
+		return db.StatusUnknown
+	}
+
+	return db.StatusFailure
+}
+
+// KindToDb marks the builds that were canceled or completed without a result as canceled
+func KindToDb(status, result string) db.BuildKind {
+	if status == "completed" && (result == "canceled" || result == "none") {
+		return db.KindCanceled
+	}
+
+	return db.KindNormal
+}
+
+func (a *Azure) organizationId() string {
+	return Source + ":" + a.organization
+}
+
+// Team projects are keyed by their guid so a rename does not orphan the dashboards using them
+func (a *Azure) projectId(projectId string) string {
+	return a.organizationId() + ":" + projectId
+}
+
+func (a *Azure) definitionId(projectId string, definition int) string {
+	return a.projectId(projectId) + ":" + strconv.Itoa(definition)
+}
+
+// parseId splits azure:organization:project:definition back into the project guid and definition id
+func (a *Azure) parseId(id string) (string, string) {
+	parts := strings.Split(strings.TrimPrefix(id, a.organizationId()+":"), ":")
+	if len(parts) != 2 {
+		return "", ""
+	}
+
+	return parts[0], parts[1]
+}
@@ -0,0 +1,229 @@
+package azure_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"build-monitor-v2/server/azure"
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const portal = "eb6e4656-77fc-42a1-9181-4c6d8e9da5d1"
+
+type fixture struct {
+	file string
+	next string
+}
+
+var fixtures = map[string]fixture{
+	"/acme/_apis/projects":                                                       {"projects.json", ""},
+	"/acme/" + portal + "/_apis/build/definitions":                               {"definitions.json", "page-2"},
+	"/acme/" + portal + "/_apis/build/definitions?page-2":                        {"definitions-2.json", ""},
+	"/acme/" + portal + "/_apis/build/builds?definitions=12":                     {"builds.json", ""},
+	"/acme/" + portal + "/_apis/build/builds?statusFilter=inProgress,notStarted": {"running.json", ""},
+	"/acme/" + portal + "/_apis/build/builds/2201":                               {"build-2201.json", ""},
+}
+
+// newAzureServer replays the recorded Azure DevOps api responses in testdata
+func newAzureServer(requests *[]*http.Request) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r)
+
+		if _, pat, _ := r.BasicAuth(); pat != "secret-pat" {
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		query := r.URL.Query()
+		key := r.URL.Path
+		if token := query.Get("continuationToken"); token != "" {
+			key += "?" + token
+		}
+
+		if definitions := query.Get("definitions"); definitions != "" {
+			key += "?definitions=" + definitions
+		}
+
+		if status := query.Get("statusFilter"); status != "" {
+			key += "?statusFilter=" + status
+		}
+
+		f, ok := fixtures[key]
+		if !ok || query.Get("api-version") == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		body, err := ioutil.ReadFile("testdata/" + f.file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if f.next != "" {
+			w.Header().Set("X-MS-ContinuationToken", f.next)
+		}
+		w.Write(body)
+	}))
+}
+
+func TestNew(t *testing.T) {
+	Convey("Given no personal access token", t, func() {
+		_, err := azure.New("https://dev.azure.com", "acme", "")
+
+		Convey("It should return an error", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestAzure_GetProjects_GetBuildTypes(t *testing.T) {
+	Convey("Given an organization with a team project", t, func() {
+		var requests []*http.Request
+		server := newAzureServer(&requests)
+		defer server.Close()
+
+		a, _ := azure.New(server.URL, "acme", "secret-pat")
+
+		Convey("When GetProjects is called", func() {
+			projects, err := a.GetProjects()
+
+			Convey("It should map the organization and its team projects to projects", func() {
+				So(err, ShouldBeNil)
+				So(projects, ShouldResemble, []db.Project{
+					{Id: "azure:acme", Name: "acme", Description: "Azure DevOps"},
+					{Id: "azure:acme:" + portal, Name: "Client Portal", Description: "Portal for the client team", ParentProjectID: "azure:acme"},
+				})
+			})
+		})
+
+		Convey("When GetBuildTypes is called", func() {
+			buildTypes, err := a.GetBuildTypes()
+
+			Convey("It should map every page of pipeline definitions to build types", func() {
+				So(err, ShouldBeNil)
+				So(buildTypes, ShouldResemble, []db.BuildType{
+					{Id: "azure:acme:" + portal + ":12", Name: "portal-ci", Description: "\\", ProjectID: "azure:acme:" + portal},
+					{Id: "azure:acme:" + portal + ":14", Name: "portal-release", Description: "\\release", ProjectID: "azure:acme:" + portal},
+					{Id: "azure:acme:" + portal + ":15", Name: "portal-nightly", Description: "\\", ProjectID: "azure:acme:" + portal},
+				})
+			})
+		})
+
+		Convey("When the token is wrong", func() {
+			a, _ := azure.New(server.URL, "acme", "wrong")
+			_, err := a.GetProjects()
+
+			Convey("It should return the error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "401")
+			})
+		})
+	})
+}
+
+func TestAzure_Builds(t *testing.T) {
+	Convey("Given a pipeline definition with runs", t, func() {
+		var requests []*http.Request
+		server := newAzureServer(&requests)
+		defer server.Close()
+
+		a, _ := azure.New(server.URL, "acme", "secret-pat")
+		buildTypeId := "azure:acme:" + portal + ":12"
+
+		Convey("When GetBuildsForBuildType is called", func() {
+			builds, err := a.GetBuildsForBuildType(buildTypeId, 25)
+
+			Convey("It should map the runs onto their branches", func() {
+				So(err, ShouldBeNil)
+				So(builds, ShouldResemble, []ci.Build{
+					{
+						Id:          2201,
+						BuildTypeId: buildTypeId,
+						BranchName:  "feature/login",
+						Number:      "20171004.3",
+						Status:      db.StatusRunning,
+						StatusText:  "inProgress",
+						StartDate:   time.Date(2017, 10, 4, 18, 25, 0, 0, time.UTC),
+					},
+					{
+						Id:          2200,
+						BuildTypeId: buildTypeId,
+						BranchName:  "main",
+						Number:      "20171004.2",
+						Status:      db.StatusFailure,
+						StatusText:  "partiallySucceeded",
+						Progress:    100,
+						StartDate:   time.Date(2017, 10, 4, 18, 20, 0, 0, time.UTC),
+						FinishDate:  time.Date(2017, 10, 4, 18, 24, 0, 0, time.UTC),
+					},
+				})
+			})
+
+			Convey("It should only ask for the requested number of builds", func() {
+				So(requests[0].URL.Query().Get("$top"), ShouldEqual, "25")
+			})
+		})
+
+		Convey("When GetRunningBuilds is called after the build types are loaded", func() {
+			_, btErr := a.GetBuildTypes()
+			So(btErr, ShouldBeNil)
+
+			builds, err := a.GetRunningBuilds()
+
+			Convey("It should return the in progress and queued builds of every team project", func() {
+				So(err, ShouldBeNil)
+				So(len(builds), ShouldEqual, 2)
+				So(builds[0].Id, ShouldEqual, 2201)
+				So(builds[0].BuildTypeId, ShouldEqual, buildTypeId)
+				So(builds[1].Id, ShouldEqual, 2202)
+				So(builds[1].Status, ShouldEqual, db.StatusRunning)
+				So(builds[1].StartDate, ShouldResemble, time.Date(2017, 10, 4, 18, 26, 0, 0, time.UTC))
+			})
+
+			Convey("And GetBuild is called once it has finished", func() {
+				build, err := a.GetBuild(builds[0])
+
+				Convey("It should re-read the build", func() {
+					So(err, ShouldBeNil)
+					So(build.Status, ShouldEqual, db.StatusSuccess)
+					So(build.FinishDate, ShouldResemble, time.Date(2017, 10, 4, 18, 28, 0, 0, time.UTC))
+				})
+			})
+		})
+	})
+}
+
+func TestStatusToDb(t *testing.T) {
+	Convey("Given Azure DevOps build statuses and results", t, func() {
+		cases := []struct {
+			status, result string
+			expected       db.BuildStatus
+			kind           db.BuildKind
+		}{
+			{"notStarted", "", db.StatusRunning, db.KindNormal},
+			{"inProgress", "", db.StatusRunning, db.KindNormal},
+			{"cancelling", "", db.StatusRunning, db.KindNormal},
+			{"completed", "succeeded", db.StatusSuccess, db.KindNormal},
+			{"completed", "partiallySucceeded", db.StatusFailure, db.KindNormal},
+			{"completed", "failed", db.StatusFailure, db.KindNormal},
+			{"completed", "canceled", db.StatusUnknown, db.KindCanceled},
+			{"completed", "none", db.StatusUnknown, db.KindCanceled},
+			{"completed", "somethingNew", db.StatusFailure, db.KindNormal},
+		}
+
+		Convey("It should map them to the db statuses and kinds", func() {
+			for _, c := range cases {
+				So(azure.StatusToDb(c.status, c.result), ShouldEqual, c.expected)
+				So(azure.KindToDb(c.status, c.result), ShouldEqual, c.kind)
+			}
+		})
+	})
+}
@@ -0,0 +1,162 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const apiVersion = "6.0"
+
+type teamProject struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type definition struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type build struct {
+	Id           int        `json:"id"`
+	BuildNumber  string     `json:"buildNumber"`
+	Status       string     `json:"status"`
+	Result       string     `json:"result"`
+	SourceBranch string     `json:"sourceBranch"`
+	QueueTime    time.Time  `json:"queueTime"`
+	StartTime    *time.Time `json:"startTime"`
+	FinishTime   *time.Time `json:"finishTime"`
+	Definition   definition `json:"definition"`
+}
+
+// client is a thin wrapper around the Azure DevOps REST api for a single organization
+type client struct {
+	url   string
+	token string
+	http  *http.Client
+}
+
+func newClient(baseUrl, organization, token string) *client {
+	return &client{
+		url:   strings.TrimSuffix(baseUrl, "/") + "/" + url.PathEscape(organization),
+		token: token,
+		http:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// get decodes one page into v and returns the continuation token for the next page, blank on the last page
+func (c *client) get(path string, query url.Values, v interface{}) (string, error) {
+	q := url.Values{}
+	for k, vs := range query {
+		q[k] = vs
+	}
+	q.Set("api-version", apiVersion)
+
+	u := c.url + path + "?" + q.Encode()
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return "", err
+	}
+
+	// Personal access tokens go in as the password with any user name
+	req.SetBasicAuth("", c.token)
+	req.Header.Set("Accept", "application/json")
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("azure devops returned %s for %s", res.Status, u)
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return "", err
+	}
+
+	return res.Header.Get("X-MS-ContinuationToken"), nil
+}
+
+// list follows the continuation tokens and hands each page to add
+func (c *client) list(path string, query url.Values, add func(page json.RawMessage) error) error {
+	if query == nil {
+		query = url.Values{}
+	}
+
+	for {
+		var page struct {
+			Value json.RawMessage `json:"value"`
+		}
+
+		next, err := c.get(path, query, &page)
+		if err != nil {
+			return err
+		}
+
+		if err := add(page.Value); err != nil {
+			return err
+		}
+
+		if next == "" {
+			return nil
+		}
+
+		query.Set("continuationToken", next)
+	}
+}
+
+func (c *client) projects() ([]teamProject, error) {
+	all := []teamProject{}
+	err := c.list("/_apis/projects", nil, func(page json.RawMessage) error {
+		var projects []teamProject
+		if err := json.Unmarshal(page, &projects); err != nil {
+			return err
+		}
+
+		all = append(all, projects...)
+		return nil
+	})
+
+	return all, err
+}
+
+func (c *client) definitions(projectId string) ([]definition, error) {
+	all := []definition{}
+	err := c.list("/"+projectId+"/_apis/build/definitions", nil, func(page json.RawMessage) error {
+		var definitions []definition
+		if err := json.Unmarshal(page, &definitions); err != nil {
+			return err
+		}
+
+		all = append(all, definitions...)
+		return nil
+	})
+
+	return all, err
+}
+
+func (c *client) builds(projectId string, query url.Values) ([]build, error) {
+	var page struct {
+		Value []build `json:"value"`
+	}
+
+	_, err := c.get("/"+projectId+"/_apis/build/builds", query, &page)
+
+	return page.Value, err
+}
+
+func (c *client) build(projectId string, id int) (build, error) {
+	var b build
+	_, err := c.get(fmt.Sprintf("/%s/_apis/build/builds/%d", projectId, id), nil, &b)
+
+	return b, err
+}
@@ -1,16 +1,54 @@
 package cfg
 
 type Config struct {
-	gofigure                   interface{} `envPrefix:"BM" order:"flag,env"`
-	Port                       int         `env:"port" flag:"port" flagDesc:"Port to run the api server on"`
-	Db                         string      `env:"db" flag:"db" flagDesc:"Url to mongodb"`
-	PasswordSalt               string      `env:"passwordSalt" flag:"passwordSalt" flagDesc:"Salt to use for the password"`
-	ClientPath                 string      `env:"clientPath" flag:"clientPath" flagDesc:"Path to where the client code is stored"`
-	AllowedOrigin              string      `env:"allowedOrigin" flag:"allowedOrigin" flagDesc:"The CORS allowed origin"`
-	JwtSecret                  string      `env:"jwtSecret" flag:"jwtSecret" flagDesc:"The secret key for the JWT token"`
-	TcUrl                      string      `env:"tcUrl" flag:"tcUrl" flagDesc:"The main url for the TeamCity REST API"`
-	TcPollInterval             string      `env:"tcPollInterval" flag:"tcPollInterval" flagDesc:"How often to poll TeamCity for builds"`
-	TcRunningBuildPollInterval string      `env:"tcRunningBuildPollInterval" flag:"tcRunningBuildPollInterval" flagDesc:"How often to poll TeamCity when we have running builds"`
+	gofigure                            interface{} `envPrefix:"BM" order:"flag,env"`
+	Port                                int         `env:"port" flag:"port" flagDesc:"Port to run the api server on"`
+	Db                                  string      `env:"db" flag:"db" flagDesc:"Url to mongodb"`
+	PasswordSalt                        string      `env:"passwordSalt" flag:"passwordSalt" flagDesc:"Salt to use for the password"`
+	ClientPath                          string      `env:"clientPath" flag:"clientPath" flagDesc:"Path to where the client code is stored"`
+	AllowedOrigin                       string      `env:"allowedOrigin" flag:"allowedOrigin" flagDesc:"The CORS allowed origin"`
+	JwtSecret                           string      `env:"jwtSecret" flag:"jwtSecret" flagDesc:"The secret key for the JWT token"`
+	TcUrl                               string      `env:"tcUrl" flag:"tcUrl" flagDesc:"The main url for the TeamCity REST API"`
+	TcPollInterval                      string      `env:"tcPollInterval" flag:"tcPollInterval" flagDesc:"How often to poll TeamCity for builds"`
+	TcRunningBuildPollInterval          string      `env:"tcRunningBuildPollInterval" flag:"tcRunningBuildPollInterval" flagDesc:"How often to poll TeamCity when we have running builds"`
+	TcToken                             string      `env:"tcToken" flag:"tcToken" flagDesc:"An access token for TeamCity, guest access is used when no token or user is set"`
+	TcTokenFile                         string      `env:"tcTokenFile" flag:"tcTokenFile" flagDesc:"A file to read the TeamCity access token from"`
+	TcUser                              string      `env:"tcUser" flag:"tcUser" flagDesc:"The TeamCity user to call the REST API as"`
+	TcPassword                          string      `env:"tcPassword" flag:"tcPassword" flagDesc:"The password for the TeamCity user"`
+	TcPasswordFile                      string      `env:"tcPasswordFile" flag:"tcPasswordFile" flagDesc:"A file to read the TeamCity password from"`
+	TcSyncConcurrency                   int         `env:"tcSyncConcurrency" flag:"tcSyncConcurrency" flagDesc:"How many build types to sync from TeamCity at once"`
+	TcRequestsPerSecond                 int         `env:"tcRequestsPerSecond" flag:"tcRequestsPerSecond" flagDesc:"The most requests a second to send each TeamCity server, 0 for no limit"`
+	TcWebhookSecret                     string      `env:"tcWebhookSecret" flag:"tcWebhookSecret" flagDesc:"The shared secret TeamCity webhooks are signed with, webhooks are refused when it is not set"`
+	TcWebhookSecretFile                 string      `env:"tcWebhookSecretFile" flag:"tcWebhookSecretFile" flagDesc:"A file to read the TeamCity webhook secret from"`
+	TcWebhookPollInterval               string      `env:"tcWebhookPollInterval" flag:"tcWebhookPollInterval" flagDesc:"How often to poll TeamCity to catch missed events when webhooks are enabled"`
+	TcServers                           string      `env:"tcServers" flag:"tcServers" flagDesc:"A json list of named TeamCity servers to monitor instead of TcUrl"`
+	JenkinsUrl                          string      `env:"jenkinsUrl" flag:"jenkinsUrl" flagDesc:"The main url for Jenkins, leave blank to disable the Jenkins monitor"`
+	JenkinsUser                         string      `env:"jenkinsUser" flag:"jenkinsUser" flagDesc:"The Jenkins user to call the api as"`
+	JenkinsToken                        string      `env:"jenkinsToken" flag:"jenkinsToken" flagDesc:"The api token for the Jenkins user"`
+	JenkinsPollInterval                 string      `env:"jenkinsPollInterval" flag:"jenkinsPollInterval" flagDesc:"How often to poll Jenkins for builds"`
+	JenkinsRunningBuildPollInterval     string      `env:"jenkinsRunningBuildPollInterval" flag:"jenkinsRunningBuildPollInterval" flagDesc:"How often to poll Jenkins when we have running builds"`
+	GitLabUrl                           string      `env:"gitlabUrl" flag:"gitlabUrl" flagDesc:"The main url for GitLab, leave blank to disable the GitLab monitor"`
+	GitLabToken                         string      `env:"gitlabToken" flag:"gitlabToken" flagDesc:"The private token to call the GitLab api with"`
+	GitLabPollInterval                  string      `env:"gitlabPollInterval" flag:"gitlabPollInterval" flagDesc:"How often to poll GitLab for builds"`
+	GitLabRunningBuildPollInterval      string      `env:"gitlabRunningBuildPollInterval" flag:"gitlabRunningBuildPollInterval" flagDesc:"How often to poll GitLab when we have running builds"`
+	GitHubApiUrl                        string      `env:"githubApiUrl" flag:"githubApiUrl" flagDesc:"The GitHub REST api url, change it for GitHub Enterprise"`
+	GitHubToken                         string      `env:"githubToken" flag:"githubToken" flagDesc:"The token to call the GitHub api with"`
+	GitHubRepos                         string      `env:"githubRepos" flag:"githubRepos" flagDesc:"Comma separated owner/name of the repositories to watch, leave blank to disable the GitHub Actions monitor"`
+	GitHubPollInterval                  string      `env:"githubPollInterval" flag:"githubPollInterval" flagDesc:"How often to poll GitHub for workflow runs"`
+	GitHubRunningBuildPollInterval      string      `env:"githubRunningBuildPollInterval" flag:"githubRunningBuildPollInterval" flagDesc:"How often to poll GitHub when we have running workflow runs"`
+	AzureDevOpsUrl                      string      `env:"azureDevopsUrl" flag:"azureDevopsUrl" flagDesc:"The Azure DevOps url, change it for Azure DevOps Server"`
+	AzureDevOpsOrganization             string      `env:"azureDevopsOrganization" flag:"azureDevopsOrganization" flagDesc:"The Azure DevOps organization to watch, leave blank to disable the Azure DevOps monitor"`
+	AzureDevOpsToken                    string      `env:"azureDevopsToken" flag:"azureDevopsToken" flagDesc:"The personal access token to call the Azure DevOps api with"`
+	AzureDevOpsPollInterval             string      `env:"azureDevopsPollInterval" flag:"azureDevopsPollInterval" flagDesc:"How often to poll Azure DevOps for builds"`
+	AzureDevOpsRunningBuildPollInterval string      `env:"azureDevopsRunningBuildPollInterval" flag:"azureDevopsRunningBuildPollInterval" flagDesc:"How often to poll Azure DevOps when we have running builds"`
+	BreakerThreshold                    int         `env:"breakerThreshold" flag:"breakerThreshold" flagDesc:"How many failed calls in a row open the circuit to a CI server"`
+	BackoffMaxInterval                  string      `env:"backoffMaxInterval" flag:"backoffMaxInterval" flagDesc:"The longest wait between calls to a CI server that keeps failing"`
+	DegradedStart                       bool        `env:"degradedStart" flag:"degradedStart" flagDesc:"Start and serve the stored builds when a CI server can not be reached"`
+	HistoryDepth                        int         `env:"historyDepth" flag:"historyDepth" flagDesc:"How many builds to keep per branch, dashboards can ask for more"`
+	BranchRetention                     string      `env:"branchRetention" flag:"branchRetention" flagDesc:"Drop the branches whose last build is older than this, leave blank to keep them forever"`
+	PersonalBuilds                      string      `env:"personalBuilds" flag:"personalBuilds" flagDesc:"How dashboards treat personal builds: ignore, show without counting them toward the status, or count"`
+	CanceledBuilds                      string      `env:"canceledBuilds" flag:"canceledBuilds" flagDesc:"How dashboards treat canceled builds: ignore, show or count"`
+	FailedToStartBuilds                 string      `env:"failedToStartBuilds" flag:"failedToStartBuilds" flagDesc:"How dashboards treat builds that failed to start: ignore, show or count"`
 }
 
 func Load(getOverrides func(s interface{}) error) (Config, error) {
@@ -26,14 +64,33 @@ func Load(getOverrides func(s interface{}) error) (Config, error) {
 
 func getDefaults() Config {
 	return Config{
-		Port:                       3030,
-		Db:                         "mongodb://localhost:27017/build-monitor-v2",
-		PasswordSalt:               "you-really-need-to-change-this",
-		ClientPath:                 "../client/dist",
-		AllowedOrigin:              "*",
-		JwtSecret:                  "you-really-need-to-change-this-one-also",
-		TcUrl:                      "http://localhost:3031",
-		TcPollInterval:             "20s",
-		TcRunningBuildPollInterval: "5s",
+		Port:                                3030,
+		Db:                                  "mongodb://localhost:27017/build-monitor-v2",
+		PasswordSalt:                        "you-really-need-to-change-this",
+		ClientPath:                          "../client/dist",
+		AllowedOrigin:                       "*",
+		JwtSecret:                           "you-really-need-to-change-this-one-also",
+		TcUrl:                               "http://localhost:3031",
+		TcPollInterval:                      "20s",
+		TcRunningBuildPollInterval:          "5s",
+		TcSyncConcurrency:                   4,
+		TcRequestsPerSecond:                 10,
+		TcWebhookPollInterval:               "5m",
+		JenkinsPollInterval:                 "20s",
+		JenkinsRunningBuildPollInterval:     "5s",
+		GitLabPollInterval:                  "30s",
+		GitLabRunningBuildPollInterval:      "10s",
+		GitHubApiUrl:                        "https://api.github.com",
+		GitHubPollInterval:                  "60s",
+		GitHubRunningBuildPollInterval:      "15s",
+		AzureDevOpsUrl:                      "https://dev.azure.com",
+		AzureDevOpsPollInterval:             "30s",
+		AzureDevOpsRunningBuildPollInterval: "10s",
+		BreakerThreshold:                    3,
+		BackoffMaxInterval:                  "5m",
+		HistoryDepth:                        12,
+		PersonalBuilds:                      "ignore",
+		CanceledBuilds:                      "show",
+		FailedToStartBuilds:                 "count",
 	}
 }
@@ -17,6 +17,32 @@ func runConfigAsserts(c *cfg.Config) {
 	So(c.AllowedOrigin, ShouldEqual, "*")
 	So(c.PasswordSalt, ShouldEqual, "you-really-need-to-change-this")
 	So(c.JwtSecret, ShouldEqual, "you-really-need-to-change-this-one-also")
+	So(c.TcWebhookSecret, ShouldEqual, "")
+	So(c.TcWebhookPollInterval, ShouldEqual, "5m")
+	So(c.BreakerThreshold, ShouldEqual, 3)
+	So(c.BackoffMaxInterval, ShouldEqual, "5m")
+	So(c.DegradedStart, ShouldBeFalse)
+	So(c.HistoryDepth, ShouldEqual, 12)
+	So(c.BranchRetention, ShouldEqual, "")
+	So(c.PersonalBuilds, ShouldEqual, "ignore")
+	So(c.CanceledBuilds, ShouldEqual, "show")
+	So(c.FailedToStartBuilds, ShouldEqual, "count")
+	So(c.TcSyncConcurrency, ShouldEqual, 4)
+	So(c.TcRequestsPerSecond, ShouldEqual, 10)
+	So(c.JenkinsUrl, ShouldEqual, "")
+	So(c.JenkinsPollInterval, ShouldEqual, "20s")
+	So(c.JenkinsRunningBuildPollInterval, ShouldEqual, "5s")
+	So(c.GitLabUrl, ShouldEqual, "")
+	So(c.GitLabPollInterval, ShouldEqual, "30s")
+	So(c.GitLabRunningBuildPollInterval, ShouldEqual, "10s")
+	So(c.GitHubApiUrl, ShouldEqual, "https://api.github.com")
+	So(c.GitHubRepos, ShouldEqual, "")
+	So(c.GitHubPollInterval, ShouldEqual, "60s")
+	So(c.GitHubRunningBuildPollInterval, ShouldEqual, "15s")
+	So(c.AzureDevOpsUrl, ShouldEqual, "https://dev.azure.com")
+	So(c.AzureDevOpsOrganization, ShouldEqual, "")
+	So(c.AzureDevOpsPollInterval, ShouldEqual, "30s")
+	So(c.AzureDevOpsRunningBuildPollInterval, ShouldEqual, "10s")
 }
 
 func TestLoad(t *testing.T) {
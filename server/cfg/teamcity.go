@@ -0,0 +1,160 @@
+package cfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// TeamCityServer is one entry of TcServers. The name namespaces the server's project and build type
+// ids, leave it blank on at most one server to keep the ids of an existing single server setup.
+type TeamCityServer struct {
+	Name                     string `json:"name"`
+	Url                      string `json:"url"`
+	Token                    string `json:"token"`
+	TokenFile                string `json:"tokenFile"`
+	User                     string `json:"user"`
+	Password                 string `json:"password"`
+	PasswordFile             string `json:"passwordFile"`
+	PollInterval             string `json:"pollInterval"`
+	RunningBuildPollInterval string `json:"runningBuildPollInterval"`
+	SyncConcurrency          int    `json:"syncConcurrency"`
+	RequestsPerSecond        int    `json:"requestsPerSecond"`
+	WebhookSecret            string `json:"webhookSecret"`
+	WebhookSecretFile        string `json:"webhookSecretFile"`
+	WebhookPollInterval      string `json:"webhookPollInterval"`
+}
+
+// reservedSources are the Source names of the other providers, a TeamCity server with one of those names
+// would give its ids the provider's prefix and its build types and webhooks would go to the provider's monitor
+var reservedSources = []string{"jenkins", "gitlab", "github", "azure"}
+
+// TeamCityServers returns the servers in TcServers, or the single TcUrl server when it is not set.
+// Intervals that are left out fall back to the Tc* intervals and the token, password and webhook
+// secret files are read so mounted secrets never need to be in the environment.
+func (c *Config) TeamCityServers() ([]TeamCityServer, error) {
+	if strings.TrimSpace(c.TcServers) == "" {
+		server := TeamCityServer{
+			Url:                      c.TcUrl,
+			Token:                    c.TcToken,
+			TokenFile:                c.TcTokenFile,
+			User:                     c.TcUser,
+			Password:                 c.TcPassword,
+			PasswordFile:             c.TcPasswordFile,
+			PollInterval:             c.TcPollInterval,
+			RunningBuildPollInterval: c.TcRunningBuildPollInterval,
+			SyncConcurrency:          c.TcSyncConcurrency,
+			RequestsPerSecond:        c.TcRequestsPerSecond,
+			WebhookSecret:            c.TcWebhookSecret,
+			WebhookSecretFile:        c.TcWebhookSecretFile,
+			WebhookPollInterval:      c.TcWebhookPollInterval,
+		}
+
+		if err := server.readSecrets(); err != nil {
+			return nil, err
+		}
+
+		return []TeamCityServer{server}, nil
+	}
+
+	var servers []TeamCityServer
+	if err := json.Unmarshal([]byte(c.TcServers), &servers); err != nil {
+		return nil, fmt.Errorf("tcServers is not valid json: %v", err)
+	}
+
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("tcServers must list at least one server")
+	}
+
+	names := map[string]bool{}
+	for i := range servers {
+		s := &servers[i]
+
+		if s.Url == "" {
+			return nil, fmt.Errorf("tcServers entry %d has no url", i)
+		}
+
+		if strings.Contains(s.Name, ":") {
+			return nil, fmt.Errorf("tcServers name %q cannot contain a colon", s.Name)
+		}
+
+		for _, source := range reservedSources {
+			if s.Name == source {
+				return nil, fmt.Errorf("tcServers name %q is used by the %s provider", s.Name, source)
+			}
+		}
+
+		if names[s.Name] {
+			return nil, fmt.Errorf("tcServers name %q is used more than once", s.Name)
+		}
+		names[s.Name] = true
+
+		if s.PollInterval == "" {
+			s.PollInterval = c.TcPollInterval
+		}
+
+		if s.RunningBuildPollInterval == "" {
+			s.RunningBuildPollInterval = c.TcRunningBuildPollInterval
+		}
+
+		if s.SyncConcurrency == 0 {
+			s.SyncConcurrency = c.TcSyncConcurrency
+		}
+
+		if s.RequestsPerSecond == 0 {
+			s.RequestsPerSecond = c.TcRequestsPerSecond
+		}
+
+		if s.WebhookPollInterval == "" {
+			s.WebhookPollInterval = c.TcWebhookPollInterval
+		}
+
+		if err := s.readSecrets(); err != nil {
+			return nil, err
+		}
+	}
+
+	return servers, nil
+}
+
+func (s *TeamCityServer) readSecrets() error {
+	if s.TokenFile != "" {
+		token, err := readSecret(s.TokenFile)
+		if err != nil {
+			return err
+		}
+
+		s.Token = token
+	}
+
+	if s.PasswordFile != "" {
+		password, err := readSecret(s.PasswordFile)
+		if err != nil {
+			return err
+		}
+
+		s.Password = password
+	}
+
+	if s.WebhookSecretFile != "" {
+		secret, err := readSecret(s.WebhookSecretFile)
+		if err != nil {
+			return err
+		}
+
+		s.WebhookSecret = secret
+	}
+
+	return nil
+}
+
+// readSecret trims the trailing new line most secret mounts and editors leave behind
+func readSecret(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %v", err)
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
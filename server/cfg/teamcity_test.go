@@ -0,0 +1,120 @@
+package cfg_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"build-monitor-v2/server/cfg"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestConfig_TeamCityServers(t *testing.T) {
+	Convey("Given a config", t, func() {
+		c := cfg.Config{
+			TcUrl:                      "http://tc.local",
+			TcPollInterval:             "20s",
+			TcRunningBuildPollInterval: "5s",
+		}
+
+		Convey("When TcServers is not set", func() {
+			servers, err := c.TeamCityServers()
+
+			Convey("It should return the TcUrl server without a name", func() {
+				So(err, ShouldBeNil)
+				So(servers, ShouldResemble, []cfg.TeamCityServer{
+					{Url: "http://tc.local", PollInterval: "20s", RunningBuildPollInterval: "5s"},
+				})
+			})
+		})
+
+		Convey("When TcServers lists named servers", func() {
+			c.TcServers = `[
+				{"name": "onprem", "url": "http://tc.onprem", "user": "radiator", "password": "pw"},
+				{"name": "cloud", "url": "https://acme.teamcity.com", "pollInterval": "1m", "runningBuildPollInterval": "10s"}
+			]`
+
+			servers, err := c.TeamCityServers()
+
+			Convey("It should return each server and default the missing intervals", func() {
+				So(err, ShouldBeNil)
+				So(servers, ShouldResemble, []cfg.TeamCityServer{
+					{Name: "onprem", Url: "http://tc.onprem", User: "radiator", Password: "pw", PollInterval: "20s", RunningBuildPollInterval: "5s"},
+					{Name: "cloud", Url: "https://acme.teamcity.com", PollInterval: "1m", RunningBuildPollInterval: "10s"},
+				})
+			})
+		})
+
+		Convey("When TcServers is not valid", func() {
+			cases := []string{
+				`{"name": "not a list"}`,
+				`[]`,
+				`[{"name": "nourl"}]`,
+				`[{"name": "a:b", "url": "http://tc"}]`,
+				`[{"name": "twice", "url": "http://tc1"}, {"name": "twice", "url": "http://tc2"}]`,
+			}
+
+			for _, source := range []string{"jenkins", "gitlab", "github", "azure"} {
+				cases = append(cases, `[{"name": "`+source+`", "url": "http://tc"}]`)
+			}
+
+			Convey("It should return an error", func() {
+				for _, servers := range cases {
+					c.TcServers = servers
+
+					_, err := c.TeamCityServers()
+					So(err, ShouldNotBeNil)
+				}
+			})
+
+			Convey("It should say which provider uses a reserved name", func() {
+				c.TcServers = `[{"name": "gitlab", "url": "http://tc"}]`
+
+				_, err := c.TeamCityServers()
+				So(err.Error(), ShouldContainSubstring, "used by the gitlab provider")
+			})
+		})
+
+		Convey("When the credentials are mounted as files", func() {
+			dir, _ := ioutil.TempDir("", "tcsecrets")
+			defer os.RemoveAll(dir)
+
+			tokenFile := filepath.Join(dir, "token")
+			passwordFile := filepath.Join(dir, "password")
+			ioutil.WriteFile(tokenFile, []byte("eyJ0eXAi\n"), 0600)
+			ioutil.WriteFile(passwordFile, []byte("pw\n"), 0600)
+
+			Convey("It should read them for the TcUrl server", func() {
+				c.TcTokenFile = tokenFile
+
+				servers, err := c.TeamCityServers()
+
+				So(err, ShouldBeNil)
+				So(servers[0].Token, ShouldEqual, "eyJ0eXAi")
+			})
+
+			Convey("It should read them for each of the TcServers", func() {
+				c.TcWebhookPollInterval = "5m"
+				c.TcServers = `[{"name": "onprem", "url": "http://tc.onprem", "user": "radiator", "passwordFile": "` + passwordFile + `", "webhookSecretFile": "` + tokenFile + `"}]`
+
+				servers, err := c.TeamCityServers()
+
+				So(err, ShouldBeNil)
+				So(servers[0].User, ShouldEqual, "radiator")
+				So(servers[0].Password, ShouldEqual, "pw")
+				So(servers[0].WebhookSecret, ShouldEqual, "eyJ0eXAi")
+				So(servers[0].WebhookPollInterval, ShouldEqual, "5m")
+			})
+
+			Convey("It should return an error when a file cannot be read", func() {
+				c.TcPasswordFile = filepath.Join(dir, "missing")
+
+				_, err := c.TeamCityServers()
+
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,19 @@
+package ci
+
+// Agent is a build agent, the pool it takes builds from and the build it is running if it is busy
+type Agent struct {
+	Id          string
+	Name        string
+	PoolId      string
+	PoolName    string
+	Connected   bool
+	Authorized  bool
+	Enabled     bool
+	BuildId     int
+	BuildTypeId string
+}
+
+// AgentLister is implemented by providers that can list their build agents, including the disconnected ones
+type AgentLister interface {
+	GetAgents() ([]Agent, error)
+}
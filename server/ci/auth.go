@@ -0,0 +1,20 @@
+package ci
+
+import "fmt"
+
+// Authenticator is implemented by providers that can check their credentials up front,
+// the monitor calls it before the first refresh so a bad token fails start up clearly.
+type Authenticator interface {
+	CheckAuth() error
+}
+
+// AuthError is returned when a provider rejects the credentials it was configured with
+type AuthError struct {
+	Provider string
+	Url      string
+	Status   string
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("%s at %s rejected the configured credentials (%s)", e.Provider, e.Url, e.Status)
+}
@@ -0,0 +1,18 @@
+package ci
+
+import "time"
+
+// The states of a monitor's circuit breaker
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// BreakerStatus is how a monitor's circuit breaker stands, RetryAt is set while the server is failing
+type BreakerStatus struct {
+	State     string     `json:"state"`
+	Failures  int        `json:"failures"`
+	LastError string     `json:"lastError,omitempty"`
+	RetryAt   *time.Time `json:"retryAt,omitempty"`
+}
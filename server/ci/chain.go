@@ -0,0 +1,40 @@
+package ci
+
+import (
+	"errors"
+	"time"
+
+	"build-monitor-v2/server/db"
+)
+
+// ErrNoBuildChain is returned when the build type's server has no build chains
+var ErrNoBuildChain = errors.New("build chains are not available for this build type")
+
+// ChainBuild is one build of a build chain, DependsOn are the ids of the builds it waited for
+type ChainBuild struct {
+	Id            int            `json:"id"`
+	BuildTypeId   string         `json:"buildTypeId"`
+	BuildTypeName string         `json:"buildTypeName"`
+	Number        string         `json:"number"`
+	BranchName    string         `json:"branchName"`
+	Status        db.BuildStatus `json:"status"`
+	StatusText    string         `json:"statusText"`
+	StartDate     time.Time      `json:"startDate"`
+	FinishDate    time.Time      `json:"finishDate"`
+	DependsOn     []int          `json:"dependsOn"`
+}
+
+// BuildChain is a build with the builds it depends on, Upstream, and the ones that depend on it, Downstream.
+// Both lists put a build after the builds it depends on. BrokenBuildIds are the failed builds whose own
+// dependencies did not fail, the steps that broke the chain.
+type BuildChain struct {
+	Build          ChainBuild   `json:"build"`
+	Upstream       []ChainBuild `json:"upstream"`
+	Downstream     []ChainBuild `json:"downstream"`
+	BrokenBuildIds []int        `json:"brokenBuildIds"`
+}
+
+// BuildChainer is implemented by providers with snapshot dependencies between their build types
+type BuildChainer interface {
+	GetBuildChain(b Build) (BuildChain, error)
+}
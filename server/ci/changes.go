@@ -0,0 +1,14 @@
+package ci
+
+// Change is a VCS change that went into a build
+type Change struct {
+	Revision  string
+	Author    string
+	Comment   string
+	FileCount int
+}
+
+// ChangeLister is implemented by providers that can list the VCS changes of a build
+type ChangeLister interface {
+	GetChanges(b Build) ([]Change, error)
+}
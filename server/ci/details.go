@@ -0,0 +1,43 @@
+package ci
+
+import (
+	"errors"
+
+	"build-monitor-v2/server/db"
+)
+
+var (
+	// ErrBuildNotFound is returned when the build does not exist or belongs to another build type
+	ErrBuildNotFound = errors.New("the build was not found")
+
+	// ErrNoBuildDetails is returned when the build type's server can not explain its builds
+	ErrNoBuildDetails = errors.New("build details are not available for this build type")
+)
+
+// BuildDetails explains why a build failed, FailedTests holds an excerpt of each stack trace
+type BuildDetails struct {
+	Id          int            `json:"id"`
+	BuildTypeId string         `json:"buildTypeId"`
+	Number      string         `json:"number"`
+	Status      db.BuildStatus `json:"status"`
+	StatusText  string         `json:"statusText"`
+	Problems    []BuildProblem `json:"problems"`
+	FailedTests []FailedTest   `json:"failedTests"`
+}
+
+type BuildProblem struct {
+	Type    string `json:"type"`
+	Details string `json:"details"`
+}
+
+type FailedTest struct {
+	Name       string `json:"name"`
+	NewFailure bool   `json:"newFailure"`
+	Muted      bool   `json:"muted"`
+	Details    string `json:"details"`
+}
+
+// BuildDetailer is implemented by providers that can list the problems and failed tests of a build
+type BuildDetailer interface {
+	GetBuildDetails(b Build) (BuildDetails, error)
+}
@@ -0,0 +1,27 @@
+package ci
+
+import "time"
+
+// Investigation is someone taking responsibility for the failures of a build type
+type Investigation struct {
+	BuildTypeId string
+	Assignee    string
+	State       string
+	Comment     string
+	Since       time.Time
+}
+
+// MutedTest is a test whose failures do not fail the build type
+type MutedTest struct {
+	BuildTypeId string
+	Name        string
+	MutedBy     string
+	Comment     string
+}
+
+// Investigations is implemented by providers that know who is fixing a build type and which tests are muted.
+// Only open investigations are returned, so a resolved one disappears on the next sync.
+type Investigations interface {
+	GetInvestigations() ([]Investigation, error)
+	GetMutedTests() ([]MutedTest, error)
+}
@@ -0,0 +1,65 @@
+package ci
+
+import (
+	"strings"
+	"time"
+
+	"build-monitor-v2/server/db"
+)
+
+// Provider is a CI system that the monitor can pull projects, build types and builds from.
+// Everything handed back is already mapped onto the db model so the monitor never needs to
+// know which system it is talking to.
+type Provider interface {
+	GetProjects() ([]db.Project, error)
+	GetBuildTypes() ([]db.BuildType, error)
+	GetBuildsForBuildType(id string, count int) ([]Build, error)
+	GetRunningBuilds() ([]Build, error)
+
+	// GetBuild re-reads a build we have seen before, it is used to finish builds
+	// that have dropped out of the running list.
+	GetBuild(b Build) (Build, error)
+}
+
+// IncrementalHistory is implemented by providers that can list just the builds after one they returned before,
+// the monitor uses it to keep the history up to date without reading all of it again.
+type IncrementalHistory interface {
+	GetBuildsSince(buildTypeId string, sinceBuildId int, count int) ([]Build, error)
+}
+
+// DashboardScoped is implemented by providers that have to ask each build type for its running builds,
+// the monitor tells them which build types are on a dashboard so they leave the others alone
+type DashboardScoped interface {
+	SetDashboardBuildTypes(ids []string)
+}
+
+// RunningBuildsError is returned by GetRunningBuilds along with the builds it could read when some build types
+// could not be asked. The monitor takes the builds it knew of for those build types to still be running.
+type RunningBuildsError struct {
+	Errors []SyncError
+}
+
+func (e *RunningBuildsError) Error() string {
+	messages := []string{}
+	for _, se := range e.Errors {
+		messages = append(messages, se.BuildTypeId+": "+se.Message)
+	}
+
+	return "failed to get the running builds of " + strings.Join(messages, ", ")
+}
+
+// Build is a single build as reported by a Provider
+type Build struct {
+	Id          int
+	BuildTypeId string
+	BranchName  string
+	Number      string
+	Status      db.BuildStatus
+	StatusText  string
+	Progress    int
+	StartDate   time.Time
+	FinishDate  time.Time
+	Kind        db.BuildKind
+	// EstimatedFinishDate is the provider's own guess for a running build, zero when it has none
+	EstimatedFinishDate time.Time
+}
@@ -0,0 +1,18 @@
+package ci
+
+import "time"
+
+// QueuedBuild is a build waiting to start. Position is its place in the whole queue, starting at 1.
+type QueuedBuild struct {
+	Id          int
+	BuildTypeId string
+	BranchName  string
+	Position    int
+	WaitReason  string
+	QueuedDate  time.Time
+}
+
+// BuildQueue is implemented by providers that can list the builds waiting for an agent
+type BuildQueue interface {
+	GetQueuedBuilds() ([]QueuedBuild, error)
+}
@@ -0,0 +1,40 @@
+package ci
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrCircuitOpen is returned for a command the monitor skipped while its server can not be reached
+	ErrCircuitOpen = errors.New("the server can not be reached")
+
+	// ErrDashboardNotFound is returned when a dashboard to sync does not exist
+	ErrDashboardNotFound = errors.New("the dashboard was not found")
+
+	// ErrBuildTypeNotFound is returned when a build type to sync does not exist or belongs to another monitor
+	ErrBuildTypeNotFound = errors.New("the build type was not found")
+)
+
+// MonitorStatus is what the api reports about each monitor
+type MonitorStatus struct {
+	Source   string         `json:"source"`
+	LastSync *SyncSummary   `json:"lastSync"`
+	Breaker  *BreakerStatus `json:"breaker,omitempty"`
+}
+
+// SyncSummary is the outcome of one build history sync. A build type that failed is listed in
+// Errors and left as it was, the others are still synced.
+type SyncSummary struct {
+	Full       bool        `json:"full"`
+	StartedAt  time.Time   `json:"startedAt"`
+	FinishedAt time.Time   `json:"finishedAt"`
+	BuildTypes int         `json:"buildTypes"`
+	Synced     int         `json:"synced"`
+	Errors     []SyncError `json:"errors"`
+}
+
+type SyncError struct {
+	BuildTypeId string `json:"buildTypeId"`
+	Message     string `json:"message"`
+}
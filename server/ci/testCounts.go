@@ -0,0 +1,16 @@
+package ci
+
+// TestCounts sums up the tests of a build. NewFailed are the failures that passed in the previous build.
+type TestCounts struct {
+	Total     int
+	Passed    int
+	Failed    int
+	NewFailed int
+	Ignored   int
+	Muted     int
+}
+
+// TestReporter is implemented by providers that can count the tests of a finished build
+type TestReporter interface {
+	GetTestCounts(b Build) (TestCounts, error)
+}
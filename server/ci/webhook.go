@@ -0,0 +1,23 @@
+package ci
+
+import "errors"
+
+var (
+	// ErrWebhooksDisabled is returned for a monitor that does not exist or has no webhook secret
+	ErrWebhooksDisabled = errors.New("webhooks are not enabled for this server")
+
+	// ErrBadSignature is returned when the payload was not signed with the shared secret
+	ErrBadSignature = errors.New("the webhook signature does not match")
+
+	// ErrWebhooksBusy is returned when the monitor has too many webhooks waiting to take another one
+	ErrWebhooksBusy = errors.New("the monitor is busy, try again later")
+
+	// ErrNotRunning is returned when a webhook or command arrives before the monitor has started or after it stopped
+	ErrNotRunning = errors.New("the monitor is not running")
+)
+
+// WebhookParser is implemented by providers that can turn the payloads their server pushes into builds.
+// ok is false for events that do not change a build, like queue or investigation notifications.
+type WebhookParser interface {
+	ParseWebhook(payload []byte) (b Build, ok bool, err error)
+}
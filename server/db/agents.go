@@ -0,0 +1,91 @@
+package db
+
+import (
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type Agent struct {
+	Id         string `bson:"_id" json:"id"`
+	Name       string `bson:"name" json:"name"`
+	Source     string `bson:"source" json:"source"`
+	PoolId     string `bson:"poolId" json:"poolId"`
+	PoolName   string `bson:"poolName" json:"poolName"`
+	Connected  bool   `bson:"connected" json:"connected"`
+	Authorized bool   `bson:"authorized" json:"authorized"`
+	Enabled    bool   `bson:"enabled" json:"enabled"`
+	// Build is the build the agent is running, it is nil while the agent is idle
+	Build *AgentBuild `bson:"build" json:"build"`
+}
+
+type AgentBuild struct {
+	Id          int    `json:"id"`
+	BuildTypeId string `json:"buildTypeId"`
+}
+
+func Agents(s *mgo.Session) *mgo.Collection {
+	return s.DB("").C("agents")
+}
+
+func (appDb *AppDb) UpsertAgent(r Agent) (*Agent, error) {
+	now := appDb.now()
+
+	change := mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{
+				"modifiedAt": now,
+				"name":       r.Name,
+				"source":     r.Source,
+				"poolId":     r.PoolId,
+				"poolName":   r.PoolName,
+				"connected":  r.Connected,
+				"authorized": r.Authorized,
+				"enabled":    r.Enabled,
+				"build":      r.Build,
+			},
+			"$unset":       bson.M{"deleted": ""},
+			"$setOnInsert": bson.M{"createdAt": now},
+		},
+		Upsert:    true,
+		ReturnNew: true,
+	}
+
+	var agent Agent
+	_, err := Agents(appDb.Session).Find(bson.M{
+		"_id": r.Id,
+	}).Apply(change, &agent)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &agent, nil
+}
+
+func (appDb *AppDb) DeleteAgent(id string) error {
+	return appDb.Delete(Agents(appDb.Session), id)
+}
+
+// AgentList returns the agents grouped by pool
+func (appDb *AppDb) AgentList() ([]Agent, error) {
+	var agentList []Agent
+
+	if err := Agents(appDb.Session).
+		Find(bson.M{"deleted": bson.M{"$exists": false}}).
+		Sort("poolName", "name").
+		Select(bson.M{
+			"_id":        1,
+			"name":       1,
+			"source":     1,
+			"poolId":     1,
+			"poolName":   1,
+			"connected":  1,
+			"authorized": 1,
+			"enabled":    1,
+			"build":      1,
+		}).All(&agentList); err != nil {
+		return nil, err
+	}
+
+	return agentList, nil
+}
@@ -0,0 +1,84 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"build-monitor-v2/server/cfg"
+	"build-monitor-v2/server/db"
+
+	"github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestAppDb_UpsertAgent(t *testing.T) {
+	Convey("Given an AppDb", t, func() {
+		c := cfg.Config{PasswordSalt: "something here"}
+		log := logrus.WithField("test", "TestAppDb_UpsertAgent")
+
+		appDb := db.Create(dbSession, &c, log, time.Now)
+
+		agent := db.Agent{
+			Id:        "TestAppDb_UpsertAgent-a01",
+			Name:      "linux-01",
+			PoolName:  "Default",
+			Connected: true,
+			Build:     &db.AgentBuild{Id: 512, BuildTypeId: "bt1"},
+		}
+
+		Convey("When an agent is inserted into the db", func() {
+			result, err := appDb.UpsertAgent(agent)
+
+			Convey("It should not error and return the object", func() {
+				So(err, ShouldBeNil)
+				So(result.Id, ShouldEqual, agent.Id)
+				So(*result.Build, ShouldResemble, *agent.Build)
+			})
+		})
+
+		Convey("When the agent finishes its build and disconnects", func() {
+			agent.Connected = false
+			agent.Build = nil
+
+			result, err := appDb.UpsertAgent(agent)
+
+			Convey("It should clear the build", func() {
+				So(err, ShouldBeNil)
+				So(result.Connected, ShouldBeFalse)
+				So(result.Build, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestAppDb_AgentList(t *testing.T) {
+	Convey("Given an appDb", t, func() {
+		c := cfg.Config{PasswordSalt: "something here"}
+		log := logrus.WithField("test", "TestAppDb_AgentList")
+
+		db.Agents(dbSession).RemoveAll(bson.M{})
+
+		appDb := db.Create(dbSession, &c, log, time.Now)
+
+		a1, _ := appDb.UpsertAgent(db.Agent{Id: "TestAppDb_AgentList-a01", Name: "mac-01", PoolName: "macOS"})
+		a2, _ := appDb.UpsertAgent(db.Agent{Id: "TestAppDb_AgentList-a02", Name: "linux-02", PoolName: "Default"})
+		a3, _ := appDb.UpsertAgent(db.Agent{Id: "TestAppDb_AgentList-a03", Name: "linux-01", PoolName: "Default"})
+		a4, _ := appDb.UpsertAgent(db.Agent{Id: "TestAppDb_AgentList-a04", Name: "gone", PoolName: "Default"})
+
+		err := appDb.DeleteAgent(a4.Id)
+		So(err, ShouldBeNil)
+
+		Convey("When AgentList is called", func() {
+			agents, alErr := appDb.AgentList()
+			So(alErr, ShouldBeNil)
+
+			Convey("It should return all non-deleted agents by pool and name", func() {
+				So(len(agents), ShouldEqual, 3)
+				So(agents[0].Id, ShouldEqual, a3.Id)
+				So(agents[1].Id, ShouldEqual, a2.Id)
+				So(agents[2].Id, ShouldEqual, a1.Id)
+			})
+		})
+	})
+}
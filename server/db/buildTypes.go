@@ -3,7 +3,6 @@ package db
 import (
 	"time"
 
-	"github.com/pstuart2/go-teamcity"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -13,27 +12,109 @@ type BuildType struct {
 	Name         string   `bson:"name" json:"name"`
 	Description  string   `bson:"description" json:"description"`
 	ProjectID    string   `bson:"projectId" json:"projectId"`
+	Source       string   `bson:"source" json:"source"`
 	IsRunning    bool     `bson:"isRunning" json:"isRunning"`
 	Branches     []Branch `bson:"branches" json:"branches"`
 	DashboardIds []string `bson:"dashboardIds" json:"dashboardIds"`
+	// Paused build types will not start builds, which includes the ones in an archived project
+	Paused bool `bson:"paused" json:"paused"`
+	// Dependencies are the build types this one has a snapshot dependency on
+	Dependencies []string `bson:"dependencies" json:"dependencies"`
+	// LastBuildId is where the next incremental history sync starts from
+	LastBuildId int `bson:"lastBuildId" json:"-"`
+	// Investigation is set while someone has taken responsibility for the build type's failures
+	Investigation *Investigation `bson:"investigation" json:"investigation"`
+	MutedTests    []MutedTest    `bson:"mutedTests" json:"mutedTests"`
+}
+
+type Investigation struct {
+	Assignee string    `json:"assignee"`
+	State    string    `json:"state"`
+	Comment  string    `json:"comment"`
+	Since    time.Time `json:"since"`
+}
+
+type MutedTest struct {
+	Name    string `json:"name"`
+	MutedBy string `json:"mutedBy"`
+	Comment string `json:"comment"`
 }
 
 type Branch struct {
 	Name      string  `bson:"name" json:"name"`
 	IsRunning bool    `bson:"isRunning" json:"isRunning"`
 	Builds    []Build `bson:"builds" json:"builds"`
+	// Queued are the builds of the branch waiting to start, first in the queue first
+	Queued []QueuedBuild `bson:"queued" json:"queued"`
 }
 
 type Build struct {
-	Id         int                  `json:"id"`
-	Number     string               `json:"number"`
-	Status     teamcity.BuildStatus `json:"status"`
-	StatusText string               `json:"statusText"`
-	Progress   int                  `json:"progress"`
-	StartDate  time.Time            `json:"startDate"`
-	FinishDate time.Time            `json:"finishDate"`
+	Id         int         `json:"id"`
+	Number     string      `json:"number"`
+	Status     BuildStatus `json:"status"`
+	StatusText string      `json:"statusText"`
+	Progress   int         `json:"progress"`
+	StartDate  time.Time   `json:"startDate"`
+	FinishDate time.Time   `json:"finishDate"`
+	// Kind tells personal, canceled and failed to start builds apart, dashboards have policies for them
+	Kind BuildKind `json:"kind"`
+	// EstimatedFinishDate is set for running builds, by the provider or from the recent builds of the branch
+	EstimatedFinishDate time.Time `json:"estimatedFinishDate"`
+	// Overtime is worked out when a dashboard is served, it is set once a running build is past its estimate
+	Overtime bool `bson:"-" json:"overtime"`
+	// Changes are only filled in for the builds since the last successful one on the branch
+	Changes []Change `json:"changes"`
+	// Tests are counted for the newest finished build on the branch, they are nil until then
+	Tests *TestCounts `json:"tests"`
+}
+
+// TestCounts sums up the tests of a build, NewFailed are the failures that passed in the previous build on the branch
+type TestCounts struct {
+	Total     int `json:"total"`
+	Passed    int `json:"passed"`
+	Failed    int `json:"failed"`
+	NewFailed int `json:"newFailed"`
+	Ignored   int `json:"ignored"`
+	Muted     int `json:"muted"`
 }
 
+type Change struct {
+	Revision  string `json:"revision"`
+	Author    string `json:"author"`
+	Comment   string `json:"comment"`
+	FileCount int    `json:"fileCount"`
+}
+
+type QueuedBuild struct {
+	Id         int       `json:"id"`
+	Position   int       `json:"position"`
+	WaitReason string    `json:"waitReason"`
+	QueuedDate time.Time `json:"queuedDate"`
+}
+
+// BuildStatus is the provider neutral state of a build. The values are stored
+// in the database and sent to the client so they must not be reordered.
+type BuildStatus int
+
+const (
+	// StatusUnknown is for finished builds that neither passed nor failed, like canceled ones, the dashboard
+	// shows them grey and they do not change the status of the branch
+	StatusUnknown BuildStatus = iota
+	StatusSuccess
+	StatusRunning
+	StatusFailure
+)
+
+// BuildKind marks the builds that did not run the build type as configured, the normal kind is empty
+type BuildKind string
+
+const (
+	KindNormal        BuildKind = ""
+	KindPersonal      BuildKind = "personal"
+	KindCanceled      BuildKind = "canceled"
+	KindFailedToStart BuildKind = "failedToStart"
+)
+
 func BuildTypes(s *mgo.Session) *mgo.Collection {
 	return s.DB("").C("buildTypes")
 }
@@ -44,10 +125,13 @@ func (appDb *AppDb) UpsertBuildType(r BuildType) (*BuildType, error) {
 	change := mgo.Change{
 		Update: bson.M{
 			"$set": bson.M{
-				"modifiedAt":  now,
-				"name":        r.Name,
-				"description": r.Description,
-				"projectId":   r.ProjectID,
+				"modifiedAt":   now,
+				"name":         r.Name,
+				"description":  r.Description,
+				"projectId":    r.ProjectID,
+				"source":       r.Source,
+				"paused":       r.Paused,
+				"dependencies": r.Dependencies,
 			},
 			"$unset":       bson.M{"deleted": ""},
 			"$setOnInsert": bson.M{"createdAt": now},
@@ -96,6 +180,47 @@ func (appDb *AppDb) UpdateBuildTypeBuilds(buildTypeId string, branches []Branch)
 	return &buildType, nil
 }
 
+// UpdateBuildTypeHistory stores a synced build history along with the build the next sync starts after
+func (appDb *AppDb) UpdateBuildTypeHistory(buildTypeId string, branches []Branch, lastBuildId int) (*BuildType, error) {
+	now := appDb.now()
+
+	change := mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{
+				"modifiedAt":  now,
+				"branches":    branches,
+				"isRunning":   isRunning(branches),
+				"lastBuildId": lastBuildId,
+			},
+			"$unset": bson.M{"deleted": ""},
+		},
+		Upsert:    false,
+		ReturnNew: true,
+	}
+
+	var buildType BuildType
+	_, err := BuildTypes(appDb.Session).Find(bson.M{
+		"_id": buildTypeId,
+	}).Apply(change, &buildType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &buildType, nil
+}
+
+// UpdateBuildTypeInvestigation replaces who is investigating the build type and its muted tests,
+// a nil investigation clears a resolved one
+func (appDb *AppDb) UpdateBuildTypeInvestigation(buildTypeId string, investigation *Investigation, mutedTests []MutedTest) error {
+	now := appDb.now()
+
+	selector := bson.M{"_id": buildTypeId}
+	update := bson.M{"$set": bson.M{"modifiedAt": now, "investigation": investigation, "mutedTests": mutedTests}}
+
+	return BuildTypes(appDb.Session).Update(selector, update)
+}
+
 func isRunning(branches []Branch) bool {
 	for _, b := range branches {
 		if b.IsRunning {
@@ -146,11 +271,15 @@ func (appDb *AppDb) BuildTypeList() ([]BuildType, error) {
 		Find(bson.M{"deleted": bson.M{"$exists": false}}).
 		Sort("name").
 		Select(bson.M{
-			"_id":         1,
-			"name":        1,
-			"description": 1,
-			"projectId":   1,
-			"paused":      1,
+			"_id":           1,
+			"name":          1,
+			"description":   1,
+			"projectId":     1,
+			"paused":        1,
+			"source":        1,
+			"lastBuildId":   1,
+			"investigation": 1,
+			"mutedTests":    1,
 		}).All(&buildTypeList); err != nil {
 		return nil, err
 	}
@@ -7,7 +7,6 @@ import (
 	"build-monitor-v2/server/cfg"
 	"build-monitor-v2/server/db"
 
-	"github.com/pstuart2/go-teamcity"
 	"github.com/sirupsen/logrus"
 	. "github.com/smartystreets/goconvey/convey"
 	"gopkg.in/mgo.v2/bson"
@@ -59,9 +58,9 @@ func TestAppDb_UpsertBuildType(t *testing.T) {
 			bt, _ := appDb.UpsertBuildType(buildType)
 
 			builds := []db.Build{
-				{Id: 908, Number: "BT-51", Status: teamcity.StatusSuccess, StatusText: "this was the last", Progress: 100},
-				{Id: 905, Number: "BT-41", Status: teamcity.StatusFailure, StatusText: "dddd", Progress: 88},
-				{Id: 901, Number: "BT-31", Status: teamcity.StatusSuccess, StatusText: "ffff", Progress: 55},
+				{Id: 908, Number: "BT-51", Status: db.StatusSuccess, StatusText: "this was the last", Progress: 100},
+				{Id: 905, Number: "BT-41", Status: db.StatusFailure, StatusText: "dddd", Progress: 88},
+				{Id: 901, Number: "BT-31", Status: db.StatusSuccess, StatusText: "ffff", Progress: 55},
 			}
 
 			branches := []db.Branch{
@@ -107,9 +106,9 @@ func TestAppDb_UpdateBuildTypeBuilds(t *testing.T) {
 			Convey("When we add builds to the build type", func() {
 
 				builds := []db.Build{
-					{Id: 908, Number: "BT-51", Status: teamcity.StatusSuccess, StatusText: "this was the last", Progress: 100},
-					{Id: 905, Number: "BT-41", Status: teamcity.StatusFailure, StatusText: "dddd", Progress: 88},
-					{Id: 901, Number: "BT-31", Status: teamcity.StatusSuccess, StatusText: "ffff", Progress: 55},
+					{Id: 908, Number: "BT-51", Status: db.StatusSuccess, StatusText: "this was the last", Progress: 100},
+					{Id: 905, Number: "BT-41", Status: db.StatusFailure, StatusText: "dddd", Progress: 88},
+					{Id: 901, Number: "BT-31", Status: db.StatusSuccess, StatusText: "ffff", Progress: 55},
 				}
 
 				branches := []db.Branch{
@@ -152,6 +151,93 @@ func TestAppDb_UpdateBuildTypeBuilds(t *testing.T) {
 	})
 }
 
+func TestAppDb_UpdateBuildTypeHistory(t *testing.T) {
+	Convey("Given an appDb", t, func() {
+		c := cfg.Config{PasswordSalt: "something here"}
+		log := logrus.WithField("test", "TestAppDb_UpdateBuildTypeHistory")
+
+		appDb := db.Create(dbSession, &c, log, time.Now)
+
+		bt, btError := appDb.UpsertBuildType(db.BuildType{Id: "Build Type Id 02", Name: "History", ProjectID: "Some project id here"})
+		So(btError, ShouldBeNil)
+
+		Convey("When we store a synced history", func() {
+			branches := []db.Branch{
+				{Name: "master", IsRunning: true, Builds: []db.Build{{Id: 912, Status: db.StatusRunning}, {Id: 908, Status: db.StatusSuccess}}},
+			}
+
+			newBt, err := appDb.UpdateBuildTypeHistory(bt.Id, branches, 908)
+
+			Convey("It should store the builds and where to sync from next", func() {
+				So(err, ShouldBeNil)
+				So(newBt.IsRunning, ShouldBeTrue)
+				So(newBt.LastBuildId, ShouldEqual, 908)
+				So(newBt.Branches[0].Builds[0].Id, ShouldEqual, 912)
+
+				buildTypes, listErr := appDb.BuildTypeList()
+				So(listErr, ShouldBeNil)
+				for _, listed := range buildTypes {
+					if listed.Id == bt.Id {
+						So(listed.LastBuildId, ShouldEqual, 908)
+					}
+				}
+			})
+		})
+	})
+}
+
+func TestAppDb_UpdateBuildTypeInvestigation(t *testing.T) {
+	Convey("Given an appDb", t, func() {
+		c := cfg.Config{PasswordSalt: "something here"}
+		log := logrus.WithField("test", "TestAppDb_UpdateBuildTypeInvestigation")
+
+		appDb := db.Create(dbSession, &c, log, time.Now)
+
+		bt, btError := appDb.UpsertBuildType(db.BuildType{Id: "Build Type Id 03", Name: "Investigated", ProjectID: "Some project id here"})
+		So(btError, ShouldBeNil)
+
+		Convey("When someone takes the investigation and mutes a test", func() {
+			since := time.Date(2017, 10, 4, 18, 30, 0, 0, time.UTC)
+			investigation := &db.Investigation{Assignee: "Alice", State: "taken", Comment: "Looking into it", Since: since}
+			muted := []db.MutedTest{{Name: "build.monitor.ClockTest.dst", MutedBy: "Bob", Comment: "Flaky"}}
+
+			err := appDb.UpdateBuildTypeInvestigation(bt.Id, investigation, muted)
+
+			Convey("It should store them on the build type", func() {
+				So(err, ShouldBeNil)
+
+				stored, findErr := appDb.FindBuildTypeById(bt.Id)
+				So(findErr, ShouldBeNil)
+				So(stored.Investigation.Assignee, ShouldEqual, "Alice")
+				So(stored.Investigation.Since.Equal(since), ShouldBeTrue)
+				So(stored.MutedTests, ShouldResemble, muted)
+				So(stored.Name, ShouldEqual, "Investigated")
+			})
+
+			Convey("And the investigation is resolved", func() {
+				err := appDb.UpdateBuildTypeInvestigation(bt.Id, nil, []db.MutedTest{})
+
+				Convey("It should clear it", func() {
+					So(err, ShouldBeNil)
+
+					stored, findErr := appDb.FindBuildTypeById(bt.Id)
+					So(findErr, ShouldBeNil)
+					So(stored.Investigation, ShouldBeNil)
+					So(len(stored.MutedTests), ShouldEqual, 0)
+				})
+			})
+		})
+
+		Convey("When the build type does not exist", func() {
+			err := appDb.UpdateBuildTypeInvestigation("not there", nil, []db.MutedTest{})
+
+			Convey("It should return an error", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
 func TestAppDb_AddRemoveDashboardFromBuildTypes(t *testing.T) {
 	Convey("Given an appDb", t, func() {
 		c := cfg.Config{PasswordSalt: "something here"}
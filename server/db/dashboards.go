@@ -17,11 +17,57 @@ type Dashboard struct {
 	CenterDateFormat string        `bson:"centerDateFormat" json:"centerDateFormat"`
 	RightDateFormat  string        `bson:"rightDateFormat" json:"rightDateFormat"`
 	BuildConfigs     []BuildConfig `bson:"buildConfigs" json:"buildConfigs"`
+	// HidePaused leaves paused build types off the dashboard instead of graying them out
+	HidePaused bool `bson:"hidePaused" json:"hidePaused"`
+	// BranchFilter is used by the build configs that do not have their own
+	BranchFilter BranchFilter `bson:"branchFilter" json:"branchFilter"`
+	// HistoryDepth is how many builds to show per branch, the server default is used when it is not set
+	HistoryDepth int `bson:"historyDepth" json:"historyDepth"`
 }
 
 type BuildConfig struct {
-	Id           string `bson:"_id" json:"id"`
-	Abbreviation string `bson:"abbreviation" json:"abbreviation"`
+	Id           string        `bson:"_id" json:"id"`
+	Abbreviation string        `bson:"abbreviation" json:"abbreviation"`
+	BranchFilter *BranchFilter `bson:"branchFilter,omitempty" json:"branchFilter,omitempty"`
+	// BuildPolicies overrides the server policies, a policy that is not set is inherited
+	BuildPolicies *BuildPolicies `bson:"buildPolicies,omitempty" json:"buildPolicies,omitempty"`
+}
+
+// BuildPolicy is how a dashboard treats a kind of build
+type BuildPolicy string
+
+const (
+	// PolicyIgnore leaves the builds off the dashboard
+	PolicyIgnore BuildPolicy = "ignore"
+	// PolicyShow shows the builds but the branch keeps the status of the newest build that counts
+	PolicyShow BuildPolicy = "show"
+	// PolicyCount treats them like every other build
+	PolicyCount BuildPolicy = "count"
+)
+
+// Valid accepts the known policies and the empty one, which means inherit
+func (p BuildPolicy) Valid() bool {
+	switch p {
+	case "", PolicyIgnore, PolicyShow, PolicyCount:
+		return true
+	}
+
+	return false
+}
+
+// BuildPolicies are the policies for the builds that are not of the normal kind
+type BuildPolicies struct {
+	Personal      BuildPolicy `bson:"personal" json:"personal"`
+	Canceled      BuildPolicy `bson:"canceled" json:"canceled"`
+	FailedToStart BuildPolicy `bson:"failedToStart" json:"failedToStart"`
+}
+
+// BranchFilter picks the branches a dashboard shows. The patterns are globs where * matches anything,
+// or regular expressions when they are wrapped in slashes like /^pull\/\d+$/.
+// A branch is shown when it matches an include, or there are none, and matches no exclude.
+type BranchFilter struct {
+	Include []string `bson:"include" json:"include"`
+	Exclude []string `bson:"exclude" json:"exclude"`
 }
 
 func Dashboards(s *mgo.Session) *mgo.Collection {
@@ -45,6 +91,9 @@ func (appDb *AppDb) UpsertDashboard(r Dashboard) (*Dashboard, error) {
 				"rightDateFormat":  r.RightDateFormat,
 				"owner":            r.Owner,
 				"buildConfigs":     r.BuildConfigs,
+				"hidePaused":       r.HidePaused,
+				"branchFilter":     r.BranchFilter,
+				"historyDepth":     r.HistoryDepth,
 			},
 			"$unset":       bson.M{"deleted": ""},
 			"$setOnInsert": bson.M{"createdAt": now},
@@ -96,6 +145,9 @@ func (appDb *AppDb) DashboardList() ([]Dashboard, error) {
 			"centerDateFormat": 1,
 			"rightDateFormat":  1,
 			"buildConfigs":     1,
+			"hidePaused":       1,
+			"branchFilter":     1,
+			"historyDepth":     1,
 		}).All(&dashboardList); err != nil {
 		return nil, err
 	}
@@ -18,6 +18,10 @@ func Ensure(session *mgo.Session, log *logrus.Entry) error {
 		return err
 	}
 
+	if err := ensureAgentCollection(Agents(session), log); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -53,6 +57,15 @@ func ensureBuildTypeCollection(c *mgo.Collection, log *logrus.Entry) error {
 	return nil
 }
 
+func ensureAgentCollection(c *mgo.Collection, log *logrus.Entry) error {
+	if err := ensureDeleted(c); err != nil {
+		log.Error("Failed calling ensureDeleted: ", err)
+		return err
+	}
+
+	return nil
+}
+
 var ensureUsername = func(c *mgo.Collection) error {
 	index := mgo.Index{
 		Key:        []string{"username"},
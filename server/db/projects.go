@@ -10,6 +10,7 @@ type Project struct {
 	Name            string `bson:"name" json:"name"`
 	Description     string `bson:"description" json:"description"`
 	ParentProjectID string `bson:"parentProjectId" json:"parentProjectId"`
+	Source          string `bson:"source" json:"source"`
 }
 
 func Projects(s *mgo.Session) *mgo.Collection {
@@ -26,6 +27,7 @@ func (appDb *AppDb) UpsertProject(r Project) (*Project, error) {
 				"name":            r.Name,
 				"description":     r.Description,
 				"parentProjectId": r.ParentProjectID,
+				"source":          r.Source,
 			},
 			"$unset":       bson.M{"deleted": ""},
 			"$setOnInsert": bson.M{"createdAt": now},
@@ -61,6 +63,7 @@ func (appDb *AppDb) ProjectList() ([]Project, error) {
 			"name":            1,
 			"description":     1,
 			"parentProjectId": 1,
+			"source":          1,
 		}).All(&projectList); err != nil {
 		return nil, err
 	}
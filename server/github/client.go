@@ -0,0 +1,183 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type repository struct {
+	FullName    string `json:"full_name"`
+	Description string `json:"description"`
+}
+
+type workflow struct {
+	Id    int    `json:"id"`
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	State string `json:"state"`
+}
+
+type workflowList struct {
+	TotalCount int        `json:"total_count"`
+	Workflows  []workflow `json:"workflows"`
+}
+
+type run struct {
+	Id           int        `json:"id"`
+	WorkflowId   int        `json:"workflow_id"`
+	RunNumber    int        `json:"run_number"`
+	HeadBranch   string     `json:"head_branch"`
+	DisplayTitle string     `json:"display_title"`
+	Status       string     `json:"status"`
+	Conclusion   string     `json:"conclusion"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	RunStartedAt *time.Time `json:"run_started_at"`
+}
+
+type runList struct {
+	TotalCount   int   `json:"total_count"`
+	WorkflowRuns []run `json:"workflow_runs"`
+}
+
+// client is a thin wrapper around the GitHub REST api that backs off when the rate limit runs out
+type client struct {
+	url   string
+	token string
+	http  *http.Client
+
+	mu           sync.Mutex
+	limitedUntil time.Time
+}
+
+func newClient(apiUrl, token string) *client {
+	return &client{
+		url:   strings.TrimSuffix(apiUrl, "/"),
+		token: token,
+		http:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *client) get(path string, query url.Values, v interface{}) error {
+	c.mu.Lock()
+	limitedUntil := c.limitedUntil
+	c.mu.Unlock()
+
+	if time.Now().Before(limitedUntil) {
+		return fmt.Errorf("github rate limit exceeded, waiting until %s", limitedUntil.Format(time.RFC3339))
+	}
+
+	u := c.url + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	c.checkRateLimit(res)
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("github returned %s for %s", res.Status, u)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// checkRateLimit stops any more calls until GitHub resets our limit, either because we have used
+// every request in the window or we were told to slow down with a Retry-After
+func (c *client) checkRateLimit(res *http.Response) {
+	var until time.Time
+
+	if retryAfter, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+		until = time.Now().Add(time.Duration(retryAfter) * time.Second)
+	} else if res.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(res.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			until = time.Unix(reset, 0)
+		}
+	}
+
+	if until.IsZero() {
+		return
+	}
+
+	c.mu.Lock()
+	c.limitedUntil = until
+	c.mu.Unlock()
+}
+
+func (c *client) repository(repo string) (repository, error) {
+	var r repository
+	err := c.get("/repos/"+repo, nil, &r)
+
+	return r, err
+}
+
+func (c *client) workflows(repo string) ([]workflow, error) {
+	all := []workflow{}
+	query := url.Values{"per_page": {"100"}}
+
+	for page := 1; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+
+		var list workflowList
+		if err := c.get("/repos/"+repo+"/actions/workflows", query, &list); err != nil {
+			return nil, err
+		}
+
+		all = append(all, list.Workflows...)
+		if len(list.Workflows) == 0 || len(all) >= list.TotalCount {
+			return all, nil
+		}
+	}
+}
+
+// runs loads the newest runs first until count have been read or there are no more
+func (c *client) runs(path string, query url.Values, count int) ([]run, error) {
+	all := []run{}
+	query.Set("per_page", "100")
+
+	for page := 1; len(all) < count; page++ {
+		query.Set("page", strconv.Itoa(page))
+
+		var list runList
+		if err := c.get(path, query, &list); err != nil {
+			return nil, err
+		}
+
+		all = append(all, list.WorkflowRuns...)
+		if len(list.WorkflowRuns) == 0 || len(all) >= list.TotalCount {
+			break
+		}
+	}
+
+	if len(all) > count {
+		return all[:count], nil
+	}
+
+	return all, nil
+}
+
+func (c *client) run(repo string, id int) (run, error) {
+	var r run
+	err := c.get(fmt.Sprintf("/repos/%s/actions/runs/%d", repo, id), nil, &r)
+
+	return r, err
+}
@@ -0,0 +1,233 @@
+package github
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+)
+
+// Source is the monitor source and id prefix used for everything that comes from GitHub
+const Source = "github"
+
+// GitHub is the ci.Provider for GitHub Actions. Each configured repository becomes a project,
+// its workflows become build types and the workflow runs are the builds on their head branches.
+type GitHub struct {
+	client *client
+	repos  []string
+
+	mu        sync.Mutex
+	workflows map[string]bool
+}
+
+// New takes the repositories to watch as owner/name
+func New(apiUrl, token string, repos []string) (*GitHub, error) {
+	if token == "" {
+		return nil, errors.New("github needs an api token")
+	}
+
+	watched := []string{}
+	for _, repo := range repos {
+		if repo = strings.TrimSpace(repo); repo != "" {
+			watched = append(watched, repo)
+		}
+	}
+
+	if len(watched) == 0 {
+		return nil, errors.New("github needs at least one repository")
+	}
+
+	return &GitHub{client: newClient(apiUrl, token), repos: watched, workflows: map[string]bool{}}, nil
+}
+
+func (g *GitHub) GetProjects() ([]db.Project, error) {
+	projects := []db.Project{{Id: Source, Name: "GitHub Actions"}}
+
+	for _, repo := range g.repos {
+		r, err := g.client.repository(repo)
+		if err != nil {
+			return nil, err
+		}
+
+		projects = append(projects, db.Project{
+			Id:              repoId(repo),
+			Name:            r.FullName,
+			Description:     r.Description,
+			ParentProjectID: Source,
+		})
+	}
+
+	return projects, nil
+}
+
+func (g *GitHub) GetBuildTypes() ([]db.BuildType, error) {
+	buildTypes := []db.BuildType{}
+	ids := map[string]bool{}
+
+	for _, repo := range g.repos {
+		workflows, err := g.client.workflows(repo)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, w := range workflows {
+			if w.State != "active" {
+				continue
+			}
+
+			bt := db.BuildType{
+				Id:          workflowId(repo, w.Id),
+				Name:        w.Name,
+				Description: w.Path,
+				ProjectID:   repoId(repo),
+			}
+
+			buildTypes = append(buildTypes, bt)
+			ids[bt.Id] = true
+		}
+	}
+
+	g.mu.Lock()
+	g.workflows = ids
+	g.mu.Unlock()
+
+	return buildTypes, nil
+}
+
+func (g *GitHub) GetBuildsForBuildType(id string, count int) ([]ci.Build, error) {
+	repo, workflow := parseId(id)
+
+	runs, err := g.client.runs("/repos/"+repo+"/actions/workflows/"+workflow+"/runs", url.Values{}, count)
+	if err != nil {
+		return nil, err
+	}
+
+	builds := []ci.Build{}
+	for _, r := range runs {
+		builds = append(builds, runToCi(id, r))
+	}
+
+	return builds, nil
+}
+
+// runningStatuses are the statuses of runs that have not completed, GitHub only filters on one status per call
+var runningStatuses = []string{"in_progress", "queued", "waiting", "requested", "pending"}
+
+// GetRunningBuilds lists the runs of each repository that have not completed, one call per status and repository
+// keeps us well inside the rate limit. A run that moves on between the calls is only listed once.
+func (g *GitHub) GetRunningBuilds() ([]ci.Build, error) {
+	g.mu.Lock()
+	workflows := g.workflows
+	g.mu.Unlock()
+
+	builds := []ci.Build{}
+	seen := make(map[int]bool)
+	for _, repo := range g.repos {
+		for _, status := range runningStatuses {
+			runs, err := g.client.runs("/repos/"+repo+"/actions/runs", url.Values{"status": {status}}, 100)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, r := range runs {
+				id := workflowId(repo, r.WorkflowId)
+				if workflows[id] && !seen[r.Id] {
+					seen[r.Id] = true
+					builds = append(builds, runToCi(id, r))
+				}
+			}
+		}
+	}
+
+	return builds, nil
+}
+
+func (g *GitHub) GetBuild(b ci.Build) (ci.Build, error) {
+	repo, _ := parseId(b.BuildTypeId)
+
+	r, err := g.client.run(repo, b.Id)
+	if err != nil {
+		return ci.Build{}, err
+	}
+
+	return runToCi(b.BuildTypeId, r), nil
+}
+
+func runToCi(buildTypeId string, r run) ci.Build {
+	build := ci.Build{
+		Id:          r.Id,
+		BuildTypeId: buildTypeId,
+		BranchName:  r.HeadBranch,
+		Number:      strconv.Itoa(r.RunNumber),
+		Status:      StatusToDb(r.Status, r.Conclusion),
+		Kind:        KindToDb(r.Status, r.Conclusion),
+		StatusText:  r.DisplayTitle,
+		StartDate:   r.CreatedAt,
+	}
+
+	if r.RunStartedAt != nil {
+		build.StartDate = *r.RunStartedAt
+	}
+
+	if r.Status == "completed" {
+		build.Progress = 100
+		build.FinishDate = r.UpdatedAt
+	}
+
+	return build
+}
+
+// StatusToDb treats every run that has not completed as running, queued runs included. Successful and neutral
+// runs passed, cancelled, skipped and stale runs are unknown and any other conclusion fails.
+func StatusToDb(status, conclusion string) db.BuildStatus {
+	if status != "completed" {
+		return db.StatusRunning
+	}
+
+	switch conclusion {
+	case "success", "neutral":
+		return db.StatusSuccess
+	case "cancelled", "skipped", "stale":
+		return db.StatusUnknown
+	}
+
+	return db.StatusFailure
+}
+
+// KindToDb marks the runs that did not run to the end as canceled and the ones that could not start as failed to start
+func KindToDb(status, conclusion string) db.BuildKind {
+	if status != "completed" {
+		return db.KindNormal
+	}
+
+	switch conclusion {
+	case "cancelled", "skipped", "stale":
+		return db.KindCanceled
+	case "startup_failure":
+		return db.KindFailedToStart
+	}
+
+	return db.KindNormal
+}
+
+func repoId(repo string) string {
+	return Source + ":" + strings.Replace(repo, "/", ":", -1)
+}
+
+func workflowId(repo string, workflow int) string {
+	return repoId(repo) + ":" + strconv.Itoa(workflow)
+}
+
+// parseId splits github:owner:name:workflow back into owner/name and the workflow id
+func parseId(id string) (string, string) {
+	parts := strings.Split(strings.TrimPrefix(id, Source+":"), ":")
+	if len(parts) != 3 {
+		return "", ""
+	}
+
+	return parts[0] + "/" + parts[1], parts[2]
+}
@@ -0,0 +1,238 @@
+package github_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"build-monitor-v2/server/db"
+	"build-monitor-v2/server/github"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var fixtures = map[string]string{
+	"/repos/acme/widgets":                                 "repo.json",
+	"/repos/acme/widgets/actions/workflows":               "workflows.json",
+	"/repos/acme/widgets/actions/workflows/161335/runs":   "ci-runs.json",
+	"/repos/acme/widgets/actions/runs?status=in_progress": "running-runs.json",
+	"/repos/acme/widgets/actions/runs?status=queued":      "queued-runs.json",
+	"/repos/acme/widgets/actions/runs?status=waiting":     "no-runs.json",
+	"/repos/acme/widgets/actions/runs?status=requested":   "no-runs.json",
+	"/repos/acme/widgets/actions/runs?status=pending":     "no-runs.json",
+	"/repos/acme/widgets/actions/runs/30433642":           "run-30433642.json",
+}
+
+// newGitHubServer replays the recorded GitHub api responses in testdata, remaining is the rate limit left after each call
+func newGitHubServer(calls *int, remaining func() int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+
+		if r.Header.Get("Authorization") != "Bearer secret-token" {
+			http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+
+		key := r.URL.Path
+		if status := r.URL.Query().Get("status"); status != "" {
+			key += "?status=" + status
+		}
+
+		file, ok := fixtures[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		body, err := ioutil.ReadFile("testdata/" + file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining()))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.Write(body)
+	}))
+}
+
+func plenty() int { return 4999 }
+
+func TestNew(t *testing.T) {
+	Convey("Given no token", t, func() {
+		_, err := github.New("https://api.github.com", "", []string{"acme/widgets"})
+
+		Convey("It should return an error", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given no repositories", t, func() {
+		_, err := github.New("https://api.github.com", "secret-token", []string{" "})
+
+		Convey("It should return an error", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestGitHub_GetProjects_GetBuildTypes(t *testing.T) {
+	Convey("Given a repository with workflows", t, func() {
+		calls := 0
+		server := newGitHubServer(&calls, plenty)
+		defer server.Close()
+
+		g, _ := github.New(server.URL, "secret-token", []string{"acme/widgets"})
+
+		Convey("When GetProjects is called", func() {
+			projects, err := g.GetProjects()
+
+			Convey("It should map the repositories to projects", func() {
+				So(err, ShouldBeNil)
+				So(projects, ShouldResemble, []db.Project{
+					{Id: "github", Name: "GitHub Actions"},
+					{Id: "github:acme:widgets", Name: "acme/widgets", Description: "Open source widgets", ParentProjectID: "github"},
+				})
+			})
+		})
+
+		Convey("When GetBuildTypes is called", func() {
+			buildTypes, err := g.GetBuildTypes()
+
+			Convey("It should map the active workflows to build types", func() {
+				So(err, ShouldBeNil)
+				So(buildTypes, ShouldResemble, []db.BuildType{
+					{Id: "github:acme:widgets:161335", Name: "CI", Description: ".github/workflows/ci.yml", ProjectID: "github:acme:widgets"},
+				})
+			})
+		})
+
+		Convey("When the token is wrong", func() {
+			g, _ := github.New(server.URL, "wrong", []string{"acme/widgets"})
+			_, err := g.GetProjects()
+
+			Convey("It should return the error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "401")
+			})
+		})
+	})
+}
+
+func TestGitHub_Builds(t *testing.T) {
+	Convey("Given a workflow with runs", t, func() {
+		calls := 0
+		server := newGitHubServer(&calls, plenty)
+		defer server.Close()
+
+		g, _ := github.New(server.URL, "secret-token", []string{"acme/widgets"})
+
+		Convey("When GetBuildsForBuildType is called", func() {
+			builds, err := g.GetBuildsForBuildType("github:acme:widgets:161335", 50)
+
+			Convey("It should map the runs with their head branches", func() {
+				So(err, ShouldBeNil)
+				So(len(builds), ShouldEqual, 3)
+
+				So(builds[0].Id, ShouldEqual, 30433642)
+				So(builds[0].Number, ShouldEqual, "562")
+				So(builds[0].BranchName, ShouldEqual, "feature/dark-mode")
+				So(builds[0].Status, ShouldEqual, db.StatusRunning)
+				So(builds[0].StartDate, ShouldResemble, time.Date(2017, 10, 4, 18, 25, 10, 0, time.UTC))
+				So(builds[0].FinishDate.IsZero(), ShouldBeTrue)
+
+				So(builds[1].BranchName, ShouldEqual, "main")
+				So(builds[1].Status, ShouldEqual, db.StatusSuccess)
+				So(builds[1].Progress, ShouldEqual, 100)
+				So(builds[1].FinishDate, ShouldResemble, time.Date(2017, 10, 4, 18, 24, 0, 0, time.UTC))
+
+				So(builds[2].Status, ShouldEqual, db.StatusFailure)
+			})
+		})
+
+		Convey("When GetRunningBuilds is called after the build types are loaded", func() {
+			_, btErr := g.GetBuildTypes()
+			So(btErr, ShouldBeNil)
+
+			builds, err := g.GetRunningBuilds()
+
+			Convey("It should return the runs of known workflows that have not completed, queued ones included", func() {
+				So(err, ShouldBeNil)
+				So(len(builds), ShouldEqual, 2)
+				So(builds[0].Id, ShouldEqual, 30433642)
+				So(builds[0].BuildTypeId, ShouldEqual, "github:acme:widgets:161335")
+				So(builds[1].Id, ShouldEqual, 30433660)
+				So(builds[1].Status, ShouldEqual, db.StatusRunning)
+				So(builds[1].StartDate, ShouldResemble, time.Date(2017, 10, 4, 18, 27, 0, 0, time.UTC))
+			})
+
+			Convey("And GetBuild is called once it has finished", func() {
+				build, err := g.GetBuild(builds[0])
+
+				Convey("It should re-read the run", func() {
+					So(err, ShouldBeNil)
+					So(build.Status, ShouldEqual, db.StatusUnknown)
+					So(build.Kind, ShouldEqual, db.KindCanceled)
+					So(build.FinishDate, ShouldResemble, time.Date(2017, 10, 4, 18, 29, 0, 0, time.UTC))
+				})
+			})
+		})
+	})
+}
+
+func TestGitHub_RateLimit(t *testing.T) {
+	Convey("Given GitHub reports the rate limit is used up", t, func() {
+		calls := 0
+		server := newGitHubServer(&calls, func() int { return 0 })
+		defer server.Close()
+
+		g, _ := github.New(server.URL, "secret-token", []string{"acme/widgets"})
+
+		_, firstErr := g.GetProjects()
+		So(firstErr, ShouldBeNil)
+
+		Convey("When another call is made before the reset", func() {
+			_, err := g.GetBuildTypes()
+
+			Convey("It should not call GitHub and return an error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "rate limit")
+				So(calls, ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestStatusToDb(t *testing.T) {
+	Convey("Given GitHub run statuses and conclusions", t, func() {
+		cases := []struct {
+			status, conclusion string
+			expected           db.BuildStatus
+			kind               db.BuildKind
+		}{
+			{"queued", "", db.StatusRunning, db.KindNormal},
+			{"in_progress", "", db.StatusRunning, db.KindNormal},
+			{"completed", "success", db.StatusSuccess, db.KindNormal},
+			{"completed", "neutral", db.StatusSuccess, db.KindNormal},
+			{"completed", "failure", db.StatusFailure, db.KindNormal},
+			{"completed", "timed_out", db.StatusFailure, db.KindNormal},
+			{"completed", "action_required", db.StatusFailure, db.KindNormal},
+			{"completed", "startup_failure", db.StatusFailure, db.KindFailedToStart},
+			{"completed", "cancelled", db.StatusUnknown, db.KindCanceled},
+			{"completed", "skipped", db.StatusUnknown, db.KindCanceled},
+			{"completed", "stale", db.StatusUnknown, db.KindCanceled},
+			{"completed", "something_new", db.StatusFailure, db.KindNormal},
+		}
+
+		Convey("It should map them to the db statuses and kinds", func() {
+			for _, c := range cases {
+				So(github.StatusToDb(c.status, c.conclusion), ShouldEqual, c.expected)
+				So(github.KindToDb(c.status, c.conclusion), ShouldEqual, c.kind)
+			}
+		})
+	})
+}
@@ -0,0 +1,165 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type group struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	FullPath    string `json:"full_path"`
+	Description string `json:"description"`
+}
+
+type project struct {
+	Id                int    `json:"id"`
+	Name              string `json:"name"`
+	Description       string `json:"description"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	Namespace         struct {
+		Kind     string `json:"kind"`
+		FullPath string `json:"full_path"`
+	} `json:"namespace"`
+}
+
+type pipeline struct {
+	Id         int        `json:"id"`
+	Iid        int        `json:"iid"`
+	Ref        string     `json:"ref"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// client is a thin wrapper around the GitLab v4 api
+type client struct {
+	url   string
+	token string
+	http  *http.Client
+}
+
+func newClient(baseUrl, token string) *client {
+	return &client{
+		url:   strings.TrimSuffix(baseUrl, "/") + "/api/v4",
+		token: token,
+		http:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// get decodes one page into v and returns the next page number, blank on the last page
+func (c *client) get(path string, query url.Values, v interface{}) (string, error) {
+	u := c.url + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("PRIVATE-TOKEN", c.token)
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("gitlab returned %s for %s", res.Status, u)
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return "", err
+	}
+
+	return res.Header.Get("X-Next-Page"), nil
+}
+
+func (c *client) groups() ([]group, error) {
+	all := []group{}
+	query := url.Values{"per_page": {"100"}}
+
+	for page := "1"; page != ""; {
+		query.Set("page", page)
+
+		var groups []group
+		next, err := c.get("/groups", query, &groups)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, groups...)
+		page = next
+	}
+
+	return all, nil
+}
+
+func (c *client) projects() ([]project, error) {
+	all := []project{}
+	query := url.Values{"per_page": {"100"}, "membership": {"true"}, "archived": {"false"}}
+
+	for page := "1"; page != ""; {
+		query.Set("page", page)
+
+		var projects []project
+		next, err := c.get("/projects", query, &projects)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, projects...)
+		page = next
+	}
+
+	return all, nil
+}
+
+// pipelines loads the newest pipelines first until count have been read or there are no more
+func (c *client) pipelines(projectPath string, query url.Values, count int) ([]pipeline, error) {
+	all := []pipeline{}
+	query.Set("per_page", "100")
+	query.Set("order_by", "id")
+	query.Set("sort", "desc")
+
+	for page := "1"; page != "" && len(all) < count; {
+		query.Set("page", page)
+
+		var pipelines []pipeline
+		next, err := c.get(projectUrl(projectPath)+"/pipelines", query, &pipelines)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, pipelines...)
+		page = next
+	}
+
+	if len(all) > count {
+		return all[:count], nil
+	}
+
+	return all, nil
+}
+
+func (c *client) pipeline(projectPath string, id int) (pipeline, error) {
+	var p pipeline
+	_, err := c.get(fmt.Sprintf("%s/pipelines/%d", projectUrl(projectPath), id), nil, &p)
+
+	return p, err
+}
+
+// projectUrl addresses a project by its url encoded path so we never need GitLab's numeric ids
+func projectUrl(projectPath string) string {
+	return "/projects/" + url.PathEscape(projectPath)
+}
@@ -0,0 +1,259 @@
+package gitlab
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+)
+
+// Source is the monitor source and id prefix used for everything that comes from GitLab
+const Source = "gitlab"
+
+// GitLab is the ci.Provider for GitLab CI. Groups become projects, each repository's pipeline
+// becomes a build type and the pipeline refs become its branches.
+type GitLab struct {
+	client *client
+
+	mu         sync.Mutex
+	buildTypes []string
+	// onDashboards are the ids of the build types on a dashboard, only they are asked for running pipelines
+	onDashboards map[string]bool
+}
+
+func New(baseUrl, token string) (*GitLab, error) {
+	if token == "" {
+		return nil, errors.New("gitlab needs a private token")
+	}
+
+	return &GitLab{client: newClient(baseUrl, token)}, nil
+}
+
+func (g *GitLab) GetProjects() ([]db.Project, error) {
+	groups, err := g.client.groups()
+	if err != nil {
+		return nil, err
+	}
+
+	projects := []db.Project{{Id: Source, Name: "GitLab", Description: g.client.url}}
+	for _, gr := range groups {
+		projects = append(projects, db.Project{
+			Id:              idFromPath(gr.FullPath),
+			Name:            gr.Name,
+			Description:     gr.Description,
+			ParentProjectID: parentId(gr.FullPath),
+		})
+	}
+
+	return projects, nil
+}
+
+func (g *GitLab) GetBuildTypes() ([]db.BuildType, error) {
+	projects, err := g.client.projects()
+	if err != nil {
+		return nil, err
+	}
+
+	buildTypes := []db.BuildType{}
+	ids := []string{}
+	for _, p := range projects {
+		projectId := Source
+		if p.Namespace.Kind == "group" {
+			projectId = idFromPath(p.Namespace.FullPath)
+		}
+
+		bt := db.BuildType{
+			Id:          idFromPath(p.PathWithNamespace),
+			Name:        p.Name,
+			Description: p.Description,
+			ProjectID:   projectId,
+		}
+
+		buildTypes = append(buildTypes, bt)
+		ids = append(ids, bt.Id)
+	}
+
+	g.mu.Lock()
+	g.buildTypes = ids
+	g.mu.Unlock()
+
+	return buildTypes, nil
+}
+
+func (g *GitLab) GetBuildsForBuildType(id string, count int) ([]ci.Build, error) {
+	pipelines, err := g.client.pipelines(pathFromId(id), url.Values{}, count)
+	if err != nil {
+		return nil, err
+	}
+
+	builds := []ci.Build{}
+	for _, p := range pipelines {
+		builds = append(builds, pipelineToCi(id, p))
+	}
+
+	return builds, nil
+}
+
+// runningStatuses are the statuses of pipelines that have not finished, GitLab only filters on one status per call
+var runningStatuses = []string{"created", "waiting_for_resource", "preparing", "pending", "running", "scheduled"}
+
+// SetDashboardBuildTypes is called by the monitor with the ids of the build types on a dashboard
+func (g *GitLab) SetDashboardBuildTypes(ids []string) {
+	onDashboards := make(map[string]bool)
+	for _, id := range ids {
+		onDashboards[id] = true
+	}
+
+	g.mu.Lock()
+	g.onDashboards = onDashboards
+	g.mu.Unlock()
+}
+
+// GetRunningBuilds asks each repository on a dashboard for the pipelines that have not finished, since GitLab has
+// no instance wide list. A repository that can not be asked does not stop the others, its error is returned
+// in a ci.RunningBuildsError with the pipelines of the rest.
+func (g *GitLab) GetRunningBuilds() ([]ci.Build, error) {
+	g.mu.Lock()
+	ids := g.buildTypes
+	onDashboards := g.onDashboards
+	g.mu.Unlock()
+
+	builds := []ci.Build{}
+	errs := []ci.SyncError{}
+	var firstErr error
+	asked := 0
+	for _, id := range ids {
+		if !onDashboards[id] {
+			continue
+		}
+
+		asked++
+		running, err := g.runningPipelines(id)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+
+			errs = append(errs, ci.SyncError{BuildTypeId: id, Message: err.Error()})
+			continue
+		}
+
+		builds = append(builds, running...)
+	}
+
+	// When no repository answered the server is most likely down, which the monitor backs off from
+	if len(errs) > 0 && len(errs) == asked {
+		return nil, firstErr
+	}
+
+	if len(errs) > 0 {
+		return builds, &ci.RunningBuildsError{Errors: errs}
+	}
+
+	return builds, nil
+}
+
+func (g *GitLab) runningPipelines(id string) ([]ci.Build, error) {
+	builds := []ci.Build{}
+	for _, status := range runningStatuses {
+		pipelines, err := g.client.pipelines(pathFromId(id), url.Values{"status": {status}}, 100)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range pipelines {
+			builds = append(builds, pipelineToCi(id, p))
+		}
+	}
+
+	return builds, nil
+}
+
+func (g *GitLab) GetBuild(b ci.Build) (ci.Build, error) {
+	p, err := g.client.pipeline(pathFromId(b.BuildTypeId), b.Id)
+	if err != nil {
+		return ci.Build{}, err
+	}
+
+	return pipelineToCi(b.BuildTypeId, p), nil
+}
+
+func pipelineToCi(buildTypeId string, p pipeline) ci.Build {
+	number := p.Iid
+	if number == 0 {
+		number = p.Id
+	}
+
+	build := ci.Build{
+		Id:          p.Id,
+		BuildTypeId: buildTypeId,
+		BranchName:  p.Ref,
+		Number:      strconv.Itoa(number),
+		Status:      StatusToDb(p.Status),
+		Kind:        KindToDb(p.Status),
+		StatusText:  p.Status,
+		StartDate:   p.CreatedAt,
+	}
+
+	if p.StartedAt != nil {
+		build.StartDate = *p.StartedAt
+	}
+
+	if build.Status == db.StatusRunning {
+		return build
+	}
+
+	build.Progress = 100
+	build.FinishDate = p.UpdatedAt
+	if p.FinishedAt != nil {
+		build.FinishDate = *p.FinishedAt
+	}
+
+	return build
+}
+
+// StatusToDb counts a pipeline that waits on a manual job as a success, every job it ran on its own passed.
+// Pipelines that have not finished are running, canceled and skipped ones are unknown and any other status fails.
+func StatusToDb(status string) db.BuildStatus {
+	switch status {
+	case "success", "manual":
+		return db.StatusSuccess
+	case "created", "waiting_for_resource", "preparing", "pending", "running", "scheduled":
+		return db.StatusRunning
+	case "canceled", "skipped":
+		return db.StatusUnknown
+	}
+
+	return db.StatusFailure
+}
+
+// KindToDb marks the canceled and skipped pipelines as canceled so the build policies apply to them
+func KindToDb(status string) db.BuildKind {
+	if status == "canceled" || status == "skipped" {
+		return db.KindCanceled
+	}
+
+	return db.KindNormal
+}
+
+// GitLab paths cannot contain a colon so it is safe to swap the path separator for ours
+func idFromPath(path string) string {
+	return Source + ":" + strings.Replace(path, "/", ":", -1)
+}
+
+func pathFromId(id string) string {
+	return strings.Replace(strings.TrimPrefix(id, Source+":"), ":", "/", -1)
+}
+
+func parentId(fullPath string) string {
+	i := strings.LastIndex(fullPath, "/")
+	if i < 0 {
+		return Source
+	}
+
+	return idFromPath(fullPath[:i])
+}
@@ -0,0 +1,238 @@
+package gitlab_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+	"build-monitor-v2/server/gitlab"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fixture struct {
+	file     string
+	nextPage string
+}
+
+var fixtures = map[string]fixture{
+	"/api/v4/groups":                                                            {"groups.json", "2"},
+	"/api/v4/groups?page=2":                                                     {"groups-2.json", ""},
+	"/api/v4/projects":                                                          {"projects.json", ""},
+	"/api/v4/projects/platform%2Fbilling/pipelines":                             {"billing-pipelines.json", ""},
+	"/api/v4/projects/platform%2Fbilling/pipelines/1003":                        {"billing-1003.json", ""},
+	"/api/v4/projects/platform%2Fbilling/pipelines?status=created":              {"empty.json", ""},
+	"/api/v4/projects/platform%2Fbilling/pipelines?status=waiting_for_resource": {"empty.json", ""},
+	"/api/v4/projects/platform%2Fbilling/pipelines?status=preparing":            {"empty.json", ""},
+	"/api/v4/projects/platform%2Fbilling/pipelines?status=pending":              {"billing-pending.json", ""},
+	"/api/v4/projects/platform%2Fbilling/pipelines?status=running":              {"billing-running.json", ""},
+	"/api/v4/projects/platform%2Fbilling/pipelines?status=scheduled":            {"empty.json", ""},
+}
+
+// newGitLabServer replays the recorded GitLab api responses in testdata
+func newGitLabServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "secret-token" {
+			http.Error(w, `{"message":"401 Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+
+		key := r.URL.EscapedPath()
+		if page := r.URL.Query().Get("page"); page != "" && page != "1" {
+			key += "?page=" + page
+		}
+
+		if status := r.URL.Query().Get("status"); status != "" {
+			key += "?status=" + status
+		}
+
+		f, ok := fixtures[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		body, err := ioutil.ReadFile("testdata/" + f.file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Next-Page", f.nextPage)
+		w.Write(body)
+	}))
+}
+
+func TestNew(t *testing.T) {
+	Convey("Given no private token", t, func() {
+		_, err := gitlab.New("http://gitlab.local", "")
+
+		Convey("It should return an error", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestGitLab_GetProjects_GetBuildTypes(t *testing.T) {
+	Convey("Given a GitLab server with groups and repositories", t, func() {
+		server := newGitLabServer()
+		defer server.Close()
+
+		g, _ := gitlab.New(server.URL, "secret-token")
+
+		Convey("When GetProjects is called", func() {
+			projects, err := g.GetProjects()
+
+			Convey("It should map every page of groups to projects", func() {
+				So(err, ShouldBeNil)
+				So(projects, ShouldResemble, []db.Project{
+					{Id: "gitlab", Name: "GitLab", Description: server.URL + "/api/v4"},
+					{Id: "gitlab:platform", Name: "Platform", Description: "Shared platform services", ParentProjectID: "gitlab"},
+					{Id: "gitlab:platform:tools", Name: "Tools", ParentProjectID: "gitlab:platform"},
+				})
+			})
+		})
+
+		Convey("When GetBuildTypes is called", func() {
+			buildTypes, err := g.GetBuildTypes()
+
+			Convey("It should map each repository's pipeline to a build type", func() {
+				So(err, ShouldBeNil)
+				So(buildTypes, ShouldResemble, []db.BuildType{
+					{Id: "gitlab:platform:billing", Name: "Billing", Description: "Billing service", ProjectID: "gitlab:platform"},
+					{Id: "gitlab:platform:tools:lint-rules", Name: "lint-rules", ProjectID: "gitlab:platform:tools"},
+					{Id: "gitlab:jdoe:dotfiles", Name: "dotfiles", ProjectID: "gitlab"},
+				})
+			})
+		})
+
+		Convey("When the token is wrong", func() {
+			g, _ := gitlab.New(server.URL, "wrong")
+			_, err := g.GetProjects()
+
+			Convey("It should return the error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "401")
+			})
+		})
+	})
+}
+
+func TestGitLab_Builds(t *testing.T) {
+	Convey("Given a GitLab server with pipelines", t, func() {
+		server := newGitLabServer()
+		defer server.Close()
+
+		g, _ := gitlab.New(server.URL, "secret-token")
+
+		Convey("When GetBuildsForBuildType is called", func() {
+			builds, err := g.GetBuildsForBuildType("gitlab:platform:billing", 2)
+
+			Convey("It should map the newest pipelines with their refs as branches", func() {
+				So(err, ShouldBeNil)
+				So(builds, ShouldResemble, []ci.Build{
+					{
+						Id:          1003,
+						BuildTypeId: "gitlab:platform:billing",
+						BranchName:  "feature/invoices",
+						Number:      "53",
+						Status:      db.StatusRunning,
+						StatusText:  "running",
+						StartDate:   time.Date(2017, 10, 4, 18, 25, 0, 0, time.UTC),
+					},
+					{
+						Id:          1002,
+						BuildTypeId: "gitlab:platform:billing",
+						BranchName:  "master",
+						Number:      "52",
+						Status:      db.StatusSuccess,
+						StatusText:  "success",
+						Progress:    100,
+						StartDate:   time.Date(2017, 10, 4, 18, 20, 0, 0, time.UTC),
+						FinishDate:  time.Date(2017, 10, 4, 18, 24, 0, 0, time.UTC),
+					},
+				})
+			})
+		})
+
+		Convey("When GetRunningBuilds is called after the build types are loaded", func() {
+			_, btErr := g.GetBuildTypes()
+			So(btErr, ShouldBeNil)
+
+			g.SetDashboardBuildTypes([]string{"gitlab:platform:billing"})
+			builds, err := g.GetRunningBuilds()
+
+			Convey("It should return the pipelines that have not finished of the repositories on a dashboard", func() {
+				So(err, ShouldBeNil)
+				So(len(builds), ShouldEqual, 2)
+				So(builds[0].Id, ShouldEqual, 1004)
+				So(builds[0].Status, ShouldEqual, db.StatusRunning)
+				So(builds[1].Id, ShouldEqual, 1003)
+				So(builds[1].BuildTypeId, ShouldEqual, "gitlab:platform:billing")
+			})
+
+			Convey("And a repository on a dashboard can not be asked", func() {
+				g.SetDashboardBuildTypes([]string{"gitlab:platform:billing", "gitlab:platform:tools:lint-rules"})
+				builds, err := g.GetRunningBuilds()
+
+				Convey("It should return the pipelines of the others with the error of that one", func() {
+					So(len(builds), ShouldEqual, 2)
+					So(err, ShouldHaveSameTypeAs, &ci.RunningBuildsError{})
+					So(len(err.(*ci.RunningBuildsError).Errors), ShouldEqual, 1)
+					So(err.(*ci.RunningBuildsError).Errors[0].BuildTypeId, ShouldEqual, "gitlab:platform:tools:lint-rules")
+				})
+			})
+
+			Convey("And no repository on a dashboard can be asked", func() {
+				g.SetDashboardBuildTypes([]string{"gitlab:platform:tools:lint-rules"})
+				_, err := g.GetRunningBuilds()
+
+				Convey("It should return the error", func() {
+					So(err, ShouldNotBeNil)
+					So(err, ShouldNotHaveSameTypeAs, &ci.RunningBuildsError{})
+				})
+			})
+
+			Convey("And GetBuild is called once it has finished", func() {
+				build, err := g.GetBuild(builds[1])
+
+				Convey("It should use the pipeline's own start and finish times", func() {
+					So(err, ShouldBeNil)
+					So(build.Status, ShouldEqual, db.StatusUnknown)
+					So(build.Kind, ShouldEqual, db.KindCanceled)
+					So(build.StartDate, ShouldResemble, time.Date(2017, 10, 4, 18, 25, 30, 0, time.UTC))
+					So(build.FinishDate, ShouldResemble, time.Date(2017, 10, 4, 18, 30, 0, 0, time.UTC))
+				})
+			})
+		})
+	})
+}
+
+func TestStatusToDb(t *testing.T) {
+	Convey("Given GitLab pipeline statuses", t, func() {
+		Convey("It should map them to the db statuses", func() {
+			So(gitlab.StatusToDb("success"), ShouldEqual, db.StatusSuccess)
+			So(gitlab.StatusToDb("manual"), ShouldEqual, db.StatusSuccess)
+			So(gitlab.StatusToDb("created"), ShouldEqual, db.StatusRunning)
+			So(gitlab.StatusToDb("running"), ShouldEqual, db.StatusRunning)
+			So(gitlab.StatusToDb("pending"), ShouldEqual, db.StatusRunning)
+			So(gitlab.StatusToDb("failed"), ShouldEqual, db.StatusFailure)
+			So(gitlab.StatusToDb("canceled"), ShouldEqual, db.StatusUnknown)
+			So(gitlab.StatusToDb("skipped"), ShouldEqual, db.StatusUnknown)
+			So(gitlab.StatusToDb("something_new"), ShouldEqual, db.StatusFailure)
+		})
+
+		Convey("It should mark the pipelines that did not run as canceled", func() {
+			So(gitlab.KindToDb("success"), ShouldEqual, db.KindNormal)
+			So(gitlab.KindToDb("manual"), ShouldEqual, db.KindNormal)
+			So(gitlab.KindToDb("failed"), ShouldEqual, db.KindNormal)
+			So(gitlab.KindToDb("canceled"), ShouldEqual, db.KindCanceled)
+			So(gitlab.KindToDb("skipped"), ShouldEqual, db.KindCanceled)
+		})
+	})
+}
@@ -0,0 +1,125 @@
+package jenkins
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type job struct {
+	Class       string  `json:"_class"`
+	Name        string  `json:"name"`
+	DisplayName string  `json:"displayName"`
+	Description string  `json:"description"`
+	Jobs        []job   `json:"jobs"`
+	Builds      []build `json:"builds"`
+}
+
+func (j job) name() string {
+	if j.DisplayName != "" {
+		return j.DisplayName
+	}
+
+	return j.Name
+}
+
+type build struct {
+	Number            int    `json:"number"`
+	Url               string `json:"url"`
+	Building          bool   `json:"building"`
+	Result            string `json:"result"`
+	Timestamp         int64  `json:"timestamp"`
+	Duration          int64  `json:"duration"`
+	EstimatedDuration int64  `json:"estimatedDuration"`
+	Description       string `json:"description"`
+}
+
+type executor struct {
+	CurrentExecutable *build `json:"currentExecutable"`
+}
+
+type computerSet struct {
+	Computer []struct {
+		Executors       []executor `json:"executors"`
+		OneOffExecutors []executor `json:"oneOffExecutors"`
+	} `json:"computer"`
+}
+
+// client is a thin wrapper around the Jenkins JSON api
+type client struct {
+	url   string
+	user  string
+	token string
+	http  *http.Client
+}
+
+func newClient(baseUrl, user, token string) *client {
+	return &client{
+		url:   strings.TrimSuffix(baseUrl, "/"),
+		user:  user,
+		token: token,
+		http:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *client) get(u string, v interface{}) error {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.token)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("jenkins returned %s for %s", res.Status, u)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// jobPath pulls the job names out of a build url such as {url}/job/folder/job/name/12/
+func (c *client) jobPath(buildUrl string) ([]string, bool) {
+	base, err := url.Parse(c.url)
+	if err != nil {
+		return nil, false
+	}
+
+	u, err := url.Parse(buildUrl)
+	if err != nil {
+		return nil, false
+	}
+
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(u.Path, base.Path), "/"), "/")
+
+	path := []string{}
+	for i := 0; i+1 < len(segments); i += 2 {
+		if segments[i] != "job" {
+			return nil, false
+		}
+
+		path = append(path, segments[i+1])
+	}
+
+	return path, len(path) > 0
+}
+
+func jobUrl(baseUrl string, path []string) string {
+	u := baseUrl + "/"
+	for _, name := range path {
+		u += "job/" + url.PathEscape(name) + "/"
+	}
+
+	return u
+}
@@ -0,0 +1,296 @@
+package jenkins
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+)
+
+// Source is the monitor source and id prefix used for everything that comes from Jenkins
+const Source = "jenkins"
+
+const (
+	folderClass       = "com.cloudbees.hudson.plugins.folder.Folder"
+	orgFolderClass    = "jenkins.branch.OrganizationFolder"
+	multiBranchClass  = "org.jenkinsci.plugins.workflow.multibranch.WorkflowMultiBranchProject"
+	buildFields       = "number,url,building,result,timestamp,duration,estimatedDuration,description"
+	executorFields    = "currentExecutable[" + buildFields + "]"
+	runningBuildsTree = "computer[executors[" + executorFields + "],oneOffExecutors[" + executorFields + "]]"
+)
+
+// Jenkins is the ci.Provider for a Jenkins server. Folders become projects, jobs become build types
+// and the branch jobs of a multibranch pipeline become the branches of its build type.
+type Jenkins struct {
+	client *client
+
+	mu          sync.Mutex
+	multiBranch map[string]bool
+}
+
+func New(baseUrl, user, token string) *Jenkins {
+	return &Jenkins{
+		client:      newClient(baseUrl, user, token),
+		multiBranch: map[string]bool{},
+	}
+}
+
+func (j *Jenkins) GetProjects() ([]db.Project, error) {
+	projects, _, err := j.walk()
+	return projects, err
+}
+
+func (j *Jenkins) GetBuildTypes() ([]db.BuildType, error) {
+	_, buildTypes, err := j.walk()
+	return buildTypes, err
+}
+
+func (j *Jenkins) GetBuildsForBuildType(id string, count int) ([]ci.Build, error) {
+	path := pathFromId(id)
+	tree := "_class,jobs[name],builds[" + buildFields + "]{0," + strconv.Itoa(count) + "}"
+
+	var jb job
+	if err := j.client.get(jobUrl(j.client.url, path)+"api/json?tree="+url.QueryEscape(tree), &jb); err != nil {
+		return nil, err
+	}
+
+	if jb.Class != multiBranchClass {
+		return buildsToCi(id, "", jb.Builds), nil
+	}
+
+	builds := []ci.Build{}
+	for _, branch := range jb.Jobs {
+		var bj job
+		branchTree := "builds[" + buildFields + "]{0," + strconv.Itoa(count) + "}"
+		if err := j.client.get(jobUrl(j.client.url, childPath(path, branch.Name))+"api/json?tree="+url.QueryEscape(branchTree), &bj); err != nil {
+			return nil, err
+		}
+
+		builds = append(builds, buildsToCi(id, decodeBranch(branch.Name), bj.Builds)...)
+	}
+
+	return builds, nil
+}
+
+func (j *Jenkins) GetRunningBuilds() ([]ci.Build, error) {
+	var computers computerSet
+	if err := j.client.get(j.client.url+"/computer/api/json?tree="+url.QueryEscape(runningBuildsTree), &computers); err != nil {
+		return nil, err
+	}
+
+	builds := []ci.Build{}
+	for _, c := range computers.Computer {
+		for _, e := range append(c.Executors, c.OneOffExecutors...) {
+			if e.CurrentExecutable == nil || !e.CurrentExecutable.Building {
+				continue
+			}
+
+			path, ok := j.client.jobPath(e.CurrentExecutable.Url)
+			if !ok {
+				continue
+			}
+
+			buildTypeId, branch := j.buildTypeForPath(path)
+			builds = append(builds, buildToCi(buildTypeId, branch, *e.CurrentExecutable))
+		}
+	}
+
+	return builds, nil
+}
+
+func (j *Jenkins) GetBuild(b ci.Build) (ci.Build, error) {
+	path := pathFromId(b.BuildTypeId)
+	if b.BranchName != "" {
+		path = append(path, encodeBranch(b.BranchName))
+	}
+
+	var jb build
+	u := jobUrl(j.client.url, path) + strconv.Itoa(b.Id) + "/api/json?tree=" + url.QueryEscape(buildFields)
+	if err := j.client.get(u, &jb); err != nil {
+		return ci.Build{}, err
+	}
+
+	return buildToCi(b.BuildTypeId, b.BranchName, jb), nil
+}
+
+// walk loads the job tree one folder at a time, the Jenkins root itself is the top level project
+func (j *Jenkins) walk() ([]db.Project, []db.BuildType, error) {
+	projects := []db.Project{{Id: Source, Name: "Jenkins", Description: j.client.url}}
+	buildTypes := []db.BuildType{}
+	multiBranch := map[string]bool{}
+
+	folders := [][]string{{}}
+	for len(folders) > 0 {
+		path := folders[0]
+		folders = folders[1:]
+
+		var folder job
+		tree := "jobs[_class,name,displayName,description]"
+		if err := j.client.get(jobUrl(j.client.url, path)+"api/json?tree="+url.QueryEscape(tree), &folder); err != nil {
+			return nil, nil, err
+		}
+
+		parentId := idFromPath(path)
+		for _, child := range folder.Jobs {
+			jobPath := childPath(path, child.Name)
+			id := idFromPath(jobPath)
+
+			switch child.Class {
+			case folderClass, orgFolderClass:
+				projects = append(projects, db.Project{Id: id, Name: child.name(), Description: child.Description, ParentProjectID: parentId})
+				folders = append(folders, jobPath)
+			default:
+				if child.Class == multiBranchClass {
+					multiBranch[id] = true
+				}
+
+				buildTypes = append(buildTypes, db.BuildType{Id: id, Name: child.name(), Description: child.Description, ProjectID: parentId})
+			}
+		}
+	}
+
+	j.mu.Lock()
+	j.multiBranch = multiBranch
+	j.mu.Unlock()
+
+	return projects, buildTypes, nil
+}
+
+// buildTypeForPath splits the branch job off when the parent is a multibranch pipeline
+func (j *Jenkins) buildTypeForPath(path []string) (string, string) {
+	if len(path) > 1 {
+		parentId := idFromPath(path[:len(path)-1])
+
+		j.mu.Lock()
+		isMultiBranch := j.multiBranch[parentId]
+		j.mu.Unlock()
+
+		if isMultiBranch {
+			return parentId, decodeBranch(path[len(path)-1])
+		}
+	}
+
+	return idFromPath(path), ""
+}
+
+func buildsToCi(buildTypeId, branch string, builds []build) []ci.Build {
+	ciBuilds := []ci.Build{}
+	for _, b := range builds {
+		ciBuilds = append(ciBuilds, buildToCi(buildTypeId, branch, b))
+	}
+
+	return ciBuilds
+}
+
+func buildToCi(buildTypeId, branch string, b build) ci.Build {
+	start := fromMillis(b.Timestamp)
+
+	build := ci.Build{
+		Id:          b.Number,
+		BuildTypeId: buildTypeId,
+		BranchName:  branch,
+		Number:      strconv.Itoa(b.Number),
+		Status:      StatusToDb(b.Building, b.Result),
+		Kind:        KindToDb(b.Building, b.Result),
+		StatusText:  b.Description,
+		StartDate:   start,
+	}
+
+	if build.StatusText == "" {
+		build.StatusText = b.Result
+	}
+
+	if b.Building {
+		build.Progress = progress(start, b.EstimatedDuration)
+	} else {
+		build.Progress = 100
+		build.FinishDate = start.Add(time.Duration(b.Duration) * time.Millisecond)
+	}
+
+	return build
+}
+
+// StatusToDb leaves the builds that were aborted or never built unknown. Any other result that is not a
+// success, unstable builds and results Jenkins adds later included, is a failure.
+func StatusToDb(building bool, result string) db.BuildStatus {
+	if building {
+		return db.StatusRunning
+	}
+
+	switch result {
+	case "SUCCESS":
+		return db.StatusSuccess
+	case "ABORTED", "NOT_BUILT":
+		return db.StatusUnknown
+	}
+
+	return db.StatusFailure
+}
+
+// KindToDb marks the builds that were aborted or never built as canceled so the build policies apply to them
+func KindToDb(building bool, result string) db.BuildKind {
+	if !building && (result == "ABORTED" || result == "NOT_BUILT") {
+		return db.KindCanceled
+	}
+
+	return db.KindNormal
+}
+
+// progress is estimated from the elapsed time since Jenkins does not report one
+func progress(start time.Time, estimatedMillis int64) int {
+	if estimatedMillis <= 0 {
+		return 0
+	}
+
+	elapsedMillis := int64(time.Since(start) / time.Millisecond)
+	p := int(elapsedMillis * 100 / estimatedMillis)
+	if p < 0 {
+		return 0
+	}
+
+	if p > 99 {
+		return 99
+	}
+
+	return p
+}
+
+func fromMillis(ms int64) time.Time {
+	return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
+}
+
+func childPath(path []string, name string) []string {
+	return append(append([]string{}, path...), name)
+}
+
+func idFromPath(path []string) string {
+	return strings.Join(append([]string{Source}, path...), ":")
+}
+
+func pathFromId(id string) []string {
+	id = strings.TrimPrefix(id, Source)
+	id = strings.TrimPrefix(id, ":")
+	if id == "" {
+		return []string{}
+	}
+
+	return strings.Split(id, ":")
+}
+
+// Branch job names are the branch name with characters like / percent encoded
+func decodeBranch(name string) string {
+	branch, err := url.PathUnescape(name)
+	if err != nil {
+		return name
+	}
+
+	return branch
+}
+
+func encodeBranch(branch string) string {
+	return url.PathEscape(branch)
+}
@@ -0,0 +1,241 @@
+package jenkins_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+	"build-monitor-v2/server/jenkins"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var fixtures = map[string]string{
+	"/api/json":                                                "root.json",
+	"/job/services/api/json":                                   "services.json",
+	"/job/legacy-build/api/json":                               "legacy-build.json",
+	"/job/legacy-build/43/api/json":                            "legacy-build-43.json",
+	"/job/services/job/gateway/api/json":                       "gateway.json",
+	"/job/services/job/gateway/job/main/api/json":              "gateway-main.json",
+	"/job/services/job/gateway/job/feature%252Flogin/api/json": "gateway-feature.json",
+	"/computer/api/json":                                       "computer.json",
+}
+
+// newJenkinsServer stands in for Jenkins by serving the json api responses from testdata
+func newJenkinsServer(requests *[]*http.Request) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r)
+
+		file, ok := fixtures[r.URL.EscapedPath()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		body, err := ioutil.ReadFile("testdata/" + file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(strings.Replace(string(body), "{{url}}", server.URL, -1)))
+	}))
+
+	return server
+}
+
+func TestJenkins_GetProjects_GetBuildTypes(t *testing.T) {
+	Convey("Given a Jenkins server with folders, jobs and a multibranch pipeline", t, func() {
+		var requests []*http.Request
+		server := newJenkinsServer(&requests)
+		defer server.Close()
+
+		j := jenkins.New(server.URL+"/", "builder", "api-token")
+
+		Convey("When GetProjects is called", func() {
+			projects, err := j.GetProjects()
+
+			Convey("It should map Jenkins and its folders to projects", func() {
+				So(err, ShouldBeNil)
+				So(projects, ShouldResemble, []db.Project{
+					{Id: "jenkins", Name: "Jenkins", Description: server.URL},
+					{Id: "jenkins:services", Name: "Services", Description: "Legacy Java services", ParentProjectID: "jenkins"},
+				})
+			})
+
+			Convey("It should authenticate with the user and api token", func() {
+				user, token, ok := requests[0].BasicAuth()
+				So(ok, ShouldBeTrue)
+				So(user, ShouldEqual, "builder")
+				So(token, ShouldEqual, "api-token")
+			})
+		})
+
+		Convey("When GetBuildTypes is called", func() {
+			buildTypes, err := j.GetBuildTypes()
+
+			Convey("It should map jobs and multibranch pipelines to build types", func() {
+				So(err, ShouldBeNil)
+				So(buildTypes, ShouldResemble, []db.BuildType{
+					{Id: "jenkins:legacy-build", Name: "legacy-build", Description: "The old monolith", ProjectID: "jenkins"},
+					{Id: "jenkins:services:billing", Name: "Billing", ProjectID: "jenkins:services"},
+					{Id: "jenkins:services:gateway", Name: "Gateway", Description: "API gateway", ProjectID: "jenkins:services"},
+				})
+			})
+		})
+
+		Convey("When Jenkins errors", func() {
+			j := jenkins.New(server.URL+"/missing", "", "")
+			_, err := j.GetProjects()
+
+			Convey("It should return the error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "404")
+			})
+		})
+	})
+}
+
+func TestJenkins_GetBuildsForBuildType(t *testing.T) {
+	Convey("Given a Jenkins server", t, func() {
+		var requests []*http.Request
+		server := newJenkinsServer(&requests)
+		defer server.Close()
+
+		j := jenkins.New(server.URL, "", "")
+
+		Convey("When the build type is a plain job", func() {
+			builds, err := j.GetBuildsForBuildType("jenkins:legacy-build", 50)
+
+			Convey("It should map the builds without a branch", func() {
+				So(err, ShouldBeNil)
+				So(builds, ShouldResemble, []ci.Build{
+					{
+						Id:          42,
+						BuildTypeId: "jenkins:legacy-build",
+						Number:      "42",
+						Status:      db.StatusSuccess,
+						StatusText:  "SUCCESS",
+						Progress:    100,
+						StartDate:   time.Unix(1507141495, 0),
+						FinishDate:  time.Unix(1507141555, 0),
+					},
+					{
+						Id:          41,
+						BuildTypeId: "jenkins:legacy-build",
+						Number:      "41",
+						Status:      db.StatusFailure,
+						StatusText:  "2 tests failed",
+						Progress:    100,
+						StartDate:   time.Unix(1507141395, 0),
+						FinishDate:  time.Unix(1507141425, 0),
+					},
+				})
+			})
+
+			Convey("It should only ask for the requested number of builds", func() {
+				So(requests[0].URL.Query().Get("tree"), ShouldEndWith, "{0,50}")
+			})
+		})
+
+		Convey("When the build type is a multibranch pipeline", func() {
+			builds, err := j.GetBuildsForBuildType("jenkins:services:gateway", 10)
+
+			Convey("It should load every branch job as a branch", func() {
+				So(err, ShouldBeNil)
+				So(len(builds), ShouldEqual, 2)
+
+				So(builds[0].BranchName, ShouldEqual, "main")
+				So(builds[0].Status, ShouldEqual, db.StatusUnknown)
+				So(builds[0].Kind, ShouldEqual, db.KindCanceled)
+				So(builds[0].BuildTypeId, ShouldEqual, "jenkins:services:gateway")
+
+				So(builds[1].BranchName, ShouldEqual, "feature/login")
+				So(builds[1].Status, ShouldEqual, db.StatusRunning)
+				So(builds[1].Progress, ShouldEqual, 99)
+				So(builds[1].FinishDate.IsZero(), ShouldBeTrue)
+			})
+		})
+
+		Convey("When the job does not exist", func() {
+			_, err := j.GetBuildsForBuildType("jenkins:nope", 10)
+
+			Convey("It should return the error", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestJenkins_GetRunningBuilds(t *testing.T) {
+	Convey("Given a Jenkins server with running builds", t, func() {
+		var requests []*http.Request
+		server := newJenkinsServer(&requests)
+		defer server.Close()
+
+		j := jenkins.New(server.URL, "", "")
+
+		Convey("When the build types have been loaded", func() {
+			_, btErr := j.GetBuildTypes()
+			So(btErr, ShouldBeNil)
+
+			builds, err := j.GetRunningBuilds()
+
+			Convey("It should map the builds on every executor", func() {
+				So(err, ShouldBeNil)
+				So(len(builds), ShouldEqual, 2)
+
+				So(builds[0].Id, ShouldEqual, 3)
+				So(builds[0].BuildTypeId, ShouldEqual, "jenkins:services:gateway")
+				So(builds[0].BranchName, ShouldEqual, "feature/login")
+				So(builds[0].Status, ShouldEqual, db.StatusRunning)
+
+				So(builds[1].Id, ShouldEqual, 43)
+				So(builds[1].BuildTypeId, ShouldEqual, "jenkins:legacy-build")
+				So(builds[1].BranchName, ShouldEqual, "")
+				So(builds[1].Progress, ShouldEqual, 0)
+			})
+
+			Convey("And GetBuild is called for a build that has finished", func() {
+				build, err := j.GetBuild(builds[1])
+
+				Convey("It should re-read the build", func() {
+					So(err, ShouldBeNil)
+					So(build.Id, ShouldEqual, 43)
+					So(build.BuildTypeId, ShouldEqual, "jenkins:legacy-build")
+					So(build.Status, ShouldEqual, db.StatusFailure)
+					So(build.FinishDate, ShouldResemble, time.Unix(1507141600, 0))
+				})
+			})
+		})
+	})
+}
+
+func TestStatusToDb(t *testing.T) {
+	Convey("Given Jenkins build results", t, func() {
+		Convey("It should map them to the db statuses", func() {
+			So(jenkins.StatusToDb(true, ""), ShouldEqual, db.StatusRunning)
+			So(jenkins.StatusToDb(false, "SUCCESS"), ShouldEqual, db.StatusSuccess)
+			So(jenkins.StatusToDb(false, "FAILURE"), ShouldEqual, db.StatusFailure)
+			So(jenkins.StatusToDb(false, "UNSTABLE"), ShouldEqual, db.StatusFailure)
+			So(jenkins.StatusToDb(false, "ABORTED"), ShouldEqual, db.StatusUnknown)
+			So(jenkins.StatusToDb(false, "NOT_BUILT"), ShouldEqual, db.StatusUnknown)
+			So(jenkins.StatusToDb(false, "SOMETHING_NEW"), ShouldEqual, db.StatusFailure)
+		})
+
+		Convey("It should mark the builds that did not run to the end as canceled", func() {
+			So(jenkins.KindToDb(true, ""), ShouldEqual, db.KindNormal)
+			So(jenkins.KindToDb(false, "SUCCESS"), ShouldEqual, db.KindNormal)
+			So(jenkins.KindToDb(false, "FAILURE"), ShouldEqual, db.KindNormal)
+			So(jenkins.KindToDb(false, "ABORTED"), ShouldEqual, db.KindCanceled)
+			So(jenkins.KindToDb(false, "NOT_BUILT"), ShouldEqual, db.KindCanceled)
+		})
+	})
+}
@@ -7,10 +7,16 @@ import (
 
 	"build-monitor-v2/server/db"
 
+	"build-monitor-v2/server/azure"
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/github"
+	"build-monitor-v2/server/gitlab"
+	"build-monitor-v2/server/jenkins"
 	"build-monitor-v2/server/tc"
 
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
 	"github.com/ian-kent/gofigure"
@@ -33,21 +39,92 @@ func main() {
 	session := setupDatabase(log, config)
 	defer session.Close()
 
-	tcLog := log.WithField("component", "tcMonitor")
-	tcDb := db.Create(session.Copy(), &config, tcLog, time.Now)
+	tcServers, tcErr := config.TeamCityServers()
+	if tcErr != nil {
+		log.Fatalf("Failed to load the TeamCity servers: %v", tcErr)
+	}
+
+	monitors := tc.Monitors{}
+	for _, s := range tcServers {
+		tcLog := log.WithFields(logrus.Fields{"component": "tcMonitor", "tcServer": s.Name})
+		tcDb := db.Create(session.Copy(), &config, tcLog, time.Now)
+
+		tcMonitor := tc.NewTeamCityServer(tcLog, s, tcDb)
+		monitors = append(monitors, &tcMonitor)
+	}
+
+	if config.JenkinsUrl != "" {
+		jenkinsProvider := jenkins.New(config.JenkinsUrl, config.JenkinsUser, config.JenkinsToken)
+		monitors = append(monitors, newProviderMonitor(log, session, &config, jenkins.Source, jenkinsProvider, config.JenkinsPollInterval, config.JenkinsRunningBuildPollInterval))
+	}
+
+	if config.GitLabUrl != "" {
+		gitlabProvider, err := gitlab.New(config.GitLabUrl, config.GitLabToken)
+		if err != nil {
+			log.Fatalf("Failed to setup the GitLab monitor: %v", err)
+		}
+
+		monitors = append(monitors, newProviderMonitor(log, session, &config, gitlab.Source, gitlabProvider, config.GitLabPollInterval, config.GitLabRunningBuildPollInterval))
+	}
+
+	if config.GitHubRepos != "" {
+		githubProvider, err := github.New(config.GitHubApiUrl, config.GitHubToken, strings.Split(config.GitHubRepos, ","))
+		if err != nil {
+			log.Fatalf("Failed to setup the GitHub Actions monitor: %v", err)
+		}
+
+		monitors = append(monitors, newProviderMonitor(log, session, &config, github.Source, githubProvider, config.GitHubPollInterval, config.GitHubRunningBuildPollInterval))
+	}
+
+	if config.AzureDevOpsOrganization != "" {
+		azureProvider, err := azure.New(config.AzureDevOpsUrl, config.AzureDevOpsOrganization, config.AzureDevOpsToken)
+		if err != nil {
+			log.Fatalf("Failed to setup the Azure DevOps monitor: %v", err)
+		}
 
-	tcMonitor := tc.NewServer(tcLog, &config, tcDb)
-	if err := tcMonitor.Start(); err != nil {
-		log.Fatalf("Failed to start Teamcity monitor: %v", err)
+		monitors = append(monitors, newProviderMonitor(log, session, &config, azure.Source, azureProvider, config.AzureDevOpsPollInterval, config.AzureDevOpsRunningBuildPollInterval))
 	}
 
-	gocron.Every(1).Hour().Do(func() { tcMonitor.Refresh() })
+	backoffMaxInterval, backoffErr := time.ParseDuration(config.BackoffMaxInterval)
+	if backoffErr != nil {
+		log.Fatalf("Failed to parse duration BackoffMaxInterval = %s", config.BackoffMaxInterval)
+	}
+
+	var branchRetention time.Duration
+	if config.BranchRetention != "" {
+		retention, retentionErr := time.ParseDuration(config.BranchRetention)
+		if retentionErr != nil {
+			log.Fatalf("Failed to parse duration BranchRetention = %s", config.BranchRetention)
+		}
+
+		branchRetention = retention
+	}
+
+	for name, policy := range map[string]string{"PersonalBuilds": config.PersonalBuilds, "CanceledBuilds": config.CanceledBuilds, "FailedToStartBuilds": config.FailedToStartBuilds} {
+		if policy == "" || !db.BuildPolicy(policy).Valid() {
+			log.Fatalf("Failed to parse build policy %s = %s, use ignore, show or count", name, policy)
+		}
+	}
+
+	for _, m := range monitors {
+		// Failing servers are retried from their poll interval up to the max interval
+		m.Breaker = tc.NewBreaker(config.BreakerThreshold, m.TcPollInterval, backoffMaxInterval)
+		m.DegradedStart = config.DegradedStart
+		m.HistoryDepth = config.HistoryDepth
+		m.BranchRetention = branchRetention
+	}
+
+	if err := monitors.Start(); err != nil {
+		log.Fatalf("Failed to start monitors: %v", err)
+	}
+
+	gocron.Every(1).Hour().Do(func() { monitors.Refresh() })
 
 	server := api.Create(
 		log.WithField("component", "api"),
 		&config,
 		session,
-		&tcMonitor,
+		monitors,
 	)
 
 	if err := server.Setup(); err != nil {
@@ -67,7 +144,16 @@ func main() {
 	cronChannel <- true
 
 	server.Shutdown()
-	tcMonitor.Shutdown()
+	monitors.Shutdown()
+}
+
+// newProviderMonitor gives each extra provider its own log component and database session like the TeamCity monitor
+func newProviderMonitor(log *logrus.Entry, session *mgo.Session, config *cfg.Config, source string, p ci.Provider, pollInterval, runningBuildPollInterval string) *tc.Server {
+	monitorLog := log.WithField("component", source+"Monitor")
+	monitorDb := db.Create(session.Copy(), config, monitorLog, time.Now)
+
+	monitor := tc.NewProviderServer(monitorLog, source, p, monitorDb, pollInterval, runningBuildPollInterval)
+	return &monitor
 }
 
 func setupDatabase(log *logrus.Entry, config cfg.Config) *mgo.Session {
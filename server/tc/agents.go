@@ -0,0 +1,92 @@
+package tc
+
+import (
+	"time"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+)
+
+// agentSyncInterval is how often the polls also sync the agents, a pool going offline is not worth a call every poll
+const agentSyncInterval = time.Minute
+
+// SyncAgents stores the agents of this monitor's server, only the ones that changed are written
+// and the ones that are gone are deleted
+var SyncAgents = func(c *Server) error {
+	p, ok := c.Provider.(ci.AgentLister)
+	if !ok {
+		return nil
+	}
+
+	agents, err := p.GetAgents()
+	if err != nil {
+		return err
+	}
+
+	dbAgentMap, amErr := agentMap(c.Db, c.Source)
+	if amErr != nil {
+		return amErr
+	}
+
+	for _, a := range agents {
+		agent := AgentToDb(a, c.Source)
+
+		if stored, ok := dbAgentMap[agent.Id]; !ok || !sameAgent(stored, agent) {
+			if _, dbErr := c.Db.UpsertAgent(agent); dbErr != nil {
+				c.Log.Errorf("Failed to upsert agent. Id: %s, Name: %s, Error: %v", agent.Id, agent.Name, dbErr)
+			}
+		}
+
+		delete(dbAgentMap, agent.Id)
+	}
+
+	for _, agent := range dbAgentMap {
+		c.Db.DeleteAgent(agent.Id)
+	}
+
+	return nil
+}
+
+func agentMap(appDb IDb, source string) (map[string]db.Agent, error) {
+	agents, err := appDb.AgentList()
+	if err != nil {
+		return nil, err
+	}
+
+	agentMap := make(map[string]db.Agent)
+	for _, v := range agents {
+		if v.Source == source {
+			agentMap[v.Id] = v
+		}
+	}
+
+	return agentMap, nil
+}
+
+func sameAgent(a, b db.Agent) bool {
+	if (a.Build == nil) != (b.Build == nil) || (a.Build != nil && *a.Build != *b.Build) {
+		return false
+	}
+
+	a.Build, b.Build = nil, nil
+	return a == b
+}
+
+func AgentToDb(a ci.Agent, source string) db.Agent {
+	agent := db.Agent{
+		Id:         a.Id,
+		Name:       a.Name,
+		Source:     source,
+		PoolId:     a.PoolId,
+		PoolName:   a.PoolName,
+		Connected:  a.Connected,
+		Authorized: a.Authorized,
+		Enabled:    a.Enabled,
+	}
+
+	if a.BuildId != 0 {
+		agent.Build = &db.AgentBuild{Id: a.BuildId, BuildTypeId: a.BuildTypeId}
+	}
+
+	return agent
+}
@@ -0,0 +1,94 @@
+package tc_test
+
+import (
+	"errors"
+	"testing"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+	"build-monitor-v2/server/tc"
+
+	"github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestServer_SyncAgents(t *testing.T) {
+	Convey("Given a monitor for a provider with agents", t, func() {
+		log := logrus.WithField("test", "TestServer_SyncAgents")
+		dbMock := new(IDbMock)
+		providerMock := new(IAgentProviderMock)
+
+		c := tc.Server{Source: "cloud", Provider: providerMock, Db: dbMock, Log: log}
+
+		busy := ci.Agent{Id: "cloud:1", Name: "linux-01", PoolId: "cloud:0", PoolName: "Default", Connected: true, Authorized: true, Enabled: true, BuildId: 512, BuildTypeId: "cloud:bt1"}
+		idle := ci.Agent{Id: "cloud:2", Name: "linux-02", PoolId: "cloud:0", PoolName: "Default", Connected: true, Authorized: true, Enabled: true}
+
+		Convey("When the agents are new", func() {
+			providerMock.On("GetAgents").Return([]ci.Agent{busy, idle}, nil)
+			dbMock.On("AgentList").Return([]db.Agent{}, nil)
+			dbMock.On("UpsertAgent", mock.Anything).Return(&db.Agent{}, nil)
+
+			err := tc.SyncAgents(&c)
+
+			Convey("It should store them with their source and build", func() {
+				So(err, ShouldBeNil)
+				dbMock.AssertNumberOfCalls(t, "UpsertAgent", 2)
+
+				stored := dbMock.Calls[1].Arguments.Get(0).(db.Agent)
+				So(stored.Source, ShouldEqual, "cloud")
+				So(stored.PoolName, ShouldEqual, "Default")
+				So(*stored.Build, ShouldResemble, db.AgentBuild{Id: 512, BuildTypeId: "cloud:bt1"})
+				So(dbMock.Calls[2].Arguments.Get(0).(db.Agent).Build, ShouldBeNil)
+			})
+		})
+
+		Convey("When an agent did not change and another one disconnected", func() {
+			disconnected := idle
+			disconnected.Connected = false
+
+			providerMock.On("GetAgents").Return([]ci.Agent{busy, disconnected}, nil)
+			dbMock.On("AgentList").Return([]db.Agent{
+				tc.AgentToDb(busy, "cloud"),
+				tc.AgentToDb(idle, "cloud"),
+				{Id: "cloud:9", Source: "cloud"},
+				{Id: "9", Source: ""},
+			}, nil)
+			dbMock.On("UpsertAgent", tc.AgentToDb(disconnected, "cloud")).Return(&db.Agent{}, nil)
+			dbMock.On("DeleteAgent", "cloud:9").Return(nil)
+
+			err := tc.SyncAgents(&c)
+
+			Convey("It should only write the one that changed", func() {
+				So(err, ShouldBeNil)
+				dbMock.AssertExpectations(t)
+				dbMock.AssertNumberOfCalls(t, "UpsertAgent", 1)
+			})
+
+			Convey("It should delete the ones of this server that are gone", func() {
+				dbMock.AssertNotCalled(t, "DeleteAgent", "9")
+			})
+		})
+
+		Convey("When the agents can not be read", func() {
+			expectedErr := errors.New("502 Bad Gateway")
+			providerMock.On("GetAgents").Return(nil, expectedErr)
+
+			err := tc.SyncAgents(&c)
+
+			Convey("It should return the error and change nothing", func() {
+				So(err, ShouldEqual, expectedErr)
+				dbMock.AssertNotCalled(t, "AgentList")
+			})
+		})
+
+		Convey("When the provider has no agents", func() {
+			c.Provider = new(IProviderMock)
+
+			Convey("It should do nothing", func() {
+				So(tc.SyncAgents(&c), ShouldBeNil)
+				dbMock.AssertNotCalled(t, "AgentList")
+			})
+		})
+	})
+}
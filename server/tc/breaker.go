@@ -0,0 +1,164 @@
+package tc
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"build-monitor-v2/server/ci"
+)
+
+// BackoffJitter spreads a retry delay over its upper half so monitors that failed together do not retry together
+var BackoffJitter = func(d time.Duration) time.Duration {
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Breaker stops a monitor from hammering a server that can not be reached. Every failure in a row
+// doubles the wait before the next call, from BaseDelay up to MaxDelay, and after Threshold failures
+// the circuit opens and nothing is called until that wait is over. The first call after it is a
+// half-open probe, it closes the circuit when it succeeds and opens it again for longer when it fails.
+type Breaker struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Now       func() time.Time
+
+	mu        sync.Mutex
+	state     string
+	failures  int
+	lastError string
+	retryAt   time.Time
+}
+
+func NewBreaker(threshold int, baseDelay, maxDelay time.Duration) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	if maxDelay < baseDelay {
+		maxDelay = baseDelay
+	}
+
+	return &Breaker{
+		Threshold: threshold,
+		BaseDelay: baseDelay,
+		MaxDelay:  maxDelay,
+		Now:       time.Now,
+		state:     ci.BreakerClosed,
+	}
+}
+
+// Allow says if the server may be called now, an open circuit turns half-open once its wait is over.
+// A nil breaker always allows the call.
+func (b *Breaker) Allow() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == ci.BreakerOpen {
+		if b.Now().Before(b.retryAt) {
+			return false
+		}
+
+		b.state = ci.BreakerHalfOpen
+	}
+
+	return true
+}
+
+// Success closes the circuit and returns true when it was not closed before
+func (b *Breaker) Success() bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	recovered := b.state != ci.BreakerClosed
+	b.state = ci.BreakerClosed
+	b.failures = 0
+	b.lastError = ""
+	b.retryAt = time.Time{}
+
+	return recovered
+}
+
+// Failure counts a failed call and returns true when it opened the circuit
+func (b *Breaker) Failure(err error) bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.lastError = err.Error()
+	b.retryAt = b.Now().Add(BackoffJitter(b.backoff()))
+
+	if b.state == ci.BreakerOpen || (b.state == ci.BreakerClosed && b.failures < b.Threshold) {
+		return false
+	}
+
+	b.state = ci.BreakerOpen
+	return true
+}
+
+// Delay is how long the monitor should wait before its next poll, the poll interval unless it is backing off
+func (b *Breaker) Delay(interval time.Duration) time.Duration {
+	if b == nil {
+		return interval
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures == 0 {
+		return interval
+	}
+
+	if wait := b.retryAt.Sub(b.Now()); wait > interval {
+		return wait
+	}
+
+	return interval
+}
+
+func (b *Breaker) Status() *ci.BreakerStatus {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := ci.BreakerStatus{State: b.state, Failures: b.failures, LastError: b.lastError}
+	if !b.retryAt.IsZero() {
+		retryAt := b.retryAt
+		status.RetryAt = &retryAt
+	}
+
+	return &status
+}
+
+func (b *Breaker) backoff() time.Duration {
+	delay := b.BaseDelay
+	for i := 1; i < b.failures && delay < b.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > b.MaxDelay {
+		return b.MaxDelay
+	}
+
+	return delay
+}
@@ -0,0 +1,97 @@
+package tc_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/tc"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBreaker(t *testing.T) {
+	Convey("Given a breaker that opens after 3 failures", t, func() {
+		oldBackoffJitter := tc.BackoffJitter
+		tc.BackoffJitter = func(d time.Duration) time.Duration { return d }
+		defer func() { tc.BackoffJitter = oldBackoffJitter }()
+
+		now := time.Date(2017, 10, 4, 18, 0, 0, 0, time.UTC)
+		b := tc.NewBreaker(3, time.Second*20, time.Minute)
+		b.Now = func() time.Time { return now }
+
+		down := errors.New("connection refused")
+
+		Convey("When the server keeps failing", func() {
+			opened := []bool{b.Failure(down), b.Failure(down)}
+
+			Convey("It should back off but stay closed below the threshold", func() {
+				So(opened, ShouldResemble, []bool{false, false})
+				So(b.Allow(), ShouldBeTrue)
+				So(b.Delay(time.Second*5), ShouldEqual, time.Second*40)
+				So(b.Status().State, ShouldEqual, ci.BreakerClosed)
+			})
+
+			Convey("It should open at the threshold and refuse calls until the backoff is over", func() {
+				So(b.Failure(down), ShouldBeTrue)
+				So(b.Allow(), ShouldBeFalse)
+
+				status := b.Status()
+				So(status.State, ShouldEqual, ci.BreakerOpen)
+				So(status.Failures, ShouldEqual, 3)
+				So(status.LastError, ShouldEqual, "connection refused")
+				So(*status.RetryAt, ShouldResemble, now.Add(time.Minute))
+
+				now = now.Add(time.Minute)
+				So(b.Allow(), ShouldBeTrue)
+				So(b.Status().State, ShouldEqual, ci.BreakerHalfOpen)
+			})
+
+			Convey("It should open again when the half-open probe fails", func() {
+				b.Failure(down)
+				now = now.Add(time.Minute)
+				b.Allow()
+
+				So(b.Failure(down), ShouldBeTrue)
+				So(b.Status().State, ShouldEqual, ci.BreakerOpen)
+				So(b.Allow(), ShouldBeFalse)
+			})
+
+			Convey("It should close and poll normally again after a success", func() {
+				b.Failure(down)
+				now = now.Add(time.Minute)
+				b.Allow()
+
+				So(b.Success(), ShouldBeTrue)
+
+				status := b.Status()
+				So(status.State, ShouldEqual, ci.BreakerClosed)
+				So(status.Failures, ShouldEqual, 0)
+				So(status.RetryAt, ShouldBeNil)
+				So(b.Delay(time.Second*5), ShouldEqual, time.Second*5)
+			})
+		})
+
+		Convey("When it is not set", func() {
+			var nilBreaker *tc.Breaker
+
+			Convey("It should always allow calls", func() {
+				So(nilBreaker.Allow(), ShouldBeTrue)
+				So(nilBreaker.Failure(down), ShouldBeFalse)
+				So(nilBreaker.Delay(time.Second), ShouldEqual, time.Second)
+				So(nilBreaker.Status(), ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestBackoffJitter(t *testing.T) {
+	Convey("It should keep the delay within its upper half", t, func() {
+		for i := 0; i < 100; i++ {
+			d := tc.BackoffJitter(time.Second * 10)
+			So(d, ShouldBeGreaterThanOrEqualTo, time.Second*5)
+			So(d, ShouldBeLessThanOrEqualTo, time.Second*10)
+		}
+	})
+}
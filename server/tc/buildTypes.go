@@ -2,12 +2,10 @@ package tc
 
 import (
 	"build-monitor-v2/server/db"
-
-	"github.com/pstuart2/go-teamcity"
 )
 
 var RefreshBuildTypes = func(c *Server) error {
-	projectMap, projErr := projectMap(c.Db)
+	projectMap, projErr := projectMap(c.Db, c.Source)
 	if projErr != nil {
 		c.Log.Errorf("Failed to get project list from database: %v", projErr)
 		return projErr
@@ -17,13 +15,13 @@ var RefreshBuildTypes = func(c *Server) error {
 		return nil
 	}
 
-	buildTypes, err := c.Tc.GetBuildTypes()
+	buildTypes, err := c.Provider.GetBuildTypes()
 	if err != nil {
-		c.Log.Errorf("Failed to get buildTypes from Team city: %v", err)
+		c.Log.Errorf("Failed to get buildTypes from the provider: %v", err)
 		return err
 	}
 
-	dbBuildTypeMap, pmErr := buildTypeMap(c.Db)
+	dbBuildTypeMap, pmErr := buildTypeMap(c.Db, c.Source)
 	if pmErr != nil {
 		c.Log.Errorf("Failed to get buildTypes from Team city: %v", err)
 		return pmErr
@@ -31,14 +29,14 @@ var RefreshBuildTypes = func(c *Server) error {
 
 	for _, buildType := range buildTypes {
 		if _, ok := projectMap[buildType.ProjectID]; ok {
-			dbBuildType := BuildTypeToDb(buildType)
+			buildType.Source = c.Source
 
-			_, dbErr := c.Db.UpsertBuildType(dbBuildType)
+			_, dbErr := c.Db.UpsertBuildType(buildType)
 			if dbErr != nil {
-				c.Log.Errorf("Failed to upsert buildType. Id: %s, Name: %s", dbBuildType.Id, dbBuildType.Name)
+				c.Log.Errorf("Failed to upsert buildType. Id: %s, Name: %s", buildType.Id, buildType.Name)
 			}
 
-			delete(dbBuildTypeMap, dbBuildType.Id)
+			delete(dbBuildTypeMap, buildType.Id)
 		}
 	}
 
@@ -49,7 +47,7 @@ var RefreshBuildTypes = func(c *Server) error {
 	return nil
 }
 
-func buildTypeMap(appDb IDb) (map[string]db.BuildType, error) {
+func buildTypeMap(appDb IDb, source string) (map[string]db.BuildType, error) {
 	buildTypes, err := appDb.BuildTypeList()
 	if err != nil {
 		return nil, err
@@ -57,17 +55,10 @@ func buildTypeMap(appDb IDb) (map[string]db.BuildType, error) {
 
 	projectMap := make(map[string]db.BuildType)
 	for _, v := range buildTypes {
-		projectMap[v.Id] = v
+		if v.Source == source {
+			projectMap[v.Id] = v
+		}
 	}
 
 	return projectMap, nil
 }
-
-func BuildTypeToDb(p teamcity.BuildType) db.BuildType {
-	return db.BuildType{
-		Id:          p.ID,
-		Name:        p.Name,
-		Description: p.Description,
-		ProjectID:   p.ProjectID,
-	}
-}
@@ -10,7 +10,6 @@ import (
 	"build-monitor-v2/server/db"
 	"errors"
 
-	"github.com/pstuart2/go-teamcity"
 	"github.com/sirupsen/logrus"
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -18,11 +17,11 @@ import (
 func TestServer_RefreshBuildTypes(t *testing.T) {
 	Convey("Given a server", t, func() {
 		log := logrus.WithField("test", "TestServer_RefreshBuildTypes")
-		serverMock := new(ITcClientMock)
+		providerMock := new(IProviderMock)
 		dbMock := new(IDbMock)
 
 		c := tc.Server{
-			Tc:             serverMock,
+			Provider:       providerMock,
 			Db:             dbMock,
 			Log:            log,
 			TcPollInterval: time.Millisecond * 500,
@@ -33,10 +32,10 @@ func TestServer_RefreshBuildTypes(t *testing.T) {
 
 			dbMock.On("ProjectList").Return(projects, nil)
 
-			Convey("It should not query team city or update the db", func() {
+			Convey("It should not query the provider or update the db", func() {
 				tc.RefreshBuildTypes(&c)
 
-				serverMock.AssertExpectations(t)
+				providerMock.AssertExpectations(t)
 				dbMock.AssertExpectations(t)
 			})
 		})
@@ -45,10 +44,10 @@ func TestServer_RefreshBuildTypes(t *testing.T) {
 			expectedError := errors.New("this was expected")
 			dbMock.On("ProjectList").Return(nil, expectedError)
 
-			Convey("It should not query team city or update the db and return the error", func() {
+			Convey("It should not query the provider or update the db and return the error", func() {
 				err := tc.RefreshBuildTypes(&c)
 
-				serverMock.AssertExpectations(t)
+				providerMock.AssertExpectations(t)
 				dbMock.AssertExpectations(t)
 
 				So(err, ShouldEqual, expectedError)
@@ -62,9 +61,9 @@ func TestServer_RefreshBuildTypes(t *testing.T) {
 
 			dbMock.On("ProjectList").Return(projects, nil)
 
-			buildTypes := []teamcity.BuildType{}
+			buildTypes := []db.BuildType{}
 
-			serverMock.On("GetBuildTypes").Times(1).Return(buildTypes, nil)
+			providerMock.On("GetBuildTypes").Times(1).Return(buildTypes, nil)
 
 			dbBuildTypes := []db.BuildType{}
 			dbMock.On("BuildTypeList").Return(dbBuildTypes, nil)
@@ -72,7 +71,7 @@ func TestServer_RefreshBuildTypes(t *testing.T) {
 			Convey("It should not update the db", func() {
 				tc.RefreshBuildTypes(&c)
 
-				serverMock.AssertExpectations(t)
+				providerMock.AssertExpectations(t)
 				dbMock.AssertExpectations(t)
 			})
 		})
@@ -85,25 +84,24 @@ func TestServer_RefreshBuildTypes(t *testing.T) {
 
 			dbMock.On("ProjectList").Return(projects, nil)
 
-			buildTypes := []teamcity.BuildType{
-				{ID: "bt1", Name: "1 BuildType", Description: "Something here _", ProjectID: "p1"},
-				{ID: "bt2", Name: "2 BuildType", Description: "Something here 1", ProjectID: "p3"},
-				{ID: "bt3", Name: "3 BuildType", Description: "Something here 2", ProjectID: "p1"},
-				{ID: "bt4", Name: "4 BuildType", Description: "Something here 3", ProjectID: "p3"},
-				{ID: "bt5", Name: "5 BuildType", Description: "Something here 3", ProjectID: "not-used"},
+			buildTypes := []db.BuildType{
+				{Id: "bt1", Name: "1 BuildType", Description: "Something here _", ProjectID: "p1"},
+				{Id: "bt2", Name: "2 BuildType", Description: "Something here 1", ProjectID: "p3"},
+				{Id: "bt3", Name: "3 BuildType", Description: "Something here 2", ProjectID: "p1"},
+				{Id: "bt4", Name: "4 BuildType", Description: "Something here 3", ProjectID: "p3"},
+				{Id: "bt5", Name: "5 BuildType", Description: "Something here 3", ProjectID: "not-used"},
 			}
 
-			serverMock.On("GetBuildTypes").Times(1).Return(buildTypes, nil)
+			providerMock.On("GetBuildTypes").Times(1).Return(buildTypes, nil)
 
 			Convey("And the get BuildTypeList is successful", func() {
 				dbBuildTypes := []db.BuildType{}
 				dbMock.On("BuildTypeList").Return(dbBuildTypes, nil)
 
-				db1 := tc.BuildTypeToDb(buildTypes[0])
-				db2 := tc.BuildTypeToDb(buildTypes[1])
-				db3 := tc.BuildTypeToDb(buildTypes[2])
-				db4 := tc.BuildTypeToDb(buildTypes[3])
-				//db5 := tc.BuildTypeToDb(buildTypes[4])
+				db1 := buildTypes[0]
+				db2 := buildTypes[1]
+				db3 := buildTypes[2]
+				db4 := buildTypes[3]
 
 				Convey("And the upsert is successful", func() {
 					dbMock.On("UpsertBuildType", db1).Return(&db1, nil)
@@ -114,7 +112,7 @@ func TestServer_RefreshBuildTypes(t *testing.T) {
 					Convey("It should call the db for each project that is not _Root", func() {
 						tc.RefreshBuildTypes(&c)
 
-						serverMock.AssertExpectations(t)
+						providerMock.AssertExpectations(t)
 						dbMock.AssertExpectations(t)
 					})
 				})
@@ -130,7 +128,7 @@ func TestServer_RefreshBuildTypes(t *testing.T) {
 					Convey("It should call the db for each project that is not _Root", func() {
 						tc.RefreshBuildTypes(&c)
 
-						serverMock.AssertExpectations(t)
+						providerMock.AssertExpectations(t)
 						dbMock.AssertExpectations(t)
 					})
 				})
@@ -148,7 +146,7 @@ func TestServer_RefreshBuildTypes(t *testing.T) {
 			})
 		})
 
-		Convey("When there are buildTypes in the database that are no longer in Tc", func() {
+		Convey("When there are buildTypes in the database that are no longer in the provider", func() {
 			projects := []db.Project{
 				{Id: "p1"},
 				{Id: "p3"},
@@ -156,21 +154,21 @@ func TestServer_RefreshBuildTypes(t *testing.T) {
 
 			dbMock.On("ProjectList").Return(projects, nil)
 
-			buildTypes := []teamcity.BuildType{
-				{ID: "bt1", Name: "1 BuildType", Description: "Something here _", ProjectID: "p1"},
-				{ID: "bt2", Name: "2 BuildType", Description: "Something here 1", ProjectID: "p3"},
-				{ID: "bt3", Name: "3 BuildType", Description: "Something here 2", ProjectID: "p1"},
-				{ID: "bt4", Name: "4 BuildType", Description: "Something here 3", ProjectID: "p3"},
+			buildTypes := []db.BuildType{
+				{Id: "bt1", Name: "1 BuildType", Description: "Something here _", ProjectID: "p1"},
+				{Id: "bt2", Name: "2 BuildType", Description: "Something here 1", ProjectID: "p3"},
+				{Id: "bt3", Name: "3 BuildType", Description: "Something here 2", ProjectID: "p1"},
+				{Id: "bt4", Name: "4 BuildType", Description: "Something here 3", ProjectID: "p3"},
 			}
 
-			serverMock.On("GetBuildTypes").Times(1).Return(buildTypes, nil)
+			providerMock.On("GetBuildTypes").Times(1).Return(buildTypes, nil)
 
-			db1 := tc.BuildTypeToDb(buildTypes[0])
-			db2 := tc.BuildTypeToDb(buildTypes[1])
-			db3 := tc.BuildTypeToDb(buildTypes[2])
-			db4 := tc.BuildTypeToDb(buildTypes[3])
-			db5 := tc.BuildTypeToDb(teamcity.BuildType{ID: "p5", Name: "5 BuildType", Description: "Something here 5", ProjectID: "p1"})
-			db6 := tc.BuildTypeToDb(teamcity.BuildType{ID: "p6", Name: "6 BuildType", Description: "Something here 6", ProjectID: "p3"})
+			db1 := buildTypes[0]
+			db2 := buildTypes[1]
+			db3 := buildTypes[2]
+			db4 := buildTypes[3]
+			db5 := db.BuildType{Id: "p5", Name: "5 BuildType", Description: "Something here 5", ProjectID: "p1"}
+			db6 := db.BuildType{Id: "p6", Name: "6 BuildType", Description: "Something here 6", ProjectID: "p3"}
 
 			dbBuildTypes := []db.BuildType{db1, db2, db3, db4, db5, db6}
 			dbMock.On("BuildTypeList").Return(dbBuildTypes, nil)
@@ -185,12 +183,12 @@ func TestServer_RefreshBuildTypes(t *testing.T) {
 			Convey("It should call the db for each buildType", func() {
 				tc.RefreshBuildTypes(&c)
 
-				serverMock.AssertExpectations(t)
+				providerMock.AssertExpectations(t)
 				dbMock.AssertExpectations(t)
 			})
 		})
 
-		Convey("When we fail to get the tc buildTypes", func() {
+		Convey("When we fail to get the provider buildTypes", func() {
 			projects := []db.Project{
 				{Id: "p1"},
 				{Id: "p3"},
@@ -199,14 +197,14 @@ func TestServer_RefreshBuildTypes(t *testing.T) {
 			dbMock.On("ProjectList").Return(projects, nil)
 
 			expectedErr := errors.New("this was expected")
-			serverMock.On("GetBuildTypes").Times(1).Return(nil, expectedErr)
+			providerMock.On("GetBuildTypes").Times(1).Return(nil, expectedErr)
 
 			Convey("It should call the db for each project that is not _Root", func() {
 				err := tc.RefreshBuildTypes(&c)
 
 				So(err, ShouldEqual, expectedErr)
 
-				serverMock.AssertExpectations(t)
+				providerMock.AssertExpectations(t)
 				dbMock.AssertExpectations(t)
 			})
 		})
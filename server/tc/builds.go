@@ -1,68 +1,129 @@
 package tc
 
 import (
+	"build-monitor-v2/server/ci"
 	"build-monitor-v2/server/db"
 	"sort"
+	"sync"
 	"time"
-
-	"github.com/pstuart2/go-teamcity"
 )
 
 // GetRunningBuilds Gets the running builds
-var GetRunningBuilds = func(c *Server, lastBuilds []teamcity.Build) []teamcity.Build {
-	runningBuilds, err := c.Tc.GetRunningBuilds()
+var GetRunningBuilds = func(c *Server, lastBuilds []ci.Build) []ci.Build {
+	runningBuilds, err := c.Provider.GetRunningBuilds()
+
+	// Builds of the build types that could not be asked are still running as far as we know
+	unanswered := make(map[string]bool)
+	if partial, ok := err.(*ci.RunningBuildsError); ok {
+		for _, se := range partial.Errors {
+			c.Log.Errorf("Failed to get running builds of %s, Error: %s", se.BuildTypeId, se.Message)
+			unanswered[se.BuildTypeId] = true
+		}
+
+		err = nil
+	}
+
 	if err != nil {
 		c.Log.Errorf("Failed to get running builds, Error: %v", err)
+		c.failed(err)
 		return lastBuilds
 	}
 
-	usefulBuilds := []teamcity.Build{}
+	c.succeeded()
 
 	c.Log.Infof("Running: %v", runningBuilds)
-	for _, b := range runningBuilds {
-		bt, btErr := c.Db.FindBuildTypeById(b.BuildTypeID)
-		if btErr != nil {
-			c.Log.Errorf("Failed to get build type for: %s, Error: %v", b.BuildTypeID, btErr)
-			continue
-		}
 
-		if len(bt.DashboardIds) == 0 {
-			c.Log.Infof("No dashboards are monitoring this build, ignore")
-			continue
+	var mu sync.Mutex
+	useful := make(map[int]bool)
+
+	groups := groupByBuildType(runningBuilds)
+	forEach(c.SyncConcurrency, len(groups), func(g int) {
+		for _, i := range groups[g] {
+			b := runningBuilds[i]
+
+			bt, btErr := c.Db.FindBuildTypeById(b.BuildTypeId)
+			if btErr != nil {
+				c.Log.Errorf("Failed to get build type for: %s, Error: %v", b.BuildTypeId, btErr)
+				continue
+			}
+
+			if len(bt.DashboardIds) == 0 {
+				c.Log.Infof("No dashboards are monitoring this build, ignore")
+				continue
+			}
+
+			mu.Lock()
+			useful[i] = true
+			mu.Unlock()
+
+			ProcessRunningBuild(c, b, bt)
 		}
+	})
 
-		usefulBuilds = append(usefulBuilds, b)
+	usefulBuilds := []ci.Build{}
+	for i, b := range runningBuilds {
+		if useful[i] {
+			usefulBuilds = append(usefulBuilds, b)
+		}
+	}
 
-		ProcessRunningBuild(c, b, bt)
+	finishedBuilds := []ci.Build{}
+	for _, lb := range lastBuilds {
+		if unanswered[lb.BuildTypeId] {
+			usefulBuilds = append(usefulBuilds, lb)
+		} else if !isBuildInList(lb, usefulBuilds) {
+			finishedBuilds = append(finishedBuilds, lb)
+		}
 	}
 
-	if len(lastBuilds) > 0 {
-		for _, lb := range lastBuilds {
-			if !isBuildInList(lb.ID, usefulBuilds) {
-				bt, btErr := c.Db.FindBuildTypeById(lb.BuildTypeID)
-				if btErr != nil {
-					c.Log.Errorf("Failed to get build type for: %s, Error: %v", lb.BuildTypeID, btErr)
-					continue
-				}
+	groups = groupByBuildType(finishedBuilds)
+	forEach(c.SyncConcurrency, len(groups), func(g int) {
+		for _, i := range groups[g] {
+			lb := finishedBuilds[i]
 
-				build, err := c.Tc.GetBuildByID(lb.ID)
-				if err != nil {
-					c.Log.Errorf("Failed to get the updated build for id: %d", lb.ID)
-					continue
-				}
+			bt, btErr := c.Db.FindBuildTypeById(lb.BuildTypeId)
+			if btErr != nil {
+				c.Log.Errorf("Failed to get build type for: %s, Error: %v", lb.BuildTypeId, btErr)
+				continue
+			}
+
+			build, err := c.Provider.GetBuild(lb)
+			if err != nil {
+				c.Log.Errorf("Failed to get the updated build for id: %d", lb.Id)
+				continue
+			}
 
-				if updErr := ProcessRunningBuild(c, build, bt); updErr != nil {
-					c.Log.Errorf("Failed to update builds for buildType: %s, Error: %v", bt.Id, updErr)
-				}
+			if updErr := ProcessRunningBuild(c, build, bt); updErr != nil {
+				c.Log.Errorf("Failed to update builds for buildType: %s, Error: %v", bt.Id, updErr)
 			}
 		}
-	}
+	})
 
 	return usefulBuilds
 }
 
+// groupByBuildType returns the indexes of the builds for each build type in the order they first appear.
+// Each group is merged by one worker since every merge rewrites the whole build type.
+func groupByBuildType(builds []ci.Build) [][]int {
+	groupIndex := make(map[string]int)
+	groups := [][]int{}
+
+	for i, b := range builds {
+		g, ok := groupIndex[b.BuildTypeId]
+		if !ok {
+			g = len(groups)
+			groupIndex[b.BuildTypeId] = g
+			groups = append(groups, []int{})
+		}
+
+		groups[g] = append(groups[g], i)
+	}
+
+	return groups
+}
+
 // ProcessRunningBuild merges a running build into build type and updates the db
-var ProcessRunningBuild = func(c *Server, b teamcity.Build, bt *db.BuildType) error {
+var ProcessRunningBuild = func(c *Server, b ci.Build, bt *db.BuildType) error {
 	index := indexOfBranch(b.BranchName, bt.Branches)
 	if index == -1 {
 		bt.Branches = append(bt.Branches, db.Branch{Name: b.BranchName})
@@ -74,13 +135,18 @@ var ProcessRunningBuild = func(c *Server, b teamcity.Build, bt *db.BuildType) er
 	if len(bt.Branches[index].Builds) == 0 {
 		bt.Branches[index].Builds = []db.Build{newBuild}
 	} else if bt.Branches[index].Builds[0].Id == newBuild.Id {
+		newBuild.Changes = bt.Branches[index].Builds[0].Changes
+		newBuild.Tests = bt.Branches[index].Builds[0].Tests
 		bt.Branches[index].Builds[0] = newBuild
 	} else {
 		bt.Branches[index].Builds = append([]db.Build{newBuild}, bt.Branches[index].Builds...)
 	}
 
-	bt.Branches[index].Builds = cleanBuilds(bt.Branches[index].Builds)
+	bt.Branches[index].Builds = cleanBuilds(bt.Branches[index].Builds, c.historyDepth(bt.Id))
 	bt.Branches[index].IsRunning = isBranchRunning(bt.Branches[index].Builds)
+	addChanges(c, bt.Id, bt.Branches[index].Builds)
+	addTestCounts(c, bt.Id, bt.Branches[index].Builds)
+	bt.Branches = pruneBranches(bt.Branches, c.BranchRetention, time.Now())
 
 	_, updErr := c.Db.UpdateBuildTypeBuilds(bt.Id, bt.Branches)
 	return updErr
@@ -100,7 +166,7 @@ func (s buildsById) Less(i, j int) bool {
 
 func isBranchRunning(builds []db.Build) bool {
 	for _, b := range builds {
-		if b.Status == teamcity.StatusRunning {
+		if b.Status == db.StatusRunning {
 			return true
 		}
 	}
@@ -108,67 +174,399 @@ func isBranchRunning(builds []db.Build) bool {
 	return false
 }
 
-func cleanBuilds(builds []db.Build) []db.Build {
+// cleanBuilds sorts the builds newest first and keeps depth of them
+func cleanBuilds(builds []db.Build, depth int) []db.Build {
 	sort.Sort(buildsById(builds))
 
-	if len(builds) > 12 {
-		return builds[:12]
+	if len(builds) > depth {
+		return builds[:depth]
 	}
 
 	return builds
 }
 
+// pruneBranches drops the branches whose newest build is older than the retention, a zero retention keeps them all.
+// Running branches and builds without dates are always kept.
+func pruneBranches(branches []db.Branch, retention time.Duration, now time.Time) []db.Branch {
+	if retention <= 0 {
+		return branches
+	}
+
+	kept := []db.Branch{}
+	for _, branch := range branches {
+		if branch.IsRunning || len(branch.Builds) == 0 {
+			kept = append(kept, branch)
+			continue
+		}
+
+		last := branch.Builds[0].FinishDate
+		if last.IsZero() {
+			last = branch.Builds[0].StartDate
+		}
+
+		if last.IsZero() || now.Sub(last) <= retention {
+			kept = append(kept, branch)
+		}
+	}
+
+	return kept
+}
+
+const (
+	// historyCount is how many builds are asked for per build type, cleanBuilds keeps the newest of them
+	historyCount = 1000
+
+	// defaultHistoryDepth is how many builds are kept per branch when the monitor does not set HistoryDepth
+	defaultHistoryDepth = 12
+)
+
+// historyDepth is how many builds to keep on each branch of a build type, the most that any dashboard showing it asks for
+func (c *Server) historyDepth(buildTypeId string) int {
+	if depth, ok := c.historyDepths[buildTypeId]; ok {
+		return depth
+	}
+
+	return c.defaultHistoryDepth()
+}
+
+func (c *Server) defaultHistoryDepth() int {
+	if c.HistoryDepth > 0 {
+		return c.HistoryDepth
+	}
+
+	return defaultHistoryDepth
+}
+
+// GetBuildHistory brings the history of every build type on a dashboard up to date. Providers that
+// support it are only asked for the builds after the last one we saw.
 var GetBuildHistory = func(c *Server) error {
+	return syncBuildHistory(c, false)
+}
+
+// GetFullBuildHistory reads the whole history of every build type on a dashboard again,
+// it only runs when a resync is asked for
+var GetFullBuildHistory = func(c *Server) error {
+	return syncBuildHistory(c, true)
+}
+
+// GetDashboardHistory brings the history of the build types on one dashboard up to date, it runs after the
+// dashboard is saved so a new build config shows its builds without waiting for a full refresh
+var GetDashboardHistory = func(c *Server, dashboardId string) (ci.SyncSummary, error) {
+	dashboards, dlErr := c.Db.DashboardList()
+	if dlErr != nil {
+		return ci.SyncSummary{}, dlErr
+	}
+
+	// The saved dashboard may ask for a deeper history than its build types had
+	c.setHistoryDepths(dashboards)
+
+	for _, d := range dashboards {
+		if d.Id != dashboardId {
+			continue
+		}
+
+		var btIdsList []string
+		for _, b := range d.BuildConfigs {
+			if !contains(btIdsList, b.Id) {
+				btIdsList = append(btIdsList, b.Id)
+			}
+		}
+
+		return syncBuildTypes(c, btIdsList, false)
+	}
+
+	return ci.SyncSummary{}, ci.ErrDashboardNotFound
+}
+
+// GetBuildTypeHistory brings the history of one build type up to date
+var GetBuildTypeHistory = func(c *Server, buildTypeId string) (ci.SyncSummary, error) {
+	dashboards, dlErr := c.Db.DashboardList()
+	if dlErr != nil {
+		return ci.SyncSummary{}, dlErr
+	}
+
+	c.setHistoryDepths(dashboards)
+
+	summary, err := syncBuildTypes(c, []string{buildTypeId}, false)
+	if err == nil && summary.BuildTypes == 0 {
+		return ci.SyncSummary{}, ci.ErrBuildTypeNotFound
+	}
+
+	return summary, err
+}
+
+func syncBuildHistory(c *Server, full bool) error {
 	dashboards, dlErr := c.Db.DashboardList()
 	if dlErr != nil {
 		return dlErr
 	}
 
+	btIdsList := c.setHistoryDepths(dashboards)
+	if len(btIdsList) == 0 {
+		return nil
+	}
+
+	summary, err := syncBuildTypes(c, btIdsList, full)
+	if err != nil {
+		return err
+	}
+
+	c.setLastSync(summary)
+	return nil
+}
+
+// setHistoryDepths keeps the deepest history any dashboard asks for per build type and returns
+// the ids of every build type on a dashboard
+func (c *Server) setHistoryDepths(dashboards []db.Dashboard) []string {
 	var btIdsList []string
+	depths := make(map[string]int)
 	for _, d := range dashboards {
+		depth := c.defaultHistoryDepth()
+		if d.HistoryDepth > depth {
+			depth = d.HistoryDepth
+		}
+
 		for _, b := range d.BuildConfigs {
 			if !contains(btIdsList, b.Id) {
 				btIdsList = append(btIdsList, b.Id)
 			}
+
+			if depth > depths[b.Id] {
+				depths[b.Id] = depth
+			}
+		}
+	}
+
+	// The stored history only goes back as far as the old depth, after a restart the old depths are not known
+	// so the build types deeper than the default are read again once
+	for id, depth := range depths {
+		if depth > c.historyDepth(id) {
+			if c.deepened == nil {
+				c.deepened = make(map[string]bool)
+			}
+
+			c.deepened[id] = true
 		}
 	}
 
+	// Only the monitor goroutine syncs and merges builds so nothing reads the depths while they are replaced
+	c.historyDepths = depths
+
+	if scoped, ok := c.Provider.(ci.DashboardScoped); ok {
+		scoped.SetDashboardBuildTypes(btIdsList)
+	}
+
+	return btIdsList
+}
+
+// syncBuildTypes syncs the history of the listed build types this monitor owns
+func syncBuildTypes(c *Server, btIdsList []string, full bool) (ci.SyncSummary, error) {
+	// Dashboards mix build types from every monitor, only load the ones this monitor owns
+	ownedBuildTypes, btErr := buildTypeMap(c.Db, c.Source)
+	if btErr != nil {
+		return ci.SyncSummary{}, btErr
+	}
+
+	var buildTypes []db.BuildType
+	var fullSyncs []bool
 	for _, buildTypeId := range btIdsList {
-		builds, err := c.Tc.GetBuildsForBuildType(buildTypeId, 1000)
+		if bt, ok := ownedBuildTypes[buildTypeId]; ok {
+			buildTypes = append(buildTypes, bt)
+			fullSyncs = append(fullSyncs, full || c.deepened[buildTypeId])
+		}
+	}
+
+	summary := ci.SyncSummary{Full: full, StartedAt: time.Now(), BuildTypes: len(buildTypes), Errors: []ci.SyncError{}}
+	synced := make([]bool, len(buildTypes))
+	var mu sync.Mutex
+
+	forEach(c.SyncConcurrency, len(buildTypes), func(i int) {
+		err := syncBuildType(c, buildTypes[i], fullSyncs[i])
+
+		mu.Lock()
+		defer mu.Unlock()
+
 		if err != nil {
-			c.Log.Errorf("Failed to get builds for buildType: %s, Error: %v", buildTypeId, err)
-			continue
+			summary.Errors = append(summary.Errors, ci.SyncError{BuildTypeId: buildTypes[i].Id, Message: err.Error()})
+		} else {
+			summary.Synced++
+			synced[i] = true
+		}
+	})
+
+	// A deepened build type that failed is read in full again next time
+	for i, bt := range buildTypes {
+		if synced[i] && fullSyncs[i] {
+			delete(c.deepened, bt.Id)
 		}
+	}
 
-		branchMap := make(map[string]*db.Branch)
-		for _, build := range builds {
-			var branch *db.Branch
+	sort.Sort(syncErrorsById(summary.Errors))
+	summary.FinishedAt = time.Now()
 
-			if val, ok := branchMap[build.BranchName]; ok {
-				branch = val
-			} else {
-				branchMap[build.BranchName] = &db.Branch{Name: build.BranchName, Builds: []db.Build{}}
-				branch = branchMap[build.BranchName]
-			}
+	c.Log.Infof("Synced the history of %d of %d build types in %v", summary.Synced, summary.BuildTypes, summary.FinishedAt.Sub(summary.StartedAt))
+	for _, e := range summary.Errors {
+		c.Log.Errorf("Failed to sync buildType: %s, Error: %s", e.BuildTypeId, e.Message)
+	}
+
+	return summary, nil
+}
+
+func syncBuildType(c *Server, bt db.BuildType, full bool) error {
+	if incremental, ok := c.Provider.(ci.IncrementalHistory); ok && !full && bt.LastBuildId > 0 {
+		err := syncNewBuilds(c, incremental, bt)
+		if err == nil {
+			return nil
+		}
+
+		c.Log.Errorf("Failed to get the builds since %d for buildType: %s, reading them all, Error: %v", bt.LastBuildId, bt.Id, err)
+	}
+
+	return syncAllBuilds(c, bt.Id)
+}
+
+func syncAllBuilds(c *Server, buildTypeId string) error {
+	builds, err := c.Provider.GetBuildsForBuildType(buildTypeId, historyCount)
+	if err != nil {
+		return err
+	}
+
+	branchMap := make(map[string]*db.Branch)
+	for _, build := range builds {
+		var branch *db.Branch
+
+		if val, ok := branchMap[build.BranchName]; ok {
+			branch = val
+		} else {
+			branchMap[build.BranchName] = &db.Branch{Name: build.BranchName, Builds: []db.Build{}}
+			branch = branchMap[build.BranchName]
+		}
+
+		branch.Builds = append(branch.Builds, BuildToDb(build))
+	}
+
+	if len(branchMap) == 0 {
+		return nil
+	}
+
+	branches := branchMapToArray(branchMap)
+	for i, branch := range branches {
+		branches[i].Builds = cleanBuilds(branch.Builds, c.historyDepth(buildTypeId))
+		branches[i].IsRunning = isBranchRunning(branches[i].Builds)
+	}
+
+	branches = pruneBranches(branches, c.BranchRetention, time.Now())
+	for _, branch := range branches {
+		addChanges(c, buildTypeId, branch.Builds)
+		addTestCounts(c, buildTypeId, branch.Builds)
+	}
+
+	_, updateErr := c.Db.UpdateBuildTypeHistory(buildTypeId, branches, lastSeenBuildId(branches))
+	return updateErr
+}
+
+// syncNewBuilds merges the builds after the last one we saw into the stored history
+func syncNewBuilds(c *Server, p ci.IncrementalHistory, bt db.BuildType) error {
+	builds, err := p.GetBuildsSince(bt.Id, bt.LastBuildId, historyCount)
+	if err != nil {
+		return err
+	}
+
+	stored, findErr := c.Db.FindBuildTypeById(bt.Id)
+	if findErr != nil {
+		return findErr
+	}
+
+	branches := stored.Branches
+	storedCount := countBuilds(branches)
+	for _, b := range builds {
+		branches = mergeBuild(branches, BuildToDb(b), b.BranchName)
+	}
+
+	for i, branch := range branches {
+		branches[i].Builds = cleanBuilds(branch.Builds, c.historyDepth(bt.Id))
+		branches[i].IsRunning = isBranchRunning(branches[i].Builds)
+	}
+
+	branches = pruneBranches(branches, c.BranchRetention, time.Now())
+
+	// Without new builds the history only changes when the depth shrank or a branch is past the retention
+	if len(builds) == 0 && countBuilds(branches) == storedCount {
+		return nil
+	}
+
+	for _, branch := range branches {
+		addChanges(c, bt.Id, branch.Builds)
+		addTestCounts(c, bt.Id, branch.Builds)
+	}
+
+	_, updateErr := c.Db.UpdateBuildTypeHistory(bt.Id, branches, lastSeenBuildId(branches))
+	return updateErr
+}
+
+func countBuilds(branches []db.Branch) int {
+	count := 0
+	for _, b := range branches {
+		count += len(b.Builds)
+	}
+
+	return count
+}
+
+type syncErrorsById []ci.SyncError
+
+func (s syncErrorsById) Len() int {
+	return len(s)
+}
+func (s syncErrorsById) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+func (s syncErrorsById) Less(i, j int) bool {
+	return s[i].BuildTypeId < s[j].BuildTypeId
+}
 
-			branch.Builds = append(branch.Builds, BuildToDb(build))
+// mergeBuild replaces the stored copy of a build or adds it to its branch
+func mergeBuild(branches []db.Branch, b db.Build, branchName string) []db.Branch {
+	index := indexOfBranch(branchName, branches)
+	if index == -1 {
+		return append(branches, db.Branch{Name: branchName, Builds: []db.Build{b}})
+	}
+
+	for i, stored := range branches[index].Builds {
+		if stored.Id == b.Id {
+			b.Changes = stored.Changes
+			b.Tests = stored.Tests
+			branches[index].Builds[i] = b
+			return branches
 		}
+	}
+
+	branches[index].Builds = append(branches[index].Builds, b)
+	return branches
+}
 
-		if len(branchMap) > 0 {
-			branches := branchMapToArray(branchMap)
-			for i, branch := range branches {
-				branches[i].Builds = cleanBuilds(branch.Builds)
-				branches[i].IsRunning = isBranchRunning(branches[i].Builds)
+// lastSeenBuildId is the newest finished build that is older than every running build,
+// so the next sync asks for the running builds again until they have finished
+func lastSeenBuildId(branches []db.Branch) int {
+	oldestRunning := 0
+	for _, branch := range branches {
+		for _, b := range branch.Builds {
+			if b.Status == db.StatusRunning && (oldestRunning == 0 || b.Id < oldestRunning) {
+				oldestRunning = b.Id
 			}
+		}
+	}
 
-			_, updateErr := c.Db.UpdateBuildTypeBuilds(buildTypeId, branches)
-			if updateErr != nil {
-				c.Log.Errorf("Failed to update db builds for buildType: %s, Error: %v", buildTypeId, updateErr)
+	last := 0
+	for _, branch := range branches {
+		for _, b := range branch.Builds {
+			if b.Status != db.StatusRunning && b.Id > last && (oldestRunning == 0 || b.Id < oldestRunning) {
+				last = b.Id
 			}
 		}
 	}
 
-	return nil
+	return last
 }
 
 func contains(s []string, k string) bool {
@@ -180,15 +578,17 @@ func contains(s []string, k string) bool {
 	return false
 }
 
-func BuildToDb(p teamcity.Build) db.Build {
+func BuildToDb(p ci.Build) db.Build {
 	return db.Build{
-		Id:         p.ID,
-		Number:     p.Number,
-		Status:     p.Status,
-		StatusText: p.StatusText,
-		Progress:   p.Progress,
-		StartDate:  p.StartDate,
-		FinishDate: getCleanFinishDate(p.StartDate, p.FinishDate),
+		Id:                  p.Id,
+		Number:              p.Number,
+		Status:              p.Status,
+		StatusText:          p.StatusText,
+		Progress:            p.Progress,
+		StartDate:           p.StartDate,
+		FinishDate:          getCleanFinishDate(p.StartDate, p.FinishDate),
+		Kind:                p.Kind,
+		EstimatedFinishDate: p.EstimatedFinishDate,
 	}
 }
 
@@ -219,9 +619,10 @@ func indexOfBranch(name string, branches []db.Branch) int {
 	return -1
 }
 
-func isBuildInList(id int, builds []teamcity.Build) bool {
+// isBuildInList also matches on build type and branch since some providers only number builds per job
+func isBuildInList(b ci.Build, builds []ci.Build) bool {
 	for _, v := range builds {
-		if v.ID == id {
+		if v.Id == b.Id && v.BuildTypeId == b.BuildTypeId && v.BranchName == b.BranchName {
 			return true
 		}
 	}
@@ -1,16 +1,18 @@
 package tc_test
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"build-monitor-v2/server/tc"
 
+	"build-monitor-v2/server/ci"
 	"build-monitor-v2/server/db"
 
 	"errors"
 
-	"github.com/pstuart2/go-teamcity"
 	"github.com/sirupsen/logrus"
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/stretchr/testify/mock"
@@ -19,23 +21,38 @@ import (
 func TestServer_GetRunningBuilds(t *testing.T) {
 	Convey("Given a server", t, func() {
 		log := logrus.WithField("test", "TestServer_GetBuildHistory")
-		tcMock := new(ITcClientMock)
+		providerMock := new(IProviderMock)
 		dbMock := new(IDbMock)
 
 		c := tc.Server{
-			Tc:  tcMock,
-			Db:  dbMock,
-			Log: log,
+			Provider: providerMock,
+			Db:       dbMock,
+			Log:      log,
 		}
 
 		Convey("When GetRunningBuilds errors", func() {
-			tcMock.On("GetRunningBuilds").Return([]teamcity.Build{}, errors.New("this shouldn't have happened"))
+			providerMock.On("GetRunningBuilds").Return([]ci.Build{}, errors.New("this shouldn't have happened"))
 
-			lastBuilds := []teamcity.Build{{ID: 42}, {ID: 43}}
+			lastBuilds := []ci.Build{{Id: 42}, {Id: 43}}
 			newLastBuilds := tc.GetRunningBuilds(&c, lastBuilds)
 
 			Convey("It should return the same build list we passed in", func() {
-				tcMock.AssertExpectations(t)
+				providerMock.AssertExpectations(t)
+				dbMock.AssertExpectations(t)
+
+				So(newLastBuilds, ShouldResemble, lastBuilds)
+			})
+		})
+
+		Convey("When GetRunningBuilds could not ask some build types", func() {
+			partialErr := &ci.RunningBuildsError{Errors: []ci.SyncError{{BuildTypeId: "bt200", Message: "502"}}}
+			providerMock.On("GetRunningBuilds").Return([]ci.Build{}, partialErr)
+
+			lastBuilds := []ci.Build{{Id: 200, BuildTypeId: "bt200"}}
+			newLastBuilds := tc.GetRunningBuilds(&c, lastBuilds)
+
+			Convey("It should keep their builds running instead of finishing them", func() {
+				providerMock.AssertExpectations(t)
 				dbMock.AssertExpectations(t)
 
 				So(newLastBuilds, ShouldResemble, lastBuilds)
@@ -43,15 +60,15 @@ func TestServer_GetRunningBuilds(t *testing.T) {
 		})
 
 		Convey("When GetRunningBuilds returns 0 builds", func() {
-			tcMock.On("GetRunningBuilds").Return([]teamcity.Build{}, errors.New("this shouldn't have happened"))
+			providerMock.On("GetRunningBuilds").Return([]ci.Build{}, errors.New("this shouldn't have happened"))
 
 			Convey("And no lastBuilds were passed in", func() {
-				lastBuilds := []teamcity.Build{}
+				lastBuilds := []ci.Build{}
 
 				newLastBuilds := tc.GetRunningBuilds(&c, lastBuilds)
 
 				Convey("It should not do anything else", func() {
-					tcMock.AssertExpectations(t)
+					providerMock.AssertExpectations(t)
 					dbMock.AssertExpectations(t)
 
 					So(len(newLastBuilds), ShouldEqual, 0)
@@ -60,22 +77,22 @@ func TestServer_GetRunningBuilds(t *testing.T) {
 		})
 
 		Convey("When GetRunningBuilds returns builds", func() {
-			runningBuilds := []teamcity.Build{
-				{ID: 100, BuildTypeID: "bt100"}, // btErr
-				{ID: 101, BuildTypeID: "bt101"}, // Still Processing
-				{ID: 102, BuildTypeID: "bt102"}, // Ignore
-				{ID: 104, BuildTypeID: "bt104"}, // New
-				{ID: 105, BuildTypeID: "bt105"}, // New
+			runningBuilds := []ci.Build{
+				{Id: 100, BuildTypeId: "bt100"}, // btErr
+				{Id: 101, BuildTypeId: "bt101"}, // Still Processing
+				{Id: 102, BuildTypeId: "bt102"}, // Ignore
+				{Id: 104, BuildTypeId: "bt104"}, // New
+				{Id: 105, BuildTypeId: "bt105"}, // New
 			}
 
-			lastBuilds := []teamcity.Build{
-				{ID: 101, BuildTypeID: "bt101"}, // Still Processing
-				{ID: 103, BuildTypeID: "bt103"}, // Completed
-				{ID: 106, BuildTypeID: "bt106"}, // Completed (err FindBuildTypeById)
-				{ID: 109, BuildTypeID: "bt109"}, // Completed (err GetBuildByID)
+			lastBuilds := []ci.Build{
+				{Id: 101, BuildTypeId: "bt101"}, // Still Processing
+				{Id: 103, BuildTypeId: "bt103"}, // Completed
+				{Id: 106, BuildTypeId: "bt106"}, // Completed (err FindBuildTypeById)
+				{Id: 109, BuildTypeId: "bt109"}, // Completed (err GetBuildByID)
 			}
 
-			tcMock.On("GetRunningBuilds").Return(runningBuilds, nil)
+			providerMock.On("GetRunningBuilds").Return(runningBuilds, nil)
 
 			dbBt1 := db.BuildType{Id: "bt101", DashboardIds: []string{"abc", "123"}}
 			dbBt2 := db.BuildType{Id: "bt102", DashboardIds: []string{}}
@@ -95,14 +112,14 @@ func TestServer_GetRunningBuilds(t *testing.T) {
 			dbMock.On("FindBuildTypeById", "bt106").Return(nil, errors.New("this is an error"))
 			dbMock.On("FindBuildTypeById", "bt109").Return(&dbBt9, nil)
 
-			tcMock.On("GetBuildByID", 103).Return(teamcity.Build{ID: 103, Number: "api-103"}, nil)
-			tcMock.On("GetBuildByID", 109).Return(teamcity.Build{}, errors.New("api failed"))
+			providerMock.On("GetBuild", lastBuilds[1]).Return(ci.Build{Id: 103, BuildTypeId: "bt103", Number: "api-103"}, nil)
+			providerMock.On("GetBuild", lastBuilds[3]).Return(ci.Build{}, errors.New("api failed"))
 
 			Convey("And the ProcessRunningBuild succeeds", func() {
 				oldProcessRunningBuild := tc.ProcessRunningBuild
-				prbBuilds := []teamcity.Build{}
+				prbBuilds := []ci.Build{}
 				prbBuildTypes := []db.BuildType{}
-				tc.ProcessRunningBuild = func(c *tc.Server, b teamcity.Build, bt *db.BuildType) error {
+				tc.ProcessRunningBuild = func(c *tc.Server, b ci.Build, bt *db.BuildType) error {
 					prbBuilds = append(prbBuilds, b)
 					prbBuildTypes = append(prbBuildTypes, *bt)
 					return nil
@@ -113,14 +130,14 @@ func TestServer_GetRunningBuilds(t *testing.T) {
 
 				Convey("It should process all the builds in the builds list", func() {
 
-					tcMock.AssertExpectations(t)
+					providerMock.AssertExpectations(t)
 					dbMock.AssertExpectations(t)
 
 					So(len(prbBuilds), ShouldEqual, 4)
-					So(prbBuilds[0].ID, ShouldEqual, 101)
-					So(prbBuilds[1].ID, ShouldEqual, 104)
-					So(prbBuilds[2].ID, ShouldEqual, 105)
-					So(prbBuilds[3].ID, ShouldEqual, 103)
+					So(prbBuilds[0].Id, ShouldEqual, 101)
+					So(prbBuilds[1].Id, ShouldEqual, 104)
+					So(prbBuilds[2].Id, ShouldEqual, 105)
+					So(prbBuilds[3].Id, ShouldEqual, 103)
 
 					So(len(prbBuildTypes), ShouldEqual, 4)
 					So(prbBuildTypes[0].Id, ShouldEqual, "bt101")
@@ -131,9 +148,9 @@ func TestServer_GetRunningBuilds(t *testing.T) {
 					Convey("And it should finish any lastBuilds no longer in builds list", func() {
 						Convey("And it should return useful builds", func() {
 							So(len(resultBuilds), ShouldEqual, 3)
-							So(resultBuilds[0].ID, ShouldEqual, 101)
-							So(resultBuilds[1].ID, ShouldEqual, 104)
-							So(resultBuilds[2].ID, ShouldEqual, 105)
+							So(resultBuilds[0].Id, ShouldEqual, 101)
+							So(resultBuilds[1].Id, ShouldEqual, 104)
+							So(resultBuilds[2].Id, ShouldEqual, 105)
 						})
 					})
 				})
@@ -141,9 +158,9 @@ func TestServer_GetRunningBuilds(t *testing.T) {
 
 			Convey("And the ProcessRunningBuild fails", func() {
 				oldProcessRunningBuild := tc.ProcessRunningBuild
-				prbBuilds := []teamcity.Build{}
+				prbBuilds := []ci.Build{}
 				prbBuildTypes := []db.BuildType{}
-				tc.ProcessRunningBuild = func(c *tc.Server, b teamcity.Build, bt *db.BuildType) error {
+				tc.ProcessRunningBuild = func(c *tc.Server, b ci.Build, bt *db.BuildType) error {
 					prbBuilds = append(prbBuilds, b)
 					prbBuildTypes = append(prbBuildTypes, *bt)
 					return errors.New("i am failing")
@@ -154,14 +171,14 @@ func TestServer_GetRunningBuilds(t *testing.T) {
 
 				Convey("It should process all the builds in the builds list", func() {
 
-					tcMock.AssertExpectations(t)
+					providerMock.AssertExpectations(t)
 					dbMock.AssertExpectations(t)
 
 					So(len(prbBuilds), ShouldEqual, 4)
-					So(prbBuilds[0].ID, ShouldEqual, 101)
-					So(prbBuilds[1].ID, ShouldEqual, 104)
-					So(prbBuilds[2].ID, ShouldEqual, 105)
-					So(prbBuilds[3].ID, ShouldEqual, 103)
+					So(prbBuilds[0].Id, ShouldEqual, 101)
+					So(prbBuilds[1].Id, ShouldEqual, 104)
+					So(prbBuilds[2].Id, ShouldEqual, 105)
+					So(prbBuilds[3].Id, ShouldEqual, 103)
 
 					So(len(prbBuildTypes), ShouldEqual, 4)
 					So(prbBuildTypes[0].Id, ShouldEqual, "bt101")
@@ -172,9 +189,9 @@ func TestServer_GetRunningBuilds(t *testing.T) {
 					Convey("And it should finish any lastBuilds no longer in builds list", func() {
 						Convey("And it should return useful builds", func() {
 							So(len(resultBuilds), ShouldEqual, 3)
-							So(resultBuilds[0].ID, ShouldEqual, 101)
-							So(resultBuilds[1].ID, ShouldEqual, 104)
-							So(resultBuilds[2].ID, ShouldEqual, 105)
+							So(resultBuilds[0].Id, ShouldEqual, 101)
+							So(resultBuilds[1].Id, ShouldEqual, 104)
+							So(resultBuilds[2].Id, ShouldEqual, 105)
 						})
 					})
 				})
@@ -186,23 +203,23 @@ func TestServer_GetRunningBuilds(t *testing.T) {
 func TestServer_ProcessRunningBuild(t *testing.T) {
 	Convey("Given a server", t, func() {
 		log := logrus.WithField("test", "TestServer_ProcessRunningBuild")
-		serverMock := new(ITcClientMock)
+		providerMock := new(IProviderMock)
 		dbMock := new(IDbMock)
 
 		c := tc.Server{
-			Tc:  serverMock,
-			Db:  dbMock,
-			Log: log,
+			Provider: providerMock,
+			Db:       dbMock,
+			Log:      log,
 		}
 
 		Convey("When we have a build type without any branches", func() {
 
-			tcBuild := teamcity.Build{
-				ID:          801,
-				BuildTypeID: "bt-id-801",
+			tcBuild := ci.Build{
+				Id:          801,
+				BuildTypeId: "bt-id-801",
 				BranchName:  "this is a b-name",
 				Number:      "tc-build-number",
-				Status:      teamcity.StatusRunning,
+				Status:      db.StatusRunning,
 				StatusText:  "this will show up some place",
 				Progress:    102,
 				StartDate:   time.Unix(1507141495, 0),
@@ -226,7 +243,7 @@ func TestServer_ProcessRunningBuild(t *testing.T) {
 					So(branchesPassedToDb[0].Name, ShouldEqual, tcBuild.BranchName)
 
 					So(len(branchesPassedToDb[0].Builds), ShouldEqual, 1)
-					So(branchesPassedToDb[0].Builds[0].Id, ShouldEqual, tcBuild.ID)
+					So(branchesPassedToDb[0].Builds[0].Id, ShouldEqual, tcBuild.Id)
 					So(branchesPassedToDb[0].Builds[0].Number, ShouldEqual, tcBuild.Number)
 					So(branchesPassedToDb[0].Builds[0].Status, ShouldEqual, tcBuild.Status)
 					So(branchesPassedToDb[0].Builds[0].StatusText, ShouldEqual, tcBuild.StatusText)
@@ -241,9 +258,9 @@ func TestServer_ProcessRunningBuild(t *testing.T) {
 
 			startDate := time.Now().Add(-1 * time.Minute)
 
-			tcBuild := teamcity.Build{
-				ID:          801,
-				BuildTypeID: "bt-id-801",
+			tcBuild := ci.Build{
+				Id:          801,
+				BuildTypeId: "bt-id-801",
 				BranchName:  "this is a b-name",
 				StartDate:   startDate,
 			}
@@ -278,7 +295,7 @@ func TestServer_ProcessRunningBuild(t *testing.T) {
 					So(branchesPassedToDb[0].Name, ShouldEqual, tcBuild.BranchName)
 
 					So(len(branchesPassedToDb[0].Builds), ShouldEqual, 2)
-					So(branchesPassedToDb[0].Builds[0].Id, ShouldEqual, tcBuild.ID)
+					So(branchesPassedToDb[0].Builds[0].Id, ShouldEqual, tcBuild.Id)
 					So(branchesPassedToDb[0].Builds[0].StartDate.Unix(), ShouldEqual, startDate.Unix())
 					So(branchesPassedToDb[0].Builds[0].FinishDate.Unix(), ShouldAlmostEqual, now.Unix())
 					So(branchesPassedToDb[0].Builds[1].Id, ShouldEqual, 799)
@@ -287,9 +304,9 @@ func TestServer_ProcessRunningBuild(t *testing.T) {
 		})
 
 		Convey("When we have a build type that already has 12 builds and this is a new build", func() {
-			tcBuild := teamcity.Build{
-				ID:          801,
-				BuildTypeID: "bt-id-801",
+			tcBuild := ci.Build{
+				Id:          801,
+				BuildTypeId: "bt-id-801",
 				BranchName:  "this is a b-name",
 			}
 
@@ -335,26 +352,56 @@ func TestServer_ProcessRunningBuild(t *testing.T) {
 					So(branchesPassedToDb[0].Name, ShouldEqual, tcBuild.BranchName)
 
 					So(len(branchesPassedToDb[0].Builds), ShouldEqual, 12)
-					So(branchesPassedToDb[0].Builds[0].Id, ShouldEqual, tcBuild.ID)
+					So(branchesPassedToDb[0].Builds[0].Id, ShouldEqual, tcBuild.Id)
 					So(branchesPassedToDb[0].Builds[1].Id, ShouldEqual, 800)
 					So(branchesPassedToDb[0].Builds[2].Id, ShouldEqual, 799)
 					So(branchesPassedToDb[0].Builds[11].Id, ShouldEqual, 790)
 				})
 			})
 		})
+
+		Convey("When the monitor keeps fewer builds and has a branch that went quiet", func() {
+			c.HistoryDepth = 2
+			c.BranchRetention = 30 * 24 * time.Hour
+
+			tcBuild := ci.Build{Id: 801, BuildTypeId: "bt-id-801", BranchName: "main", Status: db.StatusRunning}
+			old := time.Now().Add(-60 * 24 * time.Hour)
+
+			dbBuildType := db.BuildType{
+				Id: "bt-id-801",
+				Branches: []db.Branch{
+					{Name: "main", Builds: []db.Build{{Id: 800}, {Id: 799}}},
+					{Name: "feature/old", Builds: []db.Build{{Id: 12, StartDate: old, FinishDate: old}}},
+				},
+			}
+
+			var branchesPassedToDb []db.Branch
+			dbMock.On("UpdateBuildTypeBuilds", dbBuildType.Id, mock.AnythingOfType("[]db.Branch")).Return(nil, nil).Run(func(args mock.Arguments) {
+				branchesPassedToDb = args.Get(1).([]db.Branch)
+			})
+
+			tc.ProcessRunningBuild(&c, tcBuild, &dbBuildType)
+
+			Convey("It will keep the monitor's depth of builds and drop the quiet branch", func() {
+				So(len(branchesPassedToDb), ShouldEqual, 1)
+				So(len(branchesPassedToDb[0].Builds), ShouldEqual, 2)
+				So(branchesPassedToDb[0].Builds[0].Id, ShouldEqual, 801)
+				So(branchesPassedToDb[0].Builds[1].Id, ShouldEqual, 800)
+			})
+		})
 	})
 }
 
 func TestServer_GetBuildHistory(t *testing.T) {
 	Convey("Given a server", t, func() {
 		log := logrus.WithField("test", "TestServer_GetBuildHistory")
-		serverMock := new(ITcClientMock)
+		providerMock := new(IProviderMock)
 		dbMock := new(IDbMock)
 
 		c := tc.Server{
-			Tc:  serverMock,
-			Db:  dbMock,
-			Log: log,
+			Provider: providerMock,
+			Db:       dbMock,
+			Log:      log,
 		}
 
 		Convey("When DashboardList errors", func() {
@@ -377,7 +424,7 @@ func TestServer_GetBuildHistory(t *testing.T) {
 				tc.GetBuildHistory(&c)
 
 				dbMock.AssertExpectations(t)
-				serverMock.AssertExpectations(t)
+				providerMock.AssertExpectations(t)
 			})
 		})
 
@@ -393,7 +440,20 @@ func TestServer_GetBuildHistory(t *testing.T) {
 				tc.GetBuildHistory(&c)
 
 				dbMock.AssertExpectations(t)
-				serverMock.AssertExpectations(t)
+				providerMock.AssertExpectations(t)
+			})
+		})
+
+		Convey("When BuildTypeList errors", func() {
+			expectedErr := errors.New("no build types")
+			dbMock.On("DashboardList").Return([]db.Dashboard{{Id: "cool 1", BuildConfigs: []db.BuildConfig{{Id: "bcfg1"}}}}, nil)
+			dbMock.On("BuildTypeList").Return(nil, expectedErr)
+
+			err := tc.GetBuildHistory(&c)
+
+			Convey("It should return the error", func() {
+				So(err, ShouldEqual, expectedErr)
+				providerMock.AssertExpectations(t)
 			})
 		})
 
@@ -402,58 +462,60 @@ func TestServer_GetBuildHistory(t *testing.T) {
 			bcfg2 := db.BuildConfig{Id: "bcfg2"}
 			bcfg3 := db.BuildConfig{Id: "bcfg3"}
 			bcfg4 := db.BuildConfig{Id: "bcfg4"}
+			bcfg5 := db.BuildConfig{Id: "jenkins:bcfg5"}
 
 			dashboards := []db.Dashboard{
 				{Id: "cool 1", BuildConfigs: []db.BuildConfig{bcfg1, bcfg2, bcfg3}},
-				{Id: "cool 2", BuildConfigs: []db.BuildConfig{bcfg1, bcfg2, bcfg4}},
+				{Id: "cool 2", BuildConfigs: []db.BuildConfig{bcfg1, bcfg2, bcfg4, bcfg5}},
 			}
 
 			dbMock.On("DashboardList").Return(dashboards, nil)
+			dbMock.On("BuildTypeList").Return([]db.BuildType{{Id: "bcfg1"}, {Id: "bcfg2"}, {Id: "bcfg3"}, {Id: "bcfg4"}, {Id: "jenkins:bcfg5", Source: "jenkins"}}, nil)
 
 			Convey("And there are no builds", func() {
-				builds := []teamcity.Build{}
+				builds := []ci.Build{}
 
-				serverMock.On("GetBuildsForBuildType", "bcfg1", 1000).Times(1).Return(builds, nil)
-				serverMock.On("GetBuildsForBuildType", "bcfg2", 1000).Times(1).Return(nil, errors.New("just and error to be ignored"))
-				serverMock.On("GetBuildsForBuildType", "bcfg3", 1000).Times(1).Return(builds, nil)
-				serverMock.On("GetBuildsForBuildType", "bcfg4", 1000).Times(1).Return(builds, nil)
+				providerMock.On("GetBuildsForBuildType", "bcfg1", 1000).Times(1).Return(builds, nil)
+				providerMock.On("GetBuildsForBuildType", "bcfg2", 1000).Times(1).Return(nil, errors.New("just and error to be ignored"))
+				providerMock.On("GetBuildsForBuildType", "bcfg3", 1000).Times(1).Return(builds, nil)
+				providerMock.On("GetBuildsForBuildType", "bcfg4", 1000).Times(1).Return(builds, nil)
 
-				Convey("It should call GetBuildsForBuildType once for each build config", func() {
+				Convey("It should call GetBuildsForBuildType once for each build config owned by this monitor", func() {
 					tc.GetBuildHistory(&c)
 
 					dbMock.AssertExpectations(t)
-					serverMock.AssertExpectations(t)
+					providerMock.AssertExpectations(t)
 				})
 			})
 
 			Convey("And there are builds", func() {
-				b1 := teamcity.Build{BranchName: "dev", ID: 121}
-				b2 := teamcity.Build{BranchName: "feat", ID: 122}
-				b3 := teamcity.Build{BranchName: "dev", ID: 123}
-				b4 := teamcity.Build{BranchName: "dev", ID: 124}
-				b5 := teamcity.Build{BranchName: "feat", ID: 125}
-				b6 := teamcity.Build{BranchName: "feat", ID: 126}
-
-				serverMock.On("GetBuildsForBuildType", "bcfg1", 1000).Times(1).Return([]teamcity.Build{b1, b2, b3, b4}, nil)
-				serverMock.On("GetBuildsForBuildType", "bcfg2", 1000).Times(1).Return([]teamcity.Build{b2, b3}, nil)
-				serverMock.On("GetBuildsForBuildType", "bcfg3", 1000).Times(1).Return([]teamcity.Build{b1, b5, b3, b6, b2}, nil)
-				serverMock.On("GetBuildsForBuildType", "bcfg4", 1000).Times(1).Return([]teamcity.Build{b1, b2, b6, b5}, nil)
+				b1 := ci.Build{BranchName: "dev", Id: 121}
+				b2 := ci.Build{BranchName: "feat", Id: 122}
+				b3 := ci.Build{BranchName: "dev", Id: 123}
+				b4 := ci.Build{BranchName: "dev", Id: 124}
+				b5 := ci.Build{BranchName: "feat", Id: 125}
+				b6 := ci.Build{BranchName: "feat", Id: 126}
+
+				providerMock.On("GetBuildsForBuildType", "bcfg1", 1000).Times(1).Return([]ci.Build{b1, b2, b3, b4}, nil)
+				providerMock.On("GetBuildsForBuildType", "bcfg2", 1000).Times(1).Return([]ci.Build{b2, b3}, nil)
+				providerMock.On("GetBuildsForBuildType", "bcfg3", 1000).Times(1).Return([]ci.Build{b1, b5, b3, b6, b2}, nil)
+				providerMock.On("GetBuildsForBuildType", "bcfg4", 1000).Times(1).Return([]ci.Build{b1, b2, b6, b5}, nil)
 
 				var dbArray1 []db.Branch
 				var dbArray2 []db.Branch
 				var dbArray3 []db.Branch
 				var dbArray4 []db.Branch
 
-				dbMock.On("UpdateBuildTypeBuilds", "bcfg1", mock.Anything).Times(1).Return(nil, nil).Run(func(args mock.Arguments) {
+				dbMock.On("UpdateBuildTypeHistory", "bcfg1", mock.Anything, mock.Anything).Times(1).Return(nil, nil).Run(func(args mock.Arguments) {
 					dbArray1 = args.Get(1).([]db.Branch)
 				})
-				dbMock.On("UpdateBuildTypeBuilds", "bcfg2", mock.Anything).Times(1).Return(nil, nil).Run(func(args mock.Arguments) {
+				dbMock.On("UpdateBuildTypeHistory", "bcfg2", mock.Anything, mock.Anything).Times(1).Return(nil, nil).Run(func(args mock.Arguments) {
 					dbArray2 = args.Get(1).([]db.Branch)
 				})
-				dbMock.On("UpdateBuildTypeBuilds", "bcfg3", mock.Anything).Times(1).Return(nil, errors.New("error to log and ignore")).Run(func(args mock.Arguments) {
+				dbMock.On("UpdateBuildTypeHistory", "bcfg3", mock.Anything, mock.Anything).Times(1).Return(nil, errors.New("error to log and ignore")).Run(func(args mock.Arguments) {
 					dbArray3 = args.Get(1).([]db.Branch)
 				})
-				dbMock.On("UpdateBuildTypeBuilds", "bcfg4", mock.Anything).Times(1).Return(nil, nil).Run(func(args mock.Arguments) {
+				dbMock.On("UpdateBuildTypeHistory", "bcfg4", mock.Anything, mock.Anything).Times(1).Return(nil, nil).Run(func(args mock.Arguments) {
 					dbArray4 = args.Get(1).([]db.Branch)
 				})
 
@@ -462,7 +524,7 @@ func TestServer_GetBuildHistory(t *testing.T) {
 					tc.GetBuildHistory(&c)
 
 					dbMock.AssertExpectations(t)
-					serverMock.AssertExpectations(t)
+					providerMock.AssertExpectations(t)
 
 					So(len(dbArray1), ShouldEqual, 2)
 					So(len(getBranch("dev", dbArray1).Builds), ShouldEqual, 3)
@@ -483,42 +545,42 @@ func TestServer_GetBuildHistory(t *testing.T) {
 			})
 
 			Convey("And there are > 12 builds", func() {
-				b01 := teamcity.Build{BranchName: "dev", ID: 121}
-				b02 := teamcity.Build{BranchName: "dev", ID: 122}
-				b03 := teamcity.Build{BranchName: "dev", ID: 123}
-				b04 := teamcity.Build{BranchName: "dev", ID: 124}
-				b05 := teamcity.Build{BranchName: "dev", ID: 125}
-				b06 := teamcity.Build{BranchName: "dev", ID: 126}
-				b07 := teamcity.Build{BranchName: "dev", ID: 127}
-				b08 := teamcity.Build{BranchName: "dev", ID: 128}
-				b09 := teamcity.Build{BranchName: "dev", ID: 129}
-				b10 := teamcity.Build{BranchName: "dev", ID: 130}
-				b11 := teamcity.Build{BranchName: "dev", ID: 131}
-				b12 := teamcity.Build{BranchName: "dev", ID: 132}
-				b13 := teamcity.Build{BranchName: "dev", ID: 133}
-
-				allBuilds := []teamcity.Build{b01, b02, b03, b04, b05, b06, b07, b08, b09, b10, b11, b12, b13}
-
-				serverMock.On("GetBuildsForBuildType", "bcfg1", 1000).Times(1).Return(allBuilds, nil)
-				serverMock.On("GetBuildsForBuildType", "bcfg2", 1000).Times(1).Return(allBuilds, nil)
-				serverMock.On("GetBuildsForBuildType", "bcfg3", 1000).Times(1).Return(allBuilds, nil)
-				serverMock.On("GetBuildsForBuildType", "bcfg4", 1000).Times(1).Return(allBuilds, nil)
+				b01 := ci.Build{BranchName: "dev", Id: 121}
+				b02 := ci.Build{BranchName: "dev", Id: 122}
+				b03 := ci.Build{BranchName: "dev", Id: 123}
+				b04 := ci.Build{BranchName: "dev", Id: 124}
+				b05 := ci.Build{BranchName: "dev", Id: 125}
+				b06 := ci.Build{BranchName: "dev", Id: 126}
+				b07 := ci.Build{BranchName: "dev", Id: 127}
+				b08 := ci.Build{BranchName: "dev", Id: 128}
+				b09 := ci.Build{BranchName: "dev", Id: 129}
+				b10 := ci.Build{BranchName: "dev", Id: 130}
+				b11 := ci.Build{BranchName: "dev", Id: 131}
+				b12 := ci.Build{BranchName: "dev", Id: 132}
+				b13 := ci.Build{BranchName: "dev", Id: 133}
+
+				allBuilds := []ci.Build{b01, b02, b03, b04, b05, b06, b07, b08, b09, b10, b11, b12, b13}
+
+				providerMock.On("GetBuildsForBuildType", "bcfg1", 1000).Times(1).Return(allBuilds, nil)
+				providerMock.On("GetBuildsForBuildType", "bcfg2", 1000).Times(1).Return(allBuilds, nil)
+				providerMock.On("GetBuildsForBuildType", "bcfg3", 1000).Times(1).Return(allBuilds, nil)
+				providerMock.On("GetBuildsForBuildType", "bcfg4", 1000).Times(1).Return(allBuilds, nil)
 
 				var dbArray1 []db.Branch
 				var dbArray2 []db.Branch
 				var dbArray3 []db.Branch
 				var dbArray4 []db.Branch
 
-				dbMock.On("UpdateBuildTypeBuilds", "bcfg1", mock.Anything).Times(1).Return(nil, nil).Run(func(args mock.Arguments) {
+				dbMock.On("UpdateBuildTypeHistory", "bcfg1", mock.Anything, mock.Anything).Times(1).Return(nil, nil).Run(func(args mock.Arguments) {
 					dbArray1 = args.Get(1).([]db.Branch)
 				})
-				dbMock.On("UpdateBuildTypeBuilds", "bcfg2", mock.Anything).Times(1).Return(nil, nil).Run(func(args mock.Arguments) {
+				dbMock.On("UpdateBuildTypeHistory", "bcfg2", mock.Anything, mock.Anything).Times(1).Return(nil, nil).Run(func(args mock.Arguments) {
 					dbArray2 = args.Get(1).([]db.Branch)
 				})
-				dbMock.On("UpdateBuildTypeBuilds", "bcfg3", mock.Anything).Times(1).Return(nil, errors.New("error to log and ignore")).Run(func(args mock.Arguments) {
+				dbMock.On("UpdateBuildTypeHistory", "bcfg3", mock.Anything, mock.Anything).Times(1).Return(nil, errors.New("error to log and ignore")).Run(func(args mock.Arguments) {
 					dbArray3 = args.Get(1).([]db.Branch)
 				})
-				dbMock.On("UpdateBuildTypeBuilds", "bcfg4", mock.Anything).Times(1).Return(nil, nil).Run(func(args mock.Arguments) {
+				dbMock.On("UpdateBuildTypeHistory", "bcfg4", mock.Anything, mock.Anything).Times(1).Return(nil, nil).Run(func(args mock.Arguments) {
 					dbArray4 = args.Get(1).([]db.Branch)
 				})
 
@@ -527,7 +589,7 @@ func TestServer_GetBuildHistory(t *testing.T) {
 					tc.GetBuildHistory(&c)
 
 					dbMock.AssertExpectations(t)
-					serverMock.AssertExpectations(t)
+					providerMock.AssertExpectations(t)
 
 					So(len(dbArray1), ShouldEqual, 1)
 					So(dbArray1[0].Name, ShouldEqual, "dev")
@@ -550,6 +612,249 @@ func TestServer_GetBuildHistory(t *testing.T) {
 	})
 }
 
+func TestServer_GetBuildHistory_Incremental(t *testing.T) {
+	Convey("Given a monitor for a provider that can list the builds since another", t, func() {
+		log := logrus.WithField("test", "TestServer_GetBuildHistory_Incremental")
+		providerMock := new(IIncrementalProviderMock)
+		dbMock := new(IDbMock)
+
+		c := tc.Server{
+			Provider: providerMock,
+			Db:       dbMock,
+			Log:      log,
+		}
+
+		dbMock.On("DashboardList").Return([]db.Dashboard{{Id: "cool 1", BuildConfigs: []db.BuildConfig{{Id: "bcfg1"}}}}, nil)
+
+		Convey("When the build type has been synced before", func() {
+			dbMock.On("BuildTypeList").Return([]db.BuildType{{Id: "bcfg1", LastBuildId: 130}}, nil)
+
+			stored := db.BuildType{
+				Id:          "bcfg1",
+				LastBuildId: 130,
+				Branches: []db.Branch{
+					{Name: "dev", Builds: []db.Build{{Id: 130, Status: db.StatusSuccess}, {Id: 129, Status: db.StatusFailure}}},
+				},
+			}
+
+			Convey("And there are new builds", func() {
+				providerMock.On("GetBuildsSince", "bcfg1", 130, 1000).Return([]ci.Build{
+					{Id: 132, BranchName: "dev", Status: db.StatusRunning},
+					{Id: 131, BranchName: "feat", Status: db.StatusSuccess},
+				}, nil)
+				dbMock.On("FindBuildTypeById", "bcfg1").Return(&stored, nil)
+
+				var branches []db.Branch
+				dbMock.On("UpdateBuildTypeHistory", "bcfg1", mock.Anything, 131).Return(nil, nil).Run(func(args mock.Arguments) {
+					branches = args.Get(1).([]db.Branch)
+				})
+
+				err := tc.GetBuildHistory(&c)
+
+				Convey("It should only ask for the new builds and merge them into the stored history", func() {
+					So(err, ShouldBeNil)
+					dbMock.AssertExpectations(t)
+					providerMock.AssertExpectations(t)
+					providerMock.AssertNotCalled(t, "GetBuildsForBuildType", mock.Anything, mock.Anything)
+
+					dev := getBranch("dev", branches)
+					So(len(dev.Builds), ShouldEqual, 3)
+					So(dev.Builds[0].Id, ShouldEqual, 132)
+					So(dev.IsRunning, ShouldBeTrue)
+					So(getBranch("feat", branches).Builds[0].Id, ShouldEqual, 131)
+				})
+			})
+
+			Convey("And there are no new builds", func() {
+				providerMock.On("GetBuildsSince", "bcfg1", 130, 1000).Return([]ci.Build{}, nil)
+				dbMock.On("FindBuildTypeById", "bcfg1").Return(&stored, nil)
+
+				tc.GetBuildHistory(&c)
+
+				Convey("It should leave the stored history alone", func() {
+					dbMock.AssertNotCalled(t, "UpdateBuildTypeHistory", mock.Anything, mock.Anything, mock.Anything)
+				})
+			})
+
+			Convey("And there are no new builds but a branch is past the retention", func() {
+				c.BranchRetention = 30 * 24 * time.Hour
+				old := time.Now().Add(-60 * 24 * time.Hour)
+				recent := time.Now().Add(-time.Hour)
+
+				stored.Branches = []db.Branch{
+					{Name: "dev", Builds: []db.Build{{Id: 130, Status: db.StatusSuccess, FinishDate: recent}}},
+					{Name: "feature/dead", Builds: []db.Build{{Id: 90, Status: db.StatusSuccess, FinishDate: old}}},
+				}
+
+				providerMock.On("GetBuildsSince", "bcfg1", 130, 1000).Return([]ci.Build{}, nil)
+				dbMock.On("FindBuildTypeById", "bcfg1").Return(&stored, nil)
+
+				var branches []db.Branch
+				dbMock.On("UpdateBuildTypeHistory", "bcfg1", mock.Anything, 130).Return(nil, nil).Run(func(args mock.Arguments) {
+					branches = args.Get(1).([]db.Branch)
+				})
+
+				tc.GetBuildHistory(&c)
+
+				Convey("It should drop the branch all the same", func() {
+					dbMock.AssertExpectations(t)
+					So(len(branches), ShouldEqual, 1)
+					So(branches[0].Name, ShouldEqual, "dev")
+				})
+			})
+
+			Convey("And a dashboard asks for a deeper history", func() {
+				providerMock.On("GetBuildsSince", "bcfg1", 130, 1000).Return([]ci.Build{}, nil)
+				providerMock.On("GetBuildsForBuildType", "bcfg1", 1000).Return([]ci.Build{
+					{Id: 130, BranchName: "dev", Status: db.StatusSuccess},
+					{Id: 129, BranchName: "dev", Status: db.StatusFailure},
+					{Id: 128, BranchName: "dev", Status: db.StatusSuccess},
+				}, nil)
+				dbMock.On("FindBuildTypeById", "bcfg1").Return(&stored, nil)
+
+				tc.GetBuildHistory(&c)
+
+				deeperDbMock := new(IDbMock)
+				deeperDbMock.On("DashboardList").Return([]db.Dashboard{{Id: "cool 1", HistoryDepth: 20, BuildConfigs: []db.BuildConfig{{Id: "bcfg1"}}}}, nil)
+				deeperDbMock.On("BuildTypeList").Return([]db.BuildType{{Id: "bcfg1", LastBuildId: 130}}, nil)
+				deeperDbMock.On("FindBuildTypeById", "bcfg1").Return(&stored, nil)
+				deeperDbMock.On("UpdateBuildTypeHistory", "bcfg1", mock.Anything, 130).Return(nil, nil)
+				c.Db = deeperDbMock
+
+				tc.GetBuildHistory(&c)
+				tc.GetBuildHistory(&c)
+
+				Convey("It should read its whole history once to fill the extra depth", func() {
+					providerMock.AssertNumberOfCalls(t, "GetBuildsForBuildType", 1)
+					providerMock.AssertNumberOfCalls(t, "GetBuildsSince", 2)
+				})
+			})
+
+			Convey("And the provider can not find the last build anymore", func() {
+				providerMock.On("GetBuildsSince", "bcfg1", 130, 1000).Return(nil, errors.New("no build found by locator"))
+				providerMock.On("GetBuildsForBuildType", "bcfg1", 1000).Return([]ci.Build{{Id: 135, BranchName: "dev", Status: db.StatusSuccess}}, nil)
+				dbMock.On("UpdateBuildTypeHistory", "bcfg1", mock.Anything, 135).Return(nil, nil)
+
+				tc.GetBuildHistory(&c)
+
+				Convey("It should fall back to reading all of them", func() {
+					dbMock.AssertExpectations(t)
+					providerMock.AssertExpectations(t)
+				})
+			})
+
+			Convey("And a full resync is asked for", func() {
+				providerMock.On("GetBuildsForBuildType", "bcfg1", 1000).Return([]ci.Build{
+					{Id: 133, BranchName: "dev", Status: db.StatusSuccess},
+					{Id: 132, BranchName: "dev", Status: db.StatusRunning},
+					{Id: 131, BranchName: "feat", Status: db.StatusSuccess},
+				}, nil)
+				dbMock.On("UpdateBuildTypeHistory", "bcfg1", mock.Anything, 131).Return(nil, nil)
+
+				tc.GetFullBuildHistory(&c)
+
+				Convey("It should read all of them and sync from before the oldest running build next time", func() {
+					dbMock.AssertExpectations(t)
+					providerMock.AssertExpectations(t)
+					providerMock.AssertNotCalled(t, "GetBuildsSince", mock.Anything, mock.Anything, mock.Anything)
+				})
+			})
+		})
+
+		Convey("When the build type has never been synced", func() {
+			dbMock.On("BuildTypeList").Return([]db.BuildType{{Id: "bcfg1"}}, nil)
+			providerMock.On("GetBuildsForBuildType", "bcfg1", 1000).Return([]ci.Build{{Id: 7, BranchName: "dev", Status: db.StatusFailure}}, nil)
+			dbMock.On("UpdateBuildTypeHistory", "bcfg1", mock.Anything, 7).Return(nil, nil)
+
+			tc.GetBuildHistory(&c)
+
+			Convey("It should read all of its builds", func() {
+				dbMock.AssertExpectations(t)
+				providerMock.AssertExpectations(t)
+			})
+		})
+	})
+}
+
+func TestServer_GetBuildHistory_Parallel(t *testing.T) {
+	Convey("Given a monitor that syncs three build types at once", t, func() {
+		log := logrus.WithField("test", "TestServer_GetBuildHistory_Parallel")
+		providerMock := new(IProviderMock)
+		dbMock := new(IDbMock)
+
+		c := tc.Server{
+			Provider:        providerMock,
+			Db:              dbMock,
+			Log:             log,
+			SyncConcurrency: 3,
+		}
+
+		buildTypes := []db.BuildType{}
+		buildConfigs := []db.BuildConfig{}
+		for i := 1; i <= 6; i++ {
+			id := fmt.Sprintf("bcfg%d", i)
+			buildTypes = append(buildTypes, db.BuildType{Id: id})
+			buildConfigs = append(buildConfigs, db.BuildConfig{Id: id})
+		}
+
+		dbMock.On("DashboardList").Return([]db.Dashboard{{Id: "cool 1", BuildConfigs: buildConfigs}}, nil)
+		dbMock.On("BuildTypeList").Return(buildTypes, nil)
+
+		var mu sync.Mutex
+		inFlight, mostInFlight := 0, 0
+		track := func(mock.Arguments) {
+			mu.Lock()
+			inFlight++
+			if inFlight > mostInFlight {
+				mostInFlight = inFlight
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond * 20)
+
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}
+
+		for i := 1; i <= 6; i++ {
+			id := fmt.Sprintf("bcfg%d", i)
+			if i == 2 || i == 5 {
+				providerMock.On("GetBuildsForBuildType", id, 1000).Return(nil, errors.New("502 Bad Gateway")).Run(track)
+				continue
+			}
+
+			providerMock.On("GetBuildsForBuildType", id, 1000).Return([]ci.Build{{Id: i, BranchName: "dev"}}, nil).Run(track)
+			dbMock.On("UpdateBuildTypeHistory", id, mock.Anything, i).Return(nil, nil)
+		}
+
+		err := tc.GetBuildHistory(&c)
+
+		Convey("It should sync every build type without going over the concurrency", func() {
+			So(err, ShouldBeNil)
+			dbMock.AssertExpectations(t)
+			providerMock.AssertExpectations(t)
+
+			So(mostInFlight, ShouldBeGreaterThan, 1)
+			So(mostInFlight, ShouldBeLessThanOrEqualTo, 3)
+		})
+
+		Convey("It should report the build types that failed in the sync summary", func() {
+			summary := c.Status().LastSync
+
+			So(summary, ShouldNotBeNil)
+			So(summary.Full, ShouldBeFalse)
+			So(summary.BuildTypes, ShouldEqual, 6)
+			So(summary.Synced, ShouldEqual, 4)
+			So(summary.Errors, ShouldResemble, []ci.SyncError{
+				{BuildTypeId: "bcfg2", Message: "502 Bad Gateway"},
+				{BuildTypeId: "bcfg5", Message: "502 Bad Gateway"},
+			})
+			So(summary.FinishedAt.Before(summary.StartedAt), ShouldBeFalse)
+		})
+	})
+}
+
 func getBranch(name string, branches []db.Branch) db.Branch {
 	for _, b := range branches {
 		if b.Name == name {
@@ -559,3 +864,127 @@ func getBranch(name string, branches []db.Branch) db.Branch {
 
 	return db.Branch{}
 }
+
+func TestServer_GetBuildHistory_Retention(t *testing.T) {
+	Convey("Given a monitor that keeps 2 builds per branch for a month", t, func() {
+		log := logrus.WithField("test", "TestServer_GetBuildHistory_Retention")
+		providerMock := new(IProviderMock)
+		dbMock := new(IDbMock)
+
+		c := tc.Server{
+			Provider:        providerMock,
+			Db:              dbMock,
+			Log:             log,
+			HistoryDepth:    2,
+			BranchRetention: 30 * 24 * time.Hour,
+		}
+
+		recent := time.Now().Add(-time.Hour)
+		old := time.Now().Add(-60 * 24 * time.Hour)
+
+		builds := []ci.Build{
+			{Id: 9, BranchName: "main", Status: db.StatusSuccess, StartDate: recent, FinishDate: recent},
+			{Id: 8, BranchName: "main", Status: db.StatusSuccess, StartDate: recent, FinishDate: recent},
+			{Id: 7, BranchName: "main", Status: db.StatusFailure, StartDate: recent, FinishDate: recent},
+			{Id: 6, BranchName: "main", Status: db.StatusSuccess, StartDate: recent, FinishDate: recent},
+			{Id: 2, BranchName: "feature/dead", Status: db.StatusSuccess, StartDate: old, FinishDate: old},
+		}
+
+		dbMock.On("BuildTypeList").Return([]db.BuildType{{Id: "bt1"}, {Id: "bt2"}}, nil)
+		providerMock.On("GetBuildsForBuildType", "bt1", 1000).Return(builds, nil)
+		providerMock.On("GetBuildsForBuildType", "bt2", 1000).Return(builds, nil)
+
+		stored := make(map[string][]db.Branch)
+		dbMock.On("UpdateBuildTypeHistory", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Run(func(args mock.Arguments) {
+			stored[args.String(0)] = args.Get(1).([]db.Branch)
+		})
+
+		Convey("When a dashboard asks for more builds of one of the build types", func() {
+			dbMock.On("DashboardList").Return([]db.Dashboard{
+				{Id: "d1", BuildConfigs: []db.BuildConfig{{Id: "bt1"}, {Id: "bt2"}}},
+				{Id: "d2", HistoryDepth: 3, BuildConfigs: []db.BuildConfig{{Id: "bt2"}}},
+			}, nil)
+
+			err := tc.GetBuildHistory(&c)
+
+			Convey("It should keep the most builds any of its dashboards asks for", func() {
+				So(err, ShouldBeNil)
+				So(len(getBranch("main", stored["bt1"]).Builds), ShouldEqual, 2)
+				So(len(getBranch("main", stored["bt2"]).Builds), ShouldEqual, 3)
+			})
+
+			Convey("It should drop the branches whose last build is older than the retention", func() {
+				So(len(stored["bt1"]), ShouldEqual, 1)
+				So(stored["bt1"][0].Name, ShouldEqual, "main")
+			})
+		})
+	})
+}
+
+func TestServer_GetDashboardHistory(t *testing.T) {
+	Convey("Given a monitor with two dashboards", t, func() {
+		log := logrus.WithField("test", "TestServer_GetDashboardHistory")
+		providerMock := new(IProviderMock)
+		dbMock := new(IDbMock)
+
+		c := tc.Server{
+			Provider: providerMock,
+			Db:       dbMock,
+			Log:      log,
+		}
+
+		dbMock.On("DashboardList").Return([]db.Dashboard{
+			{Id: "d1", BuildConfigs: []db.BuildConfig{{Id: "bt1"}}},
+			{Id: "d2", HistoryDepth: 20, BuildConfigs: []db.BuildConfig{{Id: "bt2"}, {Id: "bt3"}, {Id: "jenkins:job"}}},
+		}, nil)
+		dbMock.On("BuildTypeList").Return([]db.BuildType{{Id: "bt1"}, {Id: "bt2"}, {Id: "bt3"}, {Id: "jenkins:job", Source: "jenkins"}}, nil)
+
+		builds := []ci.Build{{Id: 1, BranchName: "main", Status: db.StatusSuccess}}
+		providerMock.On("GetBuildsForBuildType", "bt2", 1000).Return(builds, nil)
+		providerMock.On("GetBuildsForBuildType", "bt3", 1000).Return(nil, errors.New("bt3 is broken"))
+		dbMock.On("UpdateBuildTypeHistory", "bt2", mock.Anything, mock.Anything).Return(nil, nil)
+
+		Convey("When one dashboard is synced", func() {
+			summary, err := tc.GetDashboardHistory(&c, "d2")
+
+			Convey("It should only sync its build types this monitor owns and report the outcome", func() {
+				So(err, ShouldBeNil)
+				So(summary.Full, ShouldBeFalse)
+				So(summary.BuildTypes, ShouldEqual, 2)
+				So(summary.Synced, ShouldEqual, 1)
+				So(summary.Errors, ShouldResemble, []ci.SyncError{{BuildTypeId: "bt3", Message: "bt3 is broken"}})
+
+				providerMock.AssertNotCalled(t, "GetBuildsForBuildType", "bt1", 1000)
+				dbMock.AssertExpectations(t)
+			})
+
+			Convey("It should not replace the summary of the last full sync", func() {
+				So(c.Status().LastSync, ShouldBeNil)
+			})
+		})
+
+		Convey("When the dashboard does not exist", func() {
+			_, err := tc.GetDashboardHistory(&c, "gone")
+
+			Convey("It should say so", func() {
+				So(err, ShouldEqual, ci.ErrDashboardNotFound)
+			})
+		})
+
+		Convey("When one build type is synced", func() {
+			summary, err := tc.GetBuildTypeHistory(&c, "bt2")
+			_, otherErr := tc.GetBuildTypeHistory(&c, "jenkins:job")
+
+			Convey("It should only sync that build type", func() {
+				So(err, ShouldBeNil)
+				So(summary.BuildTypes, ShouldEqual, 1)
+				So(summary.Synced, ShouldEqual, 1)
+				providerMock.AssertNotCalled(t, "GetBuildsForBuildType", "bt3", 1000)
+			})
+
+			Convey("It should not sync a build type another monitor owns", func() {
+				So(otherErr, ShouldEqual, ci.ErrBuildTypeNotFound)
+			})
+		})
+	})
+}
@@ -0,0 +1,109 @@
+package tc
+
+import (
+	"sync"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+)
+
+// maxCachedBuilds bounds each map of the build cache, a full map is simply emptied
+const maxCachedBuilds = 5000
+
+// buildCache remembers the details already read for a build so every poll and sync does not ask again.
+// A nil cache remembers nothing.
+type buildCache struct {
+	mu      sync.Mutex
+	changes map[int][]db.Change
+	tests   map[int]db.TestCounts
+	details map[int]ci.BuildDetails
+}
+
+func newBuildCache() *buildCache {
+	return &buildCache{
+		changes: make(map[int][]db.Change),
+		tests:   make(map[int]db.TestCounts),
+		details: make(map[int]ci.BuildDetails),
+	}
+}
+
+func (bc *buildCache) getChanges(id int) ([]db.Change, bool) {
+	if bc == nil {
+		return nil, false
+	}
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	changes, ok := bc.changes[id]
+	return changes, ok
+}
+
+func (bc *buildCache) putChanges(id int, changes []db.Change) {
+	if bc == nil {
+		return
+	}
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if len(bc.changes) >= maxCachedBuilds {
+		bc.changes = make(map[int][]db.Change)
+	}
+
+	bc.changes[id] = changes
+}
+
+func (bc *buildCache) getTests(id int) (db.TestCounts, bool) {
+	if bc == nil {
+		return db.TestCounts{}, false
+	}
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	tests, ok := bc.tests[id]
+	return tests, ok
+}
+
+func (bc *buildCache) putTests(id int, tests db.TestCounts) {
+	if bc == nil {
+		return
+	}
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if len(bc.tests) >= maxCachedBuilds {
+		bc.tests = make(map[int]db.TestCounts)
+	}
+
+	bc.tests[id] = tests
+}
+
+func (bc *buildCache) getDetails(id int) (ci.BuildDetails, bool) {
+	if bc == nil {
+		return ci.BuildDetails{}, false
+	}
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	details, ok := bc.details[id]
+	return details, ok
+}
+
+func (bc *buildCache) putDetails(id int, details ci.BuildDetails) {
+	if bc == nil {
+		return
+	}
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if len(bc.details) >= maxCachedBuilds {
+		bc.details = make(map[int]ci.BuildDetails)
+	}
+
+	bc.details[id] = details
+}
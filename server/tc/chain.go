@@ -0,0 +1,104 @@
+package tc
+
+import (
+	"sort"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+)
+
+// BuildChain asks the provider for the builds around a build and points out the steps that broke it.
+// A chain keeps changing while any of it runs so it is not cached.
+func (c *Server) BuildChain(buildTypeId string, buildId int) (ci.BuildChain, error) {
+	p, ok := c.Provider.(ci.BuildChainer)
+	if !ok {
+		return ci.BuildChain{}, ci.ErrNoBuildChain
+	}
+
+	chain, err := p.GetBuildChain(ci.Build{Id: buildId, BuildTypeId: buildTypeId})
+	if err != nil {
+		return ci.BuildChain{}, err
+	}
+
+	chain.Upstream = orderChain(chain.Upstream)
+	chain.Downstream = orderChain(chain.Downstream)
+	chain.BrokenBuildIds = brokenBuilds(chain)
+
+	return chain, nil
+}
+
+// orderChain puts every build after the builds it depends on, the ones that are free to go are taken
+// oldest first. Dependencies outside the list are already done.
+func orderChain(builds []ci.ChainBuild) []ci.ChainBuild {
+	remaining := append([]ci.ChainBuild{}, builds...)
+	sort.Sort(chainBuildsById(remaining))
+
+	inList := make(map[int]bool)
+	for _, b := range remaining {
+		inList[b.Id] = true
+	}
+
+	ordered := []ci.ChainBuild{}
+	for len(remaining) > 0 {
+		next := 0
+		for i, b := range remaining {
+			if !waitsFor(b, inList) {
+				next = i
+				break
+			}
+		}
+
+		// A cycle can not happen in TeamCity, if one does the oldest build breaks it
+		ordered = append(ordered, remaining[next])
+		delete(inList, remaining[next].Id)
+		remaining = append(remaining[:next], remaining[next+1:]...)
+	}
+
+	return ordered
+}
+
+type chainBuildsById []ci.ChainBuild
+
+func (s chainBuildsById) Len() int {
+	return len(s)
+}
+
+func (s chainBuildsById) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s chainBuildsById) Less(i, j int) bool {
+	return s[i].Id < s[j].Id
+}
+
+func waitsFor(b ci.ChainBuild, pending map[int]bool) bool {
+	for _, id := range b.DependsOn {
+		if pending[id] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// brokenBuilds are the failed builds of a chain that do not depend on another failed build
+func brokenBuilds(chain ci.BuildChain) []int {
+	all := append(append([]ci.ChainBuild{chain.Build}, chain.Upstream...), chain.Downstream...)
+
+	failed := make(map[int]bool)
+	for _, b := range all {
+		if b.Status == db.StatusFailure {
+			failed[b.Id] = true
+		}
+	}
+
+	broken := []int{}
+	for _, b := range all {
+		if failed[b.Id] && !waitsFor(b, failed) {
+			broken = append(broken, b.Id)
+		}
+	}
+
+	sort.Ints(broken)
+	return broken
+}
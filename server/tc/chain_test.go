@@ -0,0 +1,91 @@
+package tc_test
+
+import (
+	"errors"
+	"testing"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+	"build-monitor-v2/server/tc"
+
+	"github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestServer_BuildChain(t *testing.T) {
+	Convey("Given a monitor for a provider with build chains", t, func() {
+		log := logrus.WithField("test", "TestServer_BuildChain")
+		providerMock := new(IChainProviderMock)
+
+		c := tc.NewProviderServer(log, "", providerMock, new(IDbMock), "20s", "5s")
+
+		Convey("When a deploy chain broke in the middle", func() {
+			chain := ci.BuildChain{
+				Build: ci.ChainBuild{Id: 520, BuildTypeId: "deploy", Status: db.StatusFailure, DependsOn: []int{517, 519}},
+				Upstream: []ci.ChainBuild{
+					{Id: 519, BuildTypeId: "integration", Status: db.StatusFailure, DependsOn: []int{518}},
+					{Id: 517, BuildTypeId: "lint", Status: db.StatusSuccess, DependsOn: []int{515}},
+					{Id: 518, BuildTypeId: "unit", Status: db.StatusFailure, DependsOn: []int{515}},
+					{Id: 515, BuildTypeId: "compile", Status: db.StatusSuccess, DependsOn: []int{}},
+				},
+				Downstream: []ci.ChainBuild{
+					{Id: 522, BuildTypeId: "smoke", Status: db.StatusUnknown, DependsOn: []int{521}},
+					{Id: 521, BuildTypeId: "notify", Status: db.StatusUnknown, DependsOn: []int{520}},
+				},
+			}
+			providerMock.On("GetBuildChain", ci.Build{Id: 520, BuildTypeId: "deploy"}).Return(chain, nil)
+
+			result, err := c.BuildChain("deploy", 520)
+
+			Convey("It should put every build after the ones it depends on", func() {
+				So(err, ShouldBeNil)
+				So(chainIds(result.Upstream), ShouldResemble, []int{515, 517, 518, 519})
+				So(chainIds(result.Downstream), ShouldResemble, []int{521, 522})
+			})
+
+			Convey("It should point at the step that broke", func() {
+				So(result.BrokenBuildIds, ShouldResemble, []int{518})
+			})
+		})
+
+		Convey("When the provider can not find the build", func() {
+			providerMock.On("GetBuildChain", ci.Build{Id: 7, BuildTypeId: "deploy"}).Return(ci.BuildChain{}, ci.ErrBuildNotFound)
+
+			_, err := c.BuildChain("deploy", 7)
+
+			Convey("It should return the error", func() {
+				So(err, ShouldEqual, ci.ErrBuildNotFound)
+			})
+		})
+
+		Convey("When the provider fails", func() {
+			expectedErr := errors.New("502 Bad Gateway")
+			providerMock.On("GetBuildChain", ci.Build{Id: 7, BuildTypeId: "deploy"}).Return(ci.BuildChain{}, expectedErr)
+
+			_, err := c.BuildChain("deploy", 7)
+
+			Convey("It should return the error", func() {
+				So(err, ShouldEqual, expectedErr)
+			})
+		})
+
+		Convey("When the provider has no build chains", func() {
+			c.Provider = new(IProviderMock)
+
+			_, err := c.BuildChain("deploy", 7)
+
+			Convey("It should say so", func() {
+				So(err, ShouldEqual, ci.ErrNoBuildChain)
+			})
+		})
+	})
+}
+
+func chainIds(builds []ci.ChainBuild) []int {
+	ids := []int{}
+	for _, b := range builds {
+		ids = append(ids, b.Id)
+	}
+
+	return ids
+}
@@ -0,0 +1,55 @@
+package tc
+
+import (
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+)
+
+// addChanges fills in the changes of the builds since the last successful one, which is who a failing
+// tile points at. The builds are newest first, so a green branch costs nothing. Builds that already have
+// their changes keep them and the ones read from the provider are cached. A personal build passing does not
+// mean the branch was fixed so only a normal one stops the search.
+func addChanges(c *Server, buildTypeId string, builds []db.Build) {
+	p, ok := c.Provider.(ci.ChangeLister)
+	if !ok {
+		return
+	}
+
+	for i, b := range builds {
+		if b.Status == db.StatusSuccess && b.Kind == db.KindNormal {
+			return
+		}
+
+		if b.Changes != nil {
+			continue
+		}
+
+		if changes, ok := c.cache.getChanges(b.Id); ok {
+			builds[i].Changes = changes
+			continue
+		}
+
+		changes, err := p.GetChanges(ci.Build{Id: b.Id, BuildTypeId: buildTypeId})
+		if err != nil {
+			c.Log.Errorf("Failed to get the changes for build: %d, Error: %v", b.Id, err)
+			continue
+		}
+
+		builds[i].Changes = ChangesToDb(changes)
+		c.cache.putChanges(b.Id, builds[i].Changes)
+	}
+}
+
+func ChangesToDb(changes []ci.Change) []db.Change {
+	dbChanges := []db.Change{}
+	for _, ch := range changes {
+		dbChanges = append(dbChanges, db.Change{
+			Revision:  ch.Revision,
+			Author:    ch.Author,
+			Comment:   ch.Comment,
+			FileCount: ch.FileCount,
+		})
+	}
+
+	return dbChanges
+}
@@ -0,0 +1,102 @@
+package tc_test
+
+import (
+	"errors"
+	"testing"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+	"build-monitor-v2/server/tc"
+
+	"github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestServer_BuildChanges(t *testing.T) {
+	Convey("Given a monitor for a provider that lists the changes of a build", t, func() {
+		log := logrus.WithField("test", "TestServer_BuildChanges")
+		dbMock := new(IDbMock)
+		providerMock := new(IChangeProviderMock)
+
+		c := tc.NewProviderServer(log, "jenkins", providerMock, dbMock, "20s", "5s")
+
+		newBuildType := func() *db.BuildType {
+			return &db.BuildType{
+				Id: "bt1",
+				Branches: []db.Branch{{Name: "master", Builds: []db.Build{
+					{Id: 11, Status: db.StatusFailure},
+					{Id: 10, Status: db.StatusSuccess},
+					{Id: 9, Status: db.StatusFailure},
+				}}},
+			}
+		}
+
+		changes := []ci.Change{{Revision: "9f2c1e7", Author: "jdoe", Comment: "Fix the flaky auth test", FileCount: 3}}
+		dbMock.On("UpdateBuildTypeBuilds", "bt1", mock.Anything).Return(nil, nil)
+
+		Convey("When a build starts on a failing branch", func() {
+			providerMock.On("GetChanges", ci.Build{Id: 12, BuildTypeId: "bt1"}).Return(changes, nil)
+			providerMock.On("GetChanges", ci.Build{Id: 11, BuildTypeId: "bt1"}).Return([]ci.Change{}, nil)
+
+			err := tc.ProcessRunningBuild(&c, ci.Build{Id: 12, BuildTypeId: "bt1", BranchName: "master", Status: db.StatusRunning}, newBuildType())
+
+			Convey("It should store the changes of the builds since the last successful one", func() {
+				So(err, ShouldBeNil)
+				providerMock.AssertExpectations(t)
+				providerMock.AssertNumberOfCalls(t, "GetChanges", 2)
+
+				builds := dbMock.Calls[0].Arguments.Get(1).([]db.Branch)[0].Builds
+				So(builds[0].Changes, ShouldResemble, []db.Change{{Revision: "9f2c1e7", Author: "jdoe", Comment: "Fix the flaky auth test", FileCount: 3}})
+				So(builds[1].Changes, ShouldResemble, []db.Change{})
+				So(builds[2].Changes, ShouldBeNil)
+				So(builds[3].Changes, ShouldBeNil)
+			})
+
+			Convey("It should not ask for them again while the build runs", func() {
+				tc.ProcessRunningBuild(&c, ci.Build{Id: 12, BuildTypeId: "bt1", BranchName: "master", Status: db.StatusRunning}, newBuildType())
+
+				providerMock.AssertNumberOfCalls(t, "GetChanges", 2)
+
+				builds := dbMock.Calls[1].Arguments.Get(1).([]db.Branch)[0].Builds
+				So(builds[0].Changes[0].Author, ShouldEqual, "jdoe")
+			})
+		})
+
+		Convey("When the changes can not be read", func() {
+			providerMock.On("GetChanges", mock.Anything).Return(nil, errors.New("502 Bad Gateway"))
+
+			err := tc.ProcessRunningBuild(&c, ci.Build{Id: 12, BuildTypeId: "bt1", BranchName: "master", Status: db.StatusRunning}, newBuildType())
+
+			Convey("It should still store the build and try again next time", func() {
+				So(err, ShouldBeNil)
+
+				builds := dbMock.Calls[0].Arguments.Get(1).([]db.Branch)[0].Builds
+				So(builds[0].Id, ShouldEqual, 12)
+				So(builds[0].Changes, ShouldBeNil)
+			})
+		})
+
+		Convey("When the branch is green", func() {
+			err := tc.ProcessRunningBuild(&c, ci.Build{Id: 12, BuildTypeId: "bt1", BranchName: "master", Status: db.StatusSuccess}, newBuildType())
+
+			Convey("It should not read any changes", func() {
+				So(err, ShouldBeNil)
+				providerMock.AssertNotCalled(t, "GetChanges", mock.Anything)
+			})
+		})
+
+		Convey("When a personal build passes on a failing branch", func() {
+			providerMock.On("GetChanges", ci.Build{Id: 12, BuildTypeId: "bt1"}).Return(changes, nil)
+			providerMock.On("GetChanges", ci.Build{Id: 11, BuildTypeId: "bt1"}).Return([]ci.Change{}, nil)
+
+			b := ci.Build{Id: 12, BuildTypeId: "bt1", BranchName: "master", Status: db.StatusSuccess, Kind: db.KindPersonal}
+			err := tc.ProcessRunningBuild(&c, b, newBuildType())
+
+			Convey("It should keep reading the changes back to the last normal success", func() {
+				So(err, ShouldBeNil)
+				providerMock.AssertNumberOfCalls(t, "GetChanges", 2)
+			})
+		})
+	})
+}
@@ -0,0 +1,777 @@
+package tc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"build-monitor-v2/server/cfg"
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+
+	"github.com/pstuart2/go-teamcity"
+)
+
+const (
+	tcDateLayout = "20060102T150405-0700"
+	buildFields  = "id,buildTypeId,number,status,state,percentageComplete,branchName,statusText,startDate,finishDate,personal,failedToStart,canceledInfo,running-info(estimatedTotalSeconds)"
+
+	// chainBuildFields adds the build type name and the builds each one depends on
+	chainBuildFields = buildFields + ",buildType(name),snapshot-dependencies(build(id))"
+
+	// maxFailedTests and maxDetailLines keep the build details to what fits on a dashboard
+	maxFailedTests = 50
+	maxDetailLines = 15
+
+	// listPageSize is how many investigations or mutes are asked for at a time, the rest are paged through
+	listPageSize = 100
+)
+
+type tcBuild struct {
+	Id                 int    `json:"id"`
+	BuildTypeId        string `json:"buildTypeId"`
+	Number             string `json:"number"`
+	Status             string `json:"status"`
+	State              string `json:"state"`
+	PercentageComplete int    `json:"percentageComplete"`
+	BranchName         string `json:"branchName"`
+	StatusText         string `json:"statusText"`
+	StartDate          string `json:"startDate"`
+	FinishDate         string `json:"finishDate"`
+	Personal           bool   `json:"personal"`
+	FailedToStart      bool   `json:"failedToStart"`
+	// CanceledInfo is only sent for canceled builds
+	CanceledInfo *struct{} `json:"canceledInfo"`
+	RunningInfo  struct {
+		EstimatedTotalSeconds int `json:"estimatedTotalSeconds"`
+	} `json:"running-info"`
+}
+
+type tcChainBuild struct {
+	tcBuild
+	BuildType struct {
+		Name string `json:"name"`
+	} `json:"buildType"`
+	SnapshotDependencies struct {
+		Build []struct {
+			Id int `json:"id"`
+		} `json:"build"`
+	} `json:"snapshot-dependencies"`
+}
+
+// statusError is returned for any response other than 200, the status code tells a missing build apart
+type statusError struct {
+	Code   int
+	Status string
+	Url    string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("teamcity returned %s for %s", e.Status, e.Url)
+}
+
+// Client talks to the TeamCity REST api with guest, token or basic auth.
+// Projects and build types fill the go-teamcity models, builds are read straight into ci.Build
+// because go-teamcity has no personal or canceled flags.
+type Client struct {
+	url     string
+	token   string
+	user    string
+	pass    string
+	http    *http.Client
+	limiter *rateLimiter
+}
+
+// NewClient picks the auth from the server config, an access token wins over a user and password
+// and guest access is used when neither is set
+func NewClient(s cfg.TeamCityServer) *Client {
+	return &Client{
+		url:     strings.TrimSuffix(s.Url, "/"),
+		token:   s.Token,
+		user:    s.User,
+		pass:    s.Password,
+		http:    &http.Client{Timeout: 30 * time.Second},
+		limiter: newRateLimiter(s.RequestsPerSecond),
+	}
+}
+
+// CheckAuth reads the server info, which needs the same access as everything else we call
+func (c *Client) CheckAuth() error {
+	var server struct {
+		Version string `json:"version"`
+	}
+
+	return c.get("/server", nil, &server)
+}
+
+func (c *Client) GetProjects() ([]teamcity.Project, error) {
+	var list struct {
+		Project []struct {
+			Id              string `json:"id"`
+			Name            string `json:"name"`
+			Description     string `json:"description"`
+			ParentProjectId string `json:"parentProjectId"`
+			WebUrl          string `json:"webUrl"`
+		} `json:"project"`
+	}
+
+	query := url.Values{"fields": {"project(id,name,description,parentProjectId,webUrl)"}}
+	if err := c.get("/projects", query, &list); err != nil {
+		return nil, err
+	}
+
+	projects := []teamcity.Project{}
+	for _, p := range list.Project {
+		projects = append(projects, teamcity.Project{
+			ID:              p.Id,
+			Name:            p.Name,
+			Description:     p.Description,
+			ParentProjectID: p.ParentProjectId,
+			WebURL:          p.WebUrl,
+		})
+	}
+
+	return projects, nil
+}
+
+func (c *Client) GetBuildTypes() ([]teamcity.BuildType, error) {
+	var list struct {
+		BuildType []struct {
+			Id          string `json:"id"`
+			Name        string `json:"name"`
+			Description string `json:"description"`
+			ProjectId   string `json:"projectId"`
+			ProjectName string `json:"projectName"`
+			WebUrl      string `json:"webUrl"`
+		} `json:"buildType"`
+	}
+
+	query := url.Values{"fields": {"buildType(id,name,description,projectId,projectName,webUrl)"}}
+	if err := c.get("/buildTypes", query, &list); err != nil {
+		return nil, err
+	}
+
+	buildTypes := []teamcity.BuildType{}
+	for _, bt := range list.BuildType {
+		buildTypes = append(buildTypes, teamcity.BuildType{
+			ID:          bt.Id,
+			Name:        bt.Name,
+			Description: bt.Description,
+			ProjectID:   bt.ProjectId,
+			ProjectName: bt.ProjectName,
+			WebURL:      bt.WebUrl,
+		})
+	}
+
+	return buildTypes, nil
+}
+
+// everyKind asks for the personal, canceled and failed to start builds the default filter leaves out,
+// the build policies decide what the dashboards do with them
+const everyKind = ",personal:any,canceled:any,failedToStart:any"
+
+func (c *Client) GetBuildsForBuildType(id string, count int) ([]ci.Build, error) {
+	return c.builds("buildType:" + id + ",branch:default:any" + everyKind + ",count:" + strconv.Itoa(count))
+}
+
+// GetBuildsForBuildTypeSince only returns the builds that come after sinceBuildId
+func (c *Client) GetBuildsForBuildTypeSince(id string, sinceBuildId int, count int) ([]ci.Build, error) {
+	return c.builds("buildType:" + id + ",branch:default:any" + everyKind + ",sinceBuild:(id:" + strconv.Itoa(sinceBuildId) + "),count:" + strconv.Itoa(count))
+}
+
+func (c *Client) GetRunningBuilds() ([]ci.Build, error) {
+	return c.builds("running:true,branch:default:any" + everyKind)
+}
+
+// GetBuildQueue lists every queued build in the order TeamCity will start them
+func (c *Client) GetBuildQueue() ([]ci.QueuedBuild, error) {
+	var list struct {
+		Build []struct {
+			Id          int    `json:"id"`
+			BuildTypeId string `json:"buildTypeId"`
+			BranchName  string `json:"branchName"`
+			WaitReason  string `json:"waitReason"`
+			QueuedDate  string `json:"queuedDate"`
+		} `json:"build"`
+	}
+
+	query := url.Values{"fields": {"build(id,buildTypeId,branchName,waitReason,queuedDate)"}}
+	if err := c.get("/buildQueue", query, &list); err != nil {
+		return nil, err
+	}
+
+	queued := []ci.QueuedBuild{}
+	for i, b := range list.Build {
+		queued = append(queued, ci.QueuedBuild{
+			Id:          b.Id,
+			BuildTypeId: b.BuildTypeId,
+			BranchName:  b.BranchName,
+			Position:    i + 1,
+			WaitReason:  b.WaitReason,
+			QueuedDate:  parseTcDate(b.QueuedDate),
+		})
+	}
+
+	return queued, nil
+}
+
+// GetChangesForBuild lists the VCS changes TeamCity collected for a build
+func (c *Client) GetChangesForBuild(id int) ([]ci.Change, error) {
+	var list struct {
+		Change []struct {
+			Version  string `json:"version"`
+			Username string `json:"username"`
+			Comment  string `json:"comment"`
+			Files    struct {
+				Count int `json:"count"`
+			} `json:"files"`
+		} `json:"change"`
+	}
+
+	query := url.Values{"locator": {"build:(id:" + strconv.Itoa(id) + ")"}, "fields": {"change(version,username,comment,files(count))"}}
+	if err := c.get("/changes", query, &list); err != nil {
+		return nil, err
+	}
+
+	changes := []ci.Change{}
+	for _, ch := range list.Change {
+		changes = append(changes, ci.Change{
+			Revision:  ch.Version,
+			Author:    ch.Username,
+			Comment:   strings.TrimSpace(ch.Comment),
+			FileCount: ch.Files.Count,
+		})
+	}
+
+	return changes, nil
+}
+
+// GetTestCounts reads the test occurrence summary of a build, newFailed is counted against the previous build on its branch
+func (c *Client) GetTestCounts(id int) (ci.TestCounts, error) {
+	var b struct {
+		TestOccurrences struct {
+			Count     int `json:"count"`
+			Passed    int `json:"passed"`
+			Failed    int `json:"failed"`
+			NewFailed int `json:"newFailed"`
+			Ignored   int `json:"ignored"`
+			Muted     int `json:"muted"`
+		} `json:"testOccurrences"`
+	}
+
+	query := url.Values{"fields": {"testOccurrences(count,passed,failed,newFailed,ignored,muted)"}}
+	if err := c.get("/builds/id:"+strconv.Itoa(id), query, &b); err != nil {
+		return ci.TestCounts{}, err
+	}
+
+	t := b.TestOccurrences
+	return ci.TestCounts{Total: t.Count, Passed: t.Passed, Failed: t.Failed, NewFailed: t.NewFailed, Ignored: t.Ignored, Muted: t.Muted}, nil
+}
+
+// GetBuildDetails reads the status, build problems and failed tests of a build, the failed tests only
+// carry the start of their stack trace. A build TeamCity does not have returns ci.ErrBuildNotFound.
+func (c *Client) GetBuildDetails(id int) (ci.BuildDetails, error) {
+	var b tcBuild
+	if err := c.get("/builds/id:"+strconv.Itoa(id), url.Values{"fields": {buildFields}}, &b); err != nil {
+		if se, ok := err.(*statusError); ok && se.Code == http.StatusNotFound {
+			return ci.BuildDetails{}, ci.ErrBuildNotFound
+		}
+
+		return ci.BuildDetails{}, err
+	}
+
+	var problems struct {
+		ProblemOccurrence []struct {
+			Type    string `json:"type"`
+			Details string `json:"details"`
+		} `json:"problemOccurrence"`
+	}
+
+	problemQuery := url.Values{"locator": {"build:(id:" + strconv.Itoa(id) + ")"}, "fields": {"problemOccurrence(type,details)"}}
+	if err := c.get("/problemOccurrences", problemQuery, &problems); err != nil {
+		return ci.BuildDetails{}, err
+	}
+
+	var tests struct {
+		TestOccurrence []struct {
+			Name       string `json:"name"`
+			NewFailure bool   `json:"newFailure"`
+			Muted      bool   `json:"muted"`
+			Details    string `json:"details"`
+		} `json:"testOccurrence"`
+	}
+
+	testLocator := "build:(id:" + strconv.Itoa(id) + "),status:FAILURE,count:" + strconv.Itoa(maxFailedTests)
+	testQuery := url.Values{"locator": {testLocator}, "fields": {"testOccurrence(name,newFailure,muted,details)"}}
+	if err := c.get("/testOccurrences", testQuery, &tests); err != nil {
+		return ci.BuildDetails{}, err
+	}
+
+	details := ci.BuildDetails{
+		Id:          b.Id,
+		BuildTypeId: b.BuildTypeId,
+		Number:      b.Number,
+		Status:      StatusToDb(b.State, b.Status),
+		StatusText:  b.StatusText,
+		Problems:    []ci.BuildProblem{},
+		FailedTests: []ci.FailedTest{},
+	}
+
+	for _, p := range problems.ProblemOccurrence {
+		details.Problems = append(details.Problems, ci.BuildProblem{Type: p.Type, Details: excerpt(p.Details)})
+	}
+
+	for _, t := range tests.TestOccurrence {
+		details.FailedTests = append(details.FailedTests, ci.FailedTest{Name: t.Name, NewFailure: t.NewFailure, Muted: t.Muted, Details: excerpt(t.Details)})
+	}
+
+	return details, nil
+}
+
+// excerpt keeps the first lines of a stack trace, which is where the assertion and the failing line are
+func excerpt(details string) string {
+	lines := strings.Split(strings.TrimSpace(details), "\n")
+	if len(lines) <= maxDetailLines {
+		return strings.Join(lines, "\n")
+	}
+
+	return strings.Join(lines[:maxDetailLines], "\n") + "\n..."
+}
+
+type tcUser struct {
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+// displayName is the user's full name when TeamCity has one
+func (u tcUser) displayName() string {
+	if u.Name != "" {
+		return u.Name
+	}
+
+	return u.Username
+}
+
+type tcBuildTypeScope struct {
+	BuildTypes struct {
+		BuildType []struct {
+			Id string `json:"id"`
+		} `json:"buildType"`
+	} `json:"buildTypes"`
+}
+
+// GetInvestigations lists the investigations someone is working on, one for each build type in their scope.
+// Investigations of the whole build type come before the ones of a single test.
+func (c *Client) GetInvestigations() ([]ci.Investigation, error) {
+	buildTypeWide := []ci.Investigation{}
+	tests := []ci.Investigation{}
+
+	for start := 0; ; start += listPageSize {
+		var list struct {
+			NextHref      string `json:"nextHref"`
+			Investigation []struct {
+				State      string `json:"state"`
+				Assignee   tcUser `json:"assignee"`
+				Assignment struct {
+					Timestamp string `json:"timestamp"`
+					Text      string `json:"text"`
+				} `json:"assignment"`
+				Scope  tcBuildTypeScope `json:"scope"`
+				Target struct {
+					AnyProblem bool `json:"anyProblem"`
+				} `json:"target"`
+			} `json:"investigation"`
+		}
+
+		query := url.Values{
+			"locator": {"state:taken," + pageLocator(start)},
+			"fields":  {"nextHref,investigation(state,assignee(username,name),assignment(timestamp,text),scope(buildTypes(buildType(id))),target(anyProblem))"},
+		}
+		if err := c.get("/investigations", query, &list); err != nil {
+			return nil, err
+		}
+
+		for _, i := range list.Investigation {
+			for _, bt := range i.Scope.BuildTypes.BuildType {
+				investigation := ci.Investigation{
+					BuildTypeId: bt.Id,
+					Assignee:    i.Assignee.displayName(),
+					State:       strings.ToLower(i.State),
+					Comment:     i.Assignment.Text,
+					Since:       parseTcDate(i.Assignment.Timestamp),
+				}
+
+				if i.Target.AnyProblem {
+					buildTypeWide = append(buildTypeWide, investigation)
+				} else {
+					tests = append(tests, investigation)
+				}
+			}
+		}
+
+		if list.NextHref == "" {
+			return append(buildTypeWide, tests...), nil
+		}
+	}
+}
+
+// pageLocator asks for one page of a list, TeamCity sends a nextHref while there are more
+func pageLocator(start int) string {
+	return "start:" + strconv.Itoa(start) + ",count:" + strconv.Itoa(listPageSize)
+}
+
+// GetMutedTests lists the tests muted in a build type, tests muted for a whole project are not included
+func (c *Client) GetMutedTests() ([]ci.MutedTest, error) {
+	muted := []ci.MutedTest{}
+
+	for start := 0; ; start += listPageSize {
+		var list struct {
+			NextHref string `json:"nextHref"`
+			Mute     []struct {
+				Assignment struct {
+					Text string `json:"text"`
+					User tcUser `json:"user"`
+				} `json:"assignment"`
+				Scope  tcBuildTypeScope `json:"scope"`
+				Target struct {
+					Tests struct {
+						Test []struct {
+							Name string `json:"name"`
+						} `json:"test"`
+					} `json:"tests"`
+				} `json:"target"`
+			} `json:"mute"`
+		}
+
+		query := url.Values{
+			"locator": {pageLocator(start)},
+			"fields":  {"nextHref,mute(assignment(text,user(username,name)),scope(buildTypes(buildType(id))),target(tests(test(name))))"},
+		}
+		if err := c.get("/mutes", query, &list); err != nil {
+			return nil, err
+		}
+
+		for _, m := range list.Mute {
+			for _, bt := range m.Scope.BuildTypes.BuildType {
+				for _, t := range m.Target.Tests.Test {
+					muted = append(muted, ci.MutedTest{
+						BuildTypeId: bt.Id,
+						Name:        t.Name,
+						MutedBy:     m.Assignment.User.displayName(),
+						Comment:     m.Assignment.Text,
+					})
+				}
+			}
+		}
+
+		if list.NextHref == "" {
+			return muted, nil
+		}
+	}
+}
+
+// GetPausedBuildTypeIds lists the build types that will not start builds, the paused ones
+// and the ones in an archived project
+func (c *Client) GetPausedBuildTypeIds() ([]string, error) {
+	var list struct {
+		BuildType []struct {
+			Id      string `json:"id"`
+			Paused  bool   `json:"paused"`
+			Project struct {
+				Archived bool `json:"archived"`
+			} `json:"project"`
+		} `json:"buildType"`
+	}
+
+	query := url.Values{"fields": {"buildType(id,paused,project(archived))"}}
+	if err := c.get("/buildTypes", query, &list); err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, bt := range list.BuildType {
+		if bt.Paused || bt.Project.Archived {
+			ids = append(ids, bt.Id)
+		}
+	}
+
+	return ids, nil
+}
+
+// GetSnapshotDependencies maps each build type to the build types it has a snapshot dependency on
+func (c *Client) GetSnapshotDependencies() (map[string][]string, error) {
+	var list struct {
+		BuildType []struct {
+			Id                   string `json:"id"`
+			SnapshotDependencies struct {
+				SnapshotDependency []struct {
+					SourceBuildType struct {
+						Id string `json:"id"`
+					} `json:"source-buildType"`
+				} `json:"snapshot-dependency"`
+			} `json:"snapshot-dependencies"`
+		} `json:"buildType"`
+	}
+
+	query := url.Values{"fields": {"buildType(id,snapshot-dependencies(snapshot-dependency(source-buildType(id))))"}}
+	if err := c.get("/buildTypes", query, &list); err != nil {
+		return nil, err
+	}
+
+	dependencies := make(map[string][]string)
+	for _, bt := range list.BuildType {
+		for _, d := range bt.SnapshotDependencies.SnapshotDependency {
+			dependencies[bt.Id] = append(dependencies[bt.Id], d.SourceBuildType.Id)
+		}
+	}
+
+	return dependencies, nil
+}
+
+// GetBuildChain reads a build with every build it depends on and every build that depends on it,
+// through any number of snapshot dependencies. A build TeamCity does not have returns ci.ErrBuildNotFound.
+func (c *Client) GetBuildChain(id int) (ci.BuildChain, error) {
+	var b tcChainBuild
+	if err := c.get("/builds/id:"+strconv.Itoa(id), url.Values{"fields": {chainBuildFields}}, &b); err != nil {
+		if se, ok := err.(*statusError); ok && se.Code == http.StatusNotFound {
+			return ci.BuildChain{}, ci.ErrBuildNotFound
+		}
+
+		return ci.BuildChain{}, err
+	}
+
+	upstream, err := c.chainBuilds("snapshotDependency:(to:(id:" + strconv.Itoa(id) + ")),defaultFilter:false")
+	if err != nil {
+		return ci.BuildChain{}, err
+	}
+
+	downstream, err := c.chainBuilds("snapshotDependency:(from:(id:" + strconv.Itoa(id) + ")),defaultFilter:false")
+	if err != nil {
+		return ci.BuildChain{}, err
+	}
+
+	return ci.BuildChain{Build: b.toCi(), Upstream: upstream, Downstream: downstream}, nil
+}
+
+func (c *Client) chainBuilds(locator string) ([]ci.ChainBuild, error) {
+	var list struct {
+		Build []tcChainBuild `json:"build"`
+	}
+
+	query := url.Values{"locator": {locator}, "fields": {"build(" + chainBuildFields + ")"}}
+	if err := c.get("/builds", query, &list); err != nil {
+		return nil, err
+	}
+
+	builds := []ci.ChainBuild{}
+	for _, b := range list.Build {
+		builds = append(builds, b.toCi())
+	}
+
+	return builds, nil
+}
+
+// GetAgents lists every agent, the disconnected and unauthorized ones too, with its pool and current build
+func (c *Client) GetAgents() ([]ci.Agent, error) {
+	var list struct {
+		Agent []struct {
+			Id         int    `json:"id"`
+			Name       string `json:"name"`
+			Connected  bool   `json:"connected"`
+			Authorized bool   `json:"authorized"`
+			Enabled    bool   `json:"enabled"`
+			Pool       struct {
+				Id   int    `json:"id"`
+				Name string `json:"name"`
+			} `json:"pool"`
+			Build struct {
+				Id          int    `json:"id"`
+				BuildTypeId string `json:"buildTypeId"`
+			} `json:"build"`
+		} `json:"agent"`
+	}
+
+	query := url.Values{
+		"locator": {"defaultFilter:false"},
+		"fields":  {"agent(id,name,connected,authorized,enabled,pool(id,name),build(id,buildTypeId))"},
+	}
+	if err := c.get("/agents", query, &list); err != nil {
+		return nil, err
+	}
+
+	agents := []ci.Agent{}
+	for _, a := range list.Agent {
+		agents = append(agents, ci.Agent{
+			Id:          strconv.Itoa(a.Id),
+			Name:        a.Name,
+			PoolId:      strconv.Itoa(a.Pool.Id),
+			PoolName:    a.Pool.Name,
+			Connected:   a.Connected,
+			Authorized:  a.Authorized,
+			Enabled:     a.Enabled,
+			BuildId:     a.Build.Id,
+			BuildTypeId: a.Build.BuildTypeId,
+		})
+	}
+
+	return agents, nil
+}
+
+func (c *Client) GetBuildByID(id int) (ci.Build, error) {
+	var b tcBuild
+	if err := c.get("/builds/id:"+strconv.Itoa(id), url.Values{"fields": {buildFields}}, &b); err != nil {
+		return ci.Build{}, err
+	}
+
+	return b.toCi(), nil
+}
+
+func (c *Client) builds(locator string) ([]ci.Build, error) {
+	var list struct {
+		Build []tcBuild `json:"build"`
+	}
+
+	query := url.Values{"locator": {locator}, "fields": {"build(" + buildFields + ")"}}
+	if err := c.get("/builds", query, &list); err != nil {
+		return nil, err
+	}
+
+	builds := []ci.Build{}
+	for _, b := range list.Build {
+		builds = append(builds, b.toCi())
+	}
+
+	return builds, nil
+}
+
+func (c *Client) get(path string, query url.Values, v interface{}) error {
+	u := c.restUrl() + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+
+	c.limiter.wait()
+
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.user != "" {
+		req.SetBasicAuth(c.user, c.pass)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+		return &ci.AuthError{Provider: "TeamCity", Url: c.url, Status: res.Status}
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return &statusError{Code: res.StatusCode, Status: res.Status, Url: u}
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func (c *Client) restUrl() string {
+	if c.token == "" && c.user == "" {
+		return c.url + "/guestAuth/app/rest"
+	}
+
+	return c.url + "/app/rest"
+}
+
+func (b tcBuild) toCi() ci.Build {
+	build := ci.Build{
+		Id:          b.Id,
+		BuildTypeId: b.BuildTypeId,
+		BranchName:  b.BranchName,
+		Number:      b.Number,
+		Status:      StatusToDb(b.State, b.Status),
+		StatusText:  b.StatusText,
+		Progress:    b.PercentageComplete,
+		StartDate:   parseTcDate(b.StartDate),
+		FinishDate:  parseTcDate(b.FinishDate),
+		Kind:        b.kind(),
+	}
+
+	// TeamCity only estimates the builds that are running, from the durations it has seen
+	if build.Status == db.StatusRunning && b.RunningInfo.EstimatedTotalSeconds > 0 && !build.StartDate.IsZero() {
+		build.EstimatedFinishDate = build.StartDate.Add(time.Duration(b.RunningInfo.EstimatedTotalSeconds) * time.Second)
+	}
+
+	return build
+}
+
+func (b tcChainBuild) toCi() ci.ChainBuild {
+	build := b.tcBuild.toCi()
+
+	dependsOn := []int{}
+	for _, d := range b.SnapshotDependencies.Build {
+		dependsOn = append(dependsOn, d.Id)
+	}
+
+	return ci.ChainBuild{
+		Id:            build.Id,
+		BuildTypeId:   build.BuildTypeId,
+		BuildTypeName: b.BuildType.Name,
+		Number:        build.Number,
+		BranchName:    build.BranchName,
+		Status:        build.Status,
+		StatusText:    build.StatusText,
+		StartDate:     build.StartDate,
+		FinishDate:    build.FinishDate,
+		DependsOn:     dependsOn,
+	}
+}
+
+// kind puts personal first, a developer's remote run is theirs however it ended
+func (b tcBuild) kind() db.BuildKind {
+	switch {
+	case b.Personal:
+		return db.KindPersonal
+	case b.CanceledInfo != nil:
+		return db.KindCanceled
+	case b.FailedToStart:
+		return db.KindFailedToStart
+	}
+
+	return db.KindNormal
+}
+
+// StatusToDb leaves canceled builds, which have no status, unknown
+func StatusToDb(state, status string) db.BuildStatus {
+	if state == "running" {
+		return db.StatusRunning
+	}
+
+	switch status {
+	case "SUCCESS":
+		return db.StatusSuccess
+	case "FAILURE", "ERROR":
+		return db.StatusFailure
+	}
+
+	return db.StatusUnknown
+}
+
+func parseTcDate(s string) time.Time {
+	t, err := time.Parse(tcDateLayout, s)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t
+}
@@ -0,0 +1,435 @@
+package tc_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"build-monitor-v2/server/cfg"
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+	"build-monitor-v2/server/tc"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var tcFixtures = map[string]string{
+	"/server":             "server.json",
+	"/projects":           "projects.json",
+	"/buildTypes":         "buildTypes.json",
+	"/builds":             "builds.json",
+	"/builds/id:511":      "build-511.json",
+	"/buildQueue":         "buildQueue.json",
+	"/changes":            "changes-511.json",
+	"/problemOccurrences": "problemOccurrences.json",
+	"/testOccurrences":    "testOccurrences.json",
+	"/investigations":     "investigations.json",
+	"/mutes":              "mutes.json",
+	"/agents":             "agents.json",
+	"/builds/id:520":      "build-520.json",
+}
+
+// tcLocatorFixtures answer the requests whose locator picks builds other than the usual list
+var tcLocatorFixtures = map[string]string{
+	"snapshotDependency:(to:(id:520)),defaultFilter:false":   "chain-upstream.json",
+	"snapshotDependency:(from:(id:520)),defaultFilter:false": "chain-downstream.json",
+	"state:taken,start:100,count:100":                        "investigations-2.json",
+}
+
+// newTcServer replays the recorded TeamCity REST responses in testdata. Guest access is only
+// allowed on /guestAuth, everything else needs the token or the radiator user.
+func newTcServer(requests *[]*http.Request) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r)
+
+		path := strings.TrimPrefix(r.URL.Path, "/guestAuth/app/rest")
+		if strings.HasPrefix(r.URL.Path, "/app/rest") {
+			user, pass, _ := r.BasicAuth()
+			if r.Header.Get("Authorization") != "Bearer secret-token" && (user != "radiator" || pass != "pw") {
+				http.Error(w, "", http.StatusUnauthorized)
+				return
+			}
+
+			path = strings.TrimPrefix(r.URL.Path, "/app/rest")
+		}
+
+		file, ok := tcLocatorFixtures[r.URL.Query().Get("locator")]
+		if !ok {
+			file, ok = tcFixtures[path]
+		}
+
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		body, err := ioutil.ReadFile("testdata/" + file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+}
+
+func TestClient_Auth(t *testing.T) {
+	Convey("Given a TeamCity server", t, func() {
+		var requests []*http.Request
+		server := newTcServer(&requests)
+		defer server.Close()
+
+		Convey("When no credentials are configured", func() {
+			err := tc.NewClient(cfg.TeamCityServer{Url: server.URL}).CheckAuth()
+
+			Convey("It should use guest access", func() {
+				So(err, ShouldBeNil)
+				So(requests[0].URL.Path, ShouldEqual, "/guestAuth/app/rest/server")
+				So(requests[0].Header.Get("Authorization"), ShouldEqual, "")
+			})
+		})
+
+		Convey("When an access token is configured", func() {
+			err := tc.NewClient(cfg.TeamCityServer{Url: server.URL, Token: "secret-token", User: "radiator", Password: "pw"}).CheckAuth()
+
+			Convey("It should send the token instead of the user", func() {
+				So(err, ShouldBeNil)
+				So(requests[0].URL.Path, ShouldEqual, "/app/rest/server")
+				So(requests[0].Header.Get("Authorization"), ShouldEqual, "Bearer secret-token")
+			})
+		})
+
+		Convey("When a user and password are configured", func() {
+			err := tc.NewClient(cfg.TeamCityServer{Url: server.URL + "/", User: "radiator", Password: "pw"}).CheckAuth()
+
+			Convey("It should use basic auth", func() {
+				So(err, ShouldBeNil)
+				So(requests[0].URL.Path, ShouldEqual, "/app/rest/server")
+
+				user, pass, ok := requests[0].BasicAuth()
+				So(ok, ShouldBeTrue)
+				So(user, ShouldEqual, "radiator")
+				So(pass, ShouldEqual, "pw")
+			})
+		})
+
+		Convey("When the credentials are rejected", func() {
+			err := tc.NewClient(cfg.TeamCityServer{Url: server.URL, Token: "expired"}).CheckAuth()
+
+			Convey("It should return an AuthError naming the server", func() {
+				So(err, ShouldNotBeNil)
+
+				authErr, ok := err.(*ci.AuthError)
+				So(ok, ShouldBeTrue)
+				So(authErr.Url, ShouldEqual, server.URL)
+				So(err.Error(), ShouldContainSubstring, "401")
+			})
+		})
+	})
+}
+
+func TestClient_RateLimit(t *testing.T) {
+	Convey("Given a TeamCity client limited to 20 requests a second", t, func() {
+		var requests []*http.Request
+		server := newTcServer(&requests)
+		defer server.Close()
+
+		c := tc.NewClient(cfg.TeamCityServer{Url: server.URL, RequestsPerSecond: 20})
+
+		Convey("When it is called five times", func() {
+			started := time.Now()
+			for i := 0; i < 5; i++ {
+				c.CheckAuth()
+			}
+
+			Convey("It should space the requests out", func() {
+				So(len(requests), ShouldEqual, 5)
+				So(time.Since(started), ShouldBeGreaterThanOrEqualTo, time.Millisecond*200)
+			})
+		})
+	})
+}
+
+func TestClient_Calls(t *testing.T) {
+	Convey("Given a TeamCity client with a token", t, func() {
+		var requests []*http.Request
+		server := newTcServer(&requests)
+		defer server.Close()
+
+		c := tc.NewClient(cfg.TeamCityServer{Url: server.URL, Token: "secret-token"})
+
+		Convey("When GetProjects is called", func() {
+			projects, err := c.GetProjects()
+
+			Convey("It should return every project", func() {
+				So(err, ShouldBeNil)
+				So(len(projects), ShouldEqual, 2)
+				So(projects[1].ID, ShouldEqual, "BuildMonitor")
+				So(projects[1].ParentProjectID, ShouldEqual, "_Root")
+			})
+		})
+
+		Convey("When GetBuildTypes is called", func() {
+			buildTypes, err := c.GetBuildTypes()
+
+			Convey("It should return every build type", func() {
+				So(err, ShouldBeNil)
+				So(len(buildTypes), ShouldEqual, 3)
+				So(buildTypes[0].ID, ShouldEqual, "BuildMonitor_Server")
+				So(buildTypes[0].ProjectID, ShouldEqual, "BuildMonitor")
+			})
+		})
+
+		Convey("When GetPausedBuildTypeIds is called", func() {
+			ids, err := c.GetPausedBuildTypeIds()
+
+			Convey("It should return the paused build types and the ones in archived projects", func() {
+				So(err, ShouldBeNil)
+				So(ids, ShouldResemble, []string{"BuildMonitor_Nightly", "Legacy_Build"})
+			})
+		})
+
+		Convey("When GetSnapshotDependencies is called", func() {
+			dependencies, err := c.GetSnapshotDependencies()
+
+			Convey("It should map each build type to the ones it depends on", func() {
+				So(err, ShouldBeNil)
+				So(dependencies, ShouldResemble, map[string][]string{"BuildMonitor_Nightly": {"BuildMonitor_Server"}})
+			})
+		})
+
+		Convey("When GetBuildChain is called", func() {
+			chain, err := c.GetBuildChain(520)
+
+			Convey("It should ask for the builds on both sides of the build", func() {
+				So(requests[1].URL.Query().Get("locator"), ShouldEqual, "snapshotDependency:(to:(id:520)),defaultFilter:false")
+				So(requests[2].URL.Query().Get("locator"), ShouldEqual, "snapshotDependency:(from:(id:520)),defaultFilter:false")
+			})
+
+			Convey("It should map the builds with their dependencies", func() {
+				So(err, ShouldBeNil)
+				So(chain.Build.Id, ShouldEqual, 520)
+				So(chain.Build.BuildTypeName, ShouldEqual, "Deploy")
+				So(chain.Build.DependsOn, ShouldResemble, []int{519})
+
+				So(len(chain.Upstream), ShouldEqual, 2)
+				So(chain.Upstream[0].Id, ShouldEqual, 519)
+				So(chain.Upstream[0].Status, ShouldEqual, db.StatusFailure)
+				So(chain.Upstream[0].DependsOn, ShouldResemble, []int{518})
+				So(chain.Upstream[1].DependsOn, ShouldResemble, []int{})
+
+				So(len(chain.Downstream), ShouldEqual, 1)
+				So(chain.Downstream[0].BuildTypeId, ShouldEqual, "Pipeline_Smoke")
+			})
+		})
+
+		Convey("When GetBuildChain is called for a build TeamCity does not have", func() {
+			_, err := c.GetBuildChain(404)
+
+			Convey("It should return ci.ErrBuildNotFound", func() {
+				So(err, ShouldEqual, ci.ErrBuildNotFound)
+			})
+		})
+
+		Convey("When GetBuildsForBuildType is called", func() {
+			builds, err := c.GetBuildsForBuildType("BuildMonitor_Server", 25)
+
+			Convey("It should ask for the builds of every branch and kind", func() {
+				So(requests[0].URL.Query().Get("locator"), ShouldEqual, "buildType:BuildMonitor_Server,branch:default:any,personal:any,canceled:any,failedToStart:any,count:25")
+			})
+
+			Convey("It should map running and finished builds", func() {
+				So(err, ShouldBeNil)
+				So(len(builds), ShouldEqual, 4)
+
+				So(builds[0].Id, ShouldEqual, 512)
+				So(builds[0].Status, ShouldEqual, db.StatusRunning)
+				So(builds[0].Progress, ShouldEqual, 42)
+				So(builds[0].BranchName, ShouldEqual, "feature/auth")
+				So(builds[0].EstimatedFinishDate.Equal(time.Date(2017, 10, 4, 18, 30, 0, 0, time.UTC)), ShouldBeTrue)
+
+				So(builds[1].Status, ShouldEqual, db.StatusFailure)
+				So(builds[1].StartDate.Equal(time.Date(2017, 10, 4, 18, 20, 0, 0, time.UTC)), ShouldBeTrue)
+				So(builds[1].FinishDate.Equal(time.Date(2017, 10, 4, 18, 24, 0, 0, time.UTC)), ShouldBeTrue)
+				So(builds[1].EstimatedFinishDate.IsZero(), ShouldBeTrue)
+			})
+
+			Convey("It should mark personal and canceled builds", func() {
+				So(builds[0].Kind, ShouldEqual, db.KindNormal)
+				So(builds[2].Kind, ShouldEqual, db.KindPersonal)
+				So(builds[3].Kind, ShouldEqual, db.KindCanceled)
+				So(builds[3].Status, ShouldEqual, db.StatusUnknown)
+			})
+		})
+
+		Convey("When GetBuildsForBuildTypeSince is called", func() {
+			c.GetBuildsForBuildTypeSince("BuildMonitor_Server", 511, 1000)
+
+			Convey("It should only ask for the builds after that one, of every kind", func() {
+				So(requests[0].URL.Query().Get("locator"), ShouldEqual, "buildType:BuildMonitor_Server,branch:default:any,personal:any,canceled:any,failedToStart:any,sinceBuild:(id:511),count:1000")
+			})
+		})
+
+		Convey("When GetRunningBuilds is called", func() {
+			c.GetRunningBuilds()
+
+			Convey("It should ask for the running builds of every branch and kind", func() {
+				So(requests[0].URL.Query().Get("locator"), ShouldEqual, "running:true,branch:default:any,personal:any,canceled:any,failedToStart:any")
+			})
+		})
+
+		Convey("When GetBuildQueue is called", func() {
+			queued, err := c.GetBuildQueue()
+
+			Convey("It should return the queue in order", func() {
+				So(err, ShouldBeNil)
+				So(len(queued), ShouldEqual, 2)
+
+				So(queued[0].Id, ShouldEqual, 514)
+				So(queued[0].Position, ShouldEqual, 1)
+				So(queued[0].BranchName, ShouldEqual, "feature/auth")
+				So(queued[0].WaitReason, ShouldEqual, "Build is waiting for a compatible agent")
+				So(queued[0].QueuedDate.Equal(time.Date(2017, 10, 4, 18, 30, 0, 0, time.UTC)), ShouldBeTrue)
+
+				So(queued[1].Position, ShouldEqual, 2)
+				So(queued[1].BranchName, ShouldEqual, "")
+			})
+		})
+
+		Convey("When GetChangesForBuild is called", func() {
+			changes, err := c.GetChangesForBuild(511)
+
+			Convey("It should ask for the changes of that build", func() {
+				So(requests[0].URL.Query().Get("locator"), ShouldEqual, "build:(id:511)")
+			})
+
+			Convey("It should map the revision, author, comment and file count", func() {
+				So(err, ShouldBeNil)
+				So(changes, ShouldResemble, []ci.Change{
+					{Revision: "9f2c1e7", Author: "jdoe", Comment: "Fix the flaky auth test", FileCount: 3},
+					{Revision: "41ab0d2", Author: "asmith", Comment: "Bump the client", FileCount: 1},
+				})
+			})
+		})
+
+		Convey("When GetTestCounts is called", func() {
+			counts, err := c.GetTestCounts(511)
+
+			Convey("It should only ask for the test summary", func() {
+				So(requests[0].URL.Path, ShouldEqual, "/app/rest/builds/id:511")
+				So(requests[0].URL.Query().Get("fields"), ShouldEqual, "testOccurrences(count,passed,failed,newFailed,ignored,muted)")
+			})
+
+			Convey("It should return the counts", func() {
+				So(err, ShouldBeNil)
+				So(counts, ShouldResemble, ci.TestCounts{Total: 142, Passed: 138, Failed: 2, NewFailed: 1, Ignored: 2, Muted: 1})
+			})
+		})
+
+		Convey("When GetBuildDetails is called", func() {
+			details, err := c.GetBuildDetails(511)
+
+			Convey("It should only ask for the failed tests of that build", func() {
+				So(requests[2].URL.Query().Get("locator"), ShouldEqual, "build:(id:511),status:FAILURE,count:50")
+			})
+
+			Convey("It should return the status, problems and failed tests", func() {
+				So(err, ShouldBeNil)
+				So(details.Id, ShouldEqual, 511)
+				So(details.Status, ShouldEqual, db.StatusFailure)
+				So(details.StatusText, ShouldEqual, "Tests failed: 2")
+				So(details.Problems, ShouldResemble, []ci.BuildProblem{{Type: "TC_FAILED_TESTS", Details: "Tests failed: 2 (1 new), passed: 140"}})
+				So(len(details.FailedTests), ShouldEqual, 2)
+				So(details.FailedTests[0].NewFailure, ShouldBeTrue)
+				So(details.FailedTests[1].Muted, ShouldBeTrue)
+			})
+
+			Convey("It should only keep the start of a stack trace", func() {
+				lines := strings.Split(details.FailedTests[0].Details, "\n")
+				So(len(lines), ShouldEqual, 16)
+				So(lines[0], ShouldStartWith, "org.junit.ComparisonFailure")
+				So(lines[15], ShouldEqual, "...")
+				So(details.FailedTests[1].Details, ShouldEqual, "expected UTC")
+			})
+		})
+
+		Convey("When GetBuildDetails is called for a build TeamCity does not have", func() {
+			_, err := c.GetBuildDetails(999)
+
+			Convey("It should say the build was not found", func() {
+				So(err, ShouldEqual, ci.ErrBuildNotFound)
+			})
+		})
+
+		Convey("When GetInvestigations is called", func() {
+			investigations, err := c.GetInvestigations()
+
+			Convey("It should only ask for the open investigations, page by page", func() {
+				So(len(requests), ShouldEqual, 2)
+				So(requests[0].URL.Query().Get("locator"), ShouldEqual, "state:taken,start:0,count:100")
+				So(requests[1].URL.Query().Get("locator"), ShouldEqual, "state:taken,start:100,count:100")
+			})
+
+			Convey("It should return one for each build type of every page, the build type wide ones first", func() {
+				So(err, ShouldBeNil)
+				So(len(investigations), ShouldEqual, 3)
+
+				So(investigations[0].BuildTypeId, ShouldEqual, "BuildMonitor_Server")
+				So(investigations[0].Assignee, ShouldEqual, "Alice")
+				So(investigations[0].State, ShouldEqual, "taken")
+				So(investigations[0].Comment, ShouldEqual, "Looking into the login failures")
+				So(investigations[0].Since.Equal(time.Date(2017, 10, 4, 18, 30, 0, 0, time.UTC)), ShouldBeTrue)
+				So(investigations[1].BuildTypeId, ShouldEqual, "BuildMonitor_Client")
+				So(investigations[2].Assignee, ShouldEqual, "bob")
+			})
+		})
+
+		Convey("When GetMutedTests is called", func() {
+			muted, err := c.GetMutedTests()
+
+			Convey("It should ask for them a page at a time", func() {
+				So(len(requests), ShouldEqual, 1)
+				So(requests[0].URL.Query().Get("locator"), ShouldEqual, "start:0,count:100")
+			})
+
+			Convey("It should return each muted test of each build type", func() {
+				So(err, ShouldBeNil)
+				So(muted, ShouldResemble, []ci.MutedTest{
+					{BuildTypeId: "BuildMonitor_Server", Name: "build.monitor.ClockTest.timezone", MutedBy: "Bob", Comment: "Fails on the build agents in UTC+13"},
+					{BuildTypeId: "BuildMonitor_Server", Name: "build.monitor.ClockTest.dst", MutedBy: "Bob", Comment: "Fails on the build agents in UTC+13"},
+				})
+			})
+		})
+
+		Convey("When GetAgents is called", func() {
+			agents, err := c.GetAgents()
+
+			Convey("It should ask for the disconnected agents too", func() {
+				So(requests[0].URL.Query().Get("locator"), ShouldEqual, "defaultFilter:false")
+			})
+
+			Convey("It should return each agent with its pool and build", func() {
+				So(err, ShouldBeNil)
+				So(agents, ShouldResemble, []ci.Agent{
+					{Id: "1", Name: "linux-01", PoolId: "0", PoolName: "Default", Connected: true, Authorized: true, Enabled: true, BuildId: 512, BuildTypeId: "BuildMonitor_Server"},
+					{Id: "2", Name: "linux-02", PoolId: "0", PoolName: "Default", Connected: true, Authorized: true, Enabled: true},
+					{Id: "7", Name: "mac-01", PoolId: "3", PoolName: "macOS", Authorized: true, Enabled: true},
+				})
+			})
+		})
+
+		Convey("When GetBuildByID is called", func() {
+			build, err := c.GetBuildByID(511)
+
+			Convey("It should return the build", func() {
+				So(err, ShouldBeNil)
+				So(build.Number, ShouldEqual, "87")
+				So(build.StatusText, ShouldEqual, "Tests failed: 2")
+			})
+		})
+	})
+}
@@ -0,0 +1,80 @@
+package tc
+
+import "build-monitor-v2/server/ci"
+
+type commandKind int
+
+const (
+	shutdownCommand commandKind = iota
+	refreshCommand
+	resyncCommand
+	syncDashboardCommand
+	syncBuildTypeCommand
+)
+
+func (k commandKind) String() string {
+	switch k {
+	case shutdownCommand:
+		return "shutdown"
+	case refreshCommand:
+		return "refresh"
+	case resyncCommand:
+		return "resync"
+	case syncDashboardCommand:
+		return "dashboard sync"
+	case syncBuildTypeCommand:
+		return "build type sync"
+	}
+
+	return "unknown command"
+}
+
+// command is run by the monitor goroutine, id names the dashboard or build type a sync is scoped to.
+// The outcome is sent back on result.
+type command struct {
+	kind   commandKind
+	id     string
+	result chan commandResult
+}
+
+type commandResult struct {
+	summary ci.SyncSummary
+	err     error
+}
+
+// send hands a command to the monitor and waits for its outcome
+func (c *Server) send(kind commandKind, id string) (ci.SyncSummary, error) {
+	if c.commands == nil {
+		return ci.SyncSummary{}, ci.ErrNotRunning
+	}
+
+	cmd := command{kind: kind, id: id, result: make(chan commandResult, 1)}
+
+	select {
+	case c.commands <- cmd:
+	case <-c.stopped:
+		return ci.SyncSummary{}, ci.ErrNotRunning
+	}
+
+	r := <-cmd.result
+	return r.summary, r.err
+}
+
+// runCommand runs a command on the monitor goroutine, a refresh reports its sync in the monitor status instead
+func runCommand(c *Server, cmd command) (ci.SyncSummary, error) {
+	if !c.Breaker.Allow() {
+		c.Log.Warnf("Skipping the %v, the server can not be reached", cmd.kind)
+		return ci.SyncSummary{}, ci.ErrCircuitOpen
+	}
+
+	switch cmd.kind {
+	case refreshCommand, resyncCommand:
+		return ci.SyncSummary{}, refresh(c, cmd.kind == resyncCommand)
+	case syncDashboardCommand:
+		return GetDashboardHistory(c, cmd.id)
+	case syncBuildTypeCommand:
+		return GetBuildTypeHistory(c, cmd.id)
+	}
+
+	return ci.SyncSummary{}, nil
+}
@@ -0,0 +1,30 @@
+package tc
+
+import (
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+)
+
+// BuildDetails asks the provider why a build failed. A finished build does not change so its details
+// are cached, a running build is read again every time.
+func (c *Server) BuildDetails(buildTypeId string, buildId int) (ci.BuildDetails, error) {
+	p, ok := c.Provider.(ci.BuildDetailer)
+	if !ok {
+		return ci.BuildDetails{}, ci.ErrNoBuildDetails
+	}
+
+	if details, ok := c.cache.getDetails(buildId); ok && details.BuildTypeId == buildTypeId {
+		return details, nil
+	}
+
+	details, err := p.GetBuildDetails(ci.Build{Id: buildId, BuildTypeId: buildTypeId})
+	if err != nil {
+		return ci.BuildDetails{}, err
+	}
+
+	if details.Status != db.StatusRunning {
+		c.cache.putDetails(buildId, details)
+	}
+
+	return details, nil
+}
@@ -0,0 +1,66 @@
+package tc_test
+
+import (
+	"testing"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+	"build-monitor-v2/server/tc"
+
+	"github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestServer_BuildDetails(t *testing.T) {
+	Convey("Given a monitor for a provider that can explain its builds", t, func() {
+		log := logrus.WithField("test", "TestServer_BuildDetails")
+		providerMock := new(IDetailProviderMock)
+
+		c := tc.NewProviderServer(log, "jenkins", providerMock, new(IDbMock), "20s", "5s")
+
+		Convey("When a failed build is asked for twice", func() {
+			failed := ci.BuildDetails{Id: 7, BuildTypeId: "jenkins:job", Status: db.StatusFailure, StatusText: "Tests failed: 1"}
+			providerMock.On("GetBuildDetails", ci.Build{Id: 7, BuildTypeId: "jenkins:job"}).Return(failed, nil)
+
+			first, firstErr := c.BuildDetails("jenkins:job", 7)
+			second, secondErr := c.BuildDetails("jenkins:job", 7)
+
+			Convey("It should only ask the provider once", func() {
+				So(firstErr, ShouldBeNil)
+				So(secondErr, ShouldBeNil)
+				So(first, ShouldResemble, failed)
+				So(second, ShouldResemble, failed)
+				providerMock.AssertNumberOfCalls(t, "GetBuildDetails", 1)
+			})
+
+			Convey("It should not return it for another build type", func() {
+				providerMock.On("GetBuildDetails", ci.Build{Id: 7, BuildTypeId: "jenkins:other"}).Return(ci.BuildDetails{}, ci.ErrBuildNotFound)
+
+				_, err := c.BuildDetails("jenkins:other", 7)
+				So(err, ShouldEqual, ci.ErrBuildNotFound)
+			})
+		})
+
+		Convey("When a running build is asked for twice", func() {
+			providerMock.On("GetBuildDetails", mock.Anything).Return(ci.BuildDetails{Id: 8, BuildTypeId: "jenkins:job", Status: db.StatusRunning}, nil)
+
+			c.BuildDetails("jenkins:job", 8)
+			c.BuildDetails("jenkins:job", 8)
+
+			Convey("It should ask the provider every time", func() {
+				providerMock.AssertNumberOfCalls(t, "GetBuildDetails", 2)
+			})
+		})
+
+		Convey("When the provider can not explain its builds", func() {
+			c.Provider = new(IProviderMock)
+
+			_, err := c.BuildDetails("jenkins:job", 7)
+
+			Convey("It should say so", func() {
+				So(err, ShouldEqual, ci.ErrNoBuildDetails)
+			})
+		})
+	})
+}
@@ -0,0 +1,101 @@
+package tc
+
+import (
+	"time"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+)
+
+// investigationSyncInterval is how often the polls also sync investigations, people take them far less often
+const investigationSyncInterval = time.Minute
+
+// SyncInvestigations stores who is investigating each build type this monitor owns and which of its tests
+// are muted. Only the build types that changed are written, which is also how a resolved investigation is cleared.
+var SyncInvestigations = func(c *Server) error {
+	p, ok := c.Provider.(ci.Investigations)
+	if !ok {
+		return nil
+	}
+
+	investigations, invErr := p.GetInvestigations()
+	if invErr != nil {
+		return invErr
+	}
+
+	muted, mutedErr := p.GetMutedTests()
+	if mutedErr != nil {
+		return mutedErr
+	}
+
+	buildTypes, btErr := buildTypeMap(c.Db, c.Source)
+	if btErr != nil {
+		return btErr
+	}
+
+	// The first investigation of a build type wins, the provider lists the ones of the whole build type first
+	investigationMap := make(map[string]*db.Investigation)
+	for _, i := range investigations {
+		if _, ok := investigationMap[i.BuildTypeId]; !ok {
+			investigation := InvestigationToDb(i)
+			investigationMap[i.BuildTypeId] = &investigation
+		}
+	}
+
+	mutedMap := make(map[string][]db.MutedTest)
+	for _, m := range muted {
+		mutedMap[m.BuildTypeId] = append(mutedMap[m.BuildTypeId], MutedTestToDb(m))
+	}
+
+	for id, bt := range buildTypes {
+		investigation, mutedTests := investigationMap[id], mutedMap[id]
+		if sameInvestigation(bt.Investigation, investigation) && sameMutedTests(bt.MutedTests, mutedTests) {
+			continue
+		}
+
+		if err := c.Db.UpdateBuildTypeInvestigation(id, investigation, mutedTests); err != nil {
+			c.Log.Errorf("Failed to update the investigation for buildType: %s, Error: %v", id, err)
+		}
+	}
+
+	return nil
+}
+
+func sameInvestigation(a, b *db.Investigation) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Assignee == b.Assignee && a.State == b.State && a.Comment == b.Comment && a.Since.Equal(b.Since)
+}
+
+func sameMutedTests(a, b []db.MutedTest) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func InvestigationToDb(i ci.Investigation) db.Investigation {
+	return db.Investigation{
+		Assignee: i.Assignee,
+		State:    i.State,
+		Comment:  i.Comment,
+		Since:    i.Since,
+	}
+}
+
+func MutedTestToDb(m ci.MutedTest) db.MutedTest {
+	return db.MutedTest{
+		Name:    m.Name,
+		MutedBy: m.MutedBy,
+		Comment: m.Comment,
+	}
+}
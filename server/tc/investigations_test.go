@@ -0,0 +1,108 @@
+package tc_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+	"build-monitor-v2/server/tc"
+
+	"github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestServer_SyncInvestigations(t *testing.T) {
+	Convey("Given a monitor for a provider with investigations", t, func() {
+		log := logrus.WithField("test", "TestServer_SyncInvestigations")
+		dbMock := new(IDbMock)
+		providerMock := new(IInvestigationProviderMock)
+
+		c := tc.Server{Source: "cloud", Provider: providerMock, Db: dbMock, Log: log}
+
+		since := time.Date(2017, 10, 4, 18, 30, 0, 0, time.UTC)
+		alice := db.Investigation{Assignee: "Alice", State: "taken", Comment: "On it", Since: since}
+
+		Convey("When someone takes responsibility for a build type", func() {
+			providerMock.On("GetInvestigations").Return([]ci.Investigation{
+				{BuildTypeId: "cloud:bt1", Assignee: "Alice", State: "taken", Comment: "On it", Since: since},
+				{BuildTypeId: "cloud:bt1", Assignee: "Bob", State: "taken"},
+			}, nil)
+			providerMock.On("GetMutedTests").Return([]ci.MutedTest{{BuildTypeId: "cloud:bt1", Name: "ClockTest", MutedBy: "Bob"}}, nil)
+			dbMock.On("BuildTypeList").Return([]db.BuildType{
+				{Id: "cloud:bt1", Source: "cloud"},
+				{Id: "cloud:bt2", Source: "cloud"},
+				{Id: "bt1", Source: ""},
+			}, nil)
+			dbMock.On("UpdateBuildTypeInvestigation", "cloud:bt1", mock.Anything, mock.Anything).Return(nil)
+
+			err := tc.SyncInvestigations(&c)
+
+			Convey("It should store the first investigation and the muted tests", func() {
+				So(err, ShouldBeNil)
+				dbMock.AssertExpectations(t)
+
+				call := dbMock.Calls[1]
+				So(*call.Arguments.Get(1).(*db.Investigation), ShouldResemble, alice)
+				So(call.Arguments.Get(2), ShouldResemble, []db.MutedTest{{Name: "ClockTest", MutedBy: "Bob"}})
+			})
+
+			Convey("It should leave the build types that did not change alone", func() {
+				dbMock.AssertNumberOfCalls(t, "UpdateBuildTypeInvestigation", 1)
+			})
+		})
+
+		Convey("When the investigation is already stored", func() {
+			stored := alice
+			stored.Since = since.Local()
+
+			providerMock.On("GetInvestigations").Return([]ci.Investigation{{BuildTypeId: "cloud:bt1", Assignee: "Alice", State: "taken", Comment: "On it", Since: since}}, nil)
+			providerMock.On("GetMutedTests").Return([]ci.MutedTest{}, nil)
+			dbMock.On("BuildTypeList").Return([]db.BuildType{{Id: "cloud:bt1", Source: "cloud", Investigation: &stored}}, nil)
+
+			err := tc.SyncInvestigations(&c)
+
+			Convey("It should not write it again", func() {
+				So(err, ShouldBeNil)
+				dbMock.AssertNotCalled(t, "UpdateBuildTypeInvestigation", mock.Anything, mock.Anything, mock.Anything)
+			})
+		})
+
+		Convey("When an investigation is resolved", func() {
+			providerMock.On("GetInvestigations").Return([]ci.Investigation{}, nil)
+			providerMock.On("GetMutedTests").Return([]ci.MutedTest{}, nil)
+			dbMock.On("BuildTypeList").Return([]db.BuildType{{Id: "cloud:bt1", Source: "cloud", Investigation: &alice}}, nil)
+			dbMock.On("UpdateBuildTypeInvestigation", "cloud:bt1", (*db.Investigation)(nil), []db.MutedTest(nil)).Return(nil)
+
+			err := tc.SyncInvestigations(&c)
+
+			Convey("It should clear it", func() {
+				So(err, ShouldBeNil)
+				dbMock.AssertExpectations(t)
+			})
+		})
+
+		Convey("When the investigations can not be read", func() {
+			expectedErr := errors.New("502 Bad Gateway")
+			providerMock.On("GetInvestigations").Return(nil, expectedErr)
+
+			err := tc.SyncInvestigations(&c)
+
+			Convey("It should return the error and change nothing", func() {
+				So(err, ShouldEqual, expectedErr)
+				dbMock.AssertNotCalled(t, "BuildTypeList")
+			})
+		})
+
+		Convey("When the provider has no investigations", func() {
+			c.Provider = new(IProviderMock)
+
+			Convey("It should do nothing", func() {
+				So(tc.SyncInvestigations(&c), ShouldBeNil)
+				dbMock.AssertNotCalled(t, "BuildTypeList")
+			})
+		})
+	})
+}
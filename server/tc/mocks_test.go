@@ -1,6 +1,7 @@
 package tc_test
 
 import (
+	"build-monitor-v2/server/ci"
 	"build-monitor-v2/server/db"
 
 	"github.com/pstuart2/go-teamcity"
@@ -31,30 +32,307 @@ func (m *ITcClientMock) GetBuildTypes() ([]teamcity.BuildType, error) {
 	return args.Get(0).([]teamcity.BuildType), args.Error(1)
 }
 
-func (m *ITcClientMock) GetBuildsForBuildType(id string, count int) ([]teamcity.Build, error) {
+func (m *ITcClientMock) GetBuildsForBuildType(id string, count int) ([]ci.Build, error) {
 	args := m.Called(id, count)
 
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 
-	return args.Get(0).([]teamcity.Build), args.Error(1)
+	return args.Get(0).([]ci.Build), args.Error(1)
 }
 
-func (m *ITcClientMock) GetRunningBuilds() ([]teamcity.Build, error) {
+func (m *ITcClientMock) GetBuildsForBuildTypeSince(id string, sinceBuildId int, count int) ([]ci.Build, error) {
+	args := m.Called(id, sinceBuildId, count)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]ci.Build), args.Error(1)
+}
+
+func (m *ITcClientMock) GetRunningBuilds() ([]ci.Build, error) {
 	args := m.Called()
 
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 
-	return args.Get(0).([]teamcity.Build), args.Error(1)
+	return args.Get(0).([]ci.Build), args.Error(1)
 }
 
-func (m *ITcClientMock) GetBuildByID(id int) (teamcity.Build, error) {
+func (m *ITcClientMock) GetBuildByID(id int) (ci.Build, error) {
 	args := m.Called(id)
 
-	return args.Get(0).(teamcity.Build), args.Error(1)
+	return args.Get(0).(ci.Build), args.Error(1)
+}
+
+func (m *ITcClientMock) GetBuildQueue() ([]ci.QueuedBuild, error) {
+	args := m.Called()
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]ci.QueuedBuild), args.Error(1)
+}
+
+func (m *ITcClientMock) GetChangesForBuild(id int) ([]ci.Change, error) {
+	args := m.Called(id)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]ci.Change), args.Error(1)
+}
+
+func (m *ITcClientMock) GetTestCounts(id int) (ci.TestCounts, error) {
+	args := m.Called(id)
+
+	return args.Get(0).(ci.TestCounts), args.Error(1)
+}
+
+func (m *ITcClientMock) GetBuildDetails(id int) (ci.BuildDetails, error) {
+	args := m.Called(id)
+
+	return args.Get(0).(ci.BuildDetails), args.Error(1)
+}
+
+func (m *ITcClientMock) GetInvestigations() ([]ci.Investigation, error) {
+	args := m.Called()
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]ci.Investigation), args.Error(1)
+}
+
+func (m *ITcClientMock) GetMutedTests() ([]ci.MutedTest, error) {
+	args := m.Called()
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]ci.MutedTest), args.Error(1)
+}
+
+func (m *ITcClientMock) GetAgents() ([]ci.Agent, error) {
+	args := m.Called()
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]ci.Agent), args.Error(1)
+}
+
+func (m *ITcClientMock) GetPausedBuildTypeIds() ([]string, error) {
+	args := m.Called()
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *ITcClientMock) GetSnapshotDependencies() (map[string][]string, error) {
+	args := m.Called()
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(map[string][]string), args.Error(1)
+}
+
+func (m *ITcClientMock) GetBuildChain(id int) (ci.BuildChain, error) {
+	args := m.Called(id)
+
+	return args.Get(0).(ci.BuildChain), args.Error(1)
+}
+
+type IProviderMock struct {
+	mock.Mock
+}
+
+func (m *IProviderMock) GetProjects() ([]db.Project, error) {
+	args := m.Called()
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]db.Project), args.Error(1)
+}
+
+func (m *IProviderMock) GetBuildTypes() ([]db.BuildType, error) {
+	args := m.Called()
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]db.BuildType), args.Error(1)
+}
+
+func (m *IProviderMock) GetBuildsForBuildType(id string, count int) ([]ci.Build, error) {
+	args := m.Called(id, count)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]ci.Build), args.Error(1)
+}
+
+func (m *IProviderMock) GetRunningBuilds() ([]ci.Build, error) {
+	args := m.Called()
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]ci.Build), args.Error(1)
+}
+
+func (m *IProviderMock) GetBuild(b ci.Build) (ci.Build, error) {
+	args := m.Called(b)
+
+	return args.Get(0).(ci.Build), args.Error(1)
+}
+
+// IIncrementalProviderMock is a provider that can list the builds after a given one
+type IIncrementalProviderMock struct {
+	IProviderMock
+}
+
+func (m *IIncrementalProviderMock) GetBuildsSince(buildTypeId string, sinceBuildId int, count int) ([]ci.Build, error) {
+	args := m.Called(buildTypeId, sinceBuildId, count)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]ci.Build), args.Error(1)
+}
+
+// IAuthProviderMock is a provider that can also check its credentials
+type IAuthProviderMock struct {
+	IProviderMock
+}
+
+func (m *IAuthProviderMock) CheckAuth() error {
+	args := m.Called()
+
+	return args.Error(0)
+}
+
+// IQueueProviderMock is a provider that can also list its build queue
+type IQueueProviderMock struct {
+	IProviderMock
+}
+
+func (m *IQueueProviderMock) GetQueuedBuilds() ([]ci.QueuedBuild, error) {
+	args := m.Called()
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]ci.QueuedBuild), args.Error(1)
+}
+
+// IChangeProviderMock is a provider that can also list the changes of a build
+type IChangeProviderMock struct {
+	IProviderMock
+}
+
+func (m *IChangeProviderMock) GetChanges(b ci.Build) ([]ci.Change, error) {
+	args := m.Called(b)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]ci.Change), args.Error(1)
+}
+
+// ITestProviderMock is a provider that can also count the tests of a build
+type ITestProviderMock struct {
+	IProviderMock
+}
+
+func (m *ITestProviderMock) GetTestCounts(b ci.Build) (ci.TestCounts, error) {
+	args := m.Called(b)
+
+	return args.Get(0).(ci.TestCounts), args.Error(1)
+}
+
+// IDetailProviderMock is a provider that can also explain why a build failed
+type IDetailProviderMock struct {
+	IProviderMock
+}
+
+func (m *IDetailProviderMock) GetBuildDetails(b ci.Build) (ci.BuildDetails, error) {
+	args := m.Called(b)
+
+	return args.Get(0).(ci.BuildDetails), args.Error(1)
+}
+
+// IInvestigationProviderMock is a provider that can also list investigations and muted tests
+type IInvestigationProviderMock struct {
+	IProviderMock
+}
+
+func (m *IInvestigationProviderMock) GetInvestigations() ([]ci.Investigation, error) {
+	args := m.Called()
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]ci.Investigation), args.Error(1)
+}
+
+func (m *IInvestigationProviderMock) GetMutedTests() ([]ci.MutedTest, error) {
+	args := m.Called()
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]ci.MutedTest), args.Error(1)
+}
+
+// IAgentProviderMock is a provider that can also list its build agents
+type IAgentProviderMock struct {
+	IProviderMock
+}
+
+func (m *IAgentProviderMock) GetAgents() ([]ci.Agent, error) {
+	args := m.Called()
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]ci.Agent), args.Error(1)
+}
+
+// IChainProviderMock is a provider that can also read build chains
+type IChainProviderMock struct {
+	IProviderMock
+}
+
+func (m *IChainProviderMock) GetBuildChain(b ci.Build) (ci.BuildChain, error) {
+	args := m.Called(b)
+
+	return args.Get(0).(ci.BuildChain), args.Error(1)
 }
 
 type IDbMock struct {
@@ -123,6 +401,22 @@ func (m *IDbMock) UpdateBuildTypeBuilds(buildTypeId string, branches []db.Branch
 	return args.Get(0).(*db.BuildType), args.Error(1)
 }
 
+func (m *IDbMock) UpdateBuildTypeHistory(buildTypeId string, branches []db.Branch, lastBuildId int) (*db.BuildType, error) {
+	args := m.Called(buildTypeId, branches, lastBuildId)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*db.BuildType), args.Error(1)
+}
+
+func (m *IDbMock) UpdateBuildTypeInvestigation(buildTypeId string, investigation *db.Investigation, mutedTests []db.MutedTest) error {
+	args := m.Called(buildTypeId, investigation, mutedTests)
+
+	return args.Error(0)
+}
+
 func (m *IDbMock) DashboardList() ([]db.Dashboard, error) {
 	args := m.Called()
 
@@ -133,6 +427,32 @@ func (m *IDbMock) DashboardList() ([]db.Dashboard, error) {
 	return args.Get(0).([]db.Dashboard), args.Error(1)
 }
 
+func (m *IDbMock) UpsertAgent(r db.Agent) (*db.Agent, error) {
+	args := m.Called(r)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*db.Agent), args.Error(1)
+}
+
+func (m *IDbMock) AgentList() ([]db.Agent, error) {
+	args := m.Called()
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]db.Agent), args.Error(1)
+}
+
+func (m *IDbMock) DeleteAgent(id string) error {
+	args := m.Called(id)
+
+	return args.Error(0)
+}
+
 func (m *IDbMock) FindBuildTypeById(id string) (*db.BuildType, error) {
 	args := m.Called(id)
 
@@ -0,0 +1,159 @@
+package tc
+
+import (
+	"sort"
+	"strings"
+
+	"build-monitor-v2/server/ci"
+)
+
+// Monitors fans the calls from main and the api out to every configured monitor
+type Monitors []*Server
+
+func (m Monitors) Start() error {
+	for _, s := range m {
+		if err := s.Start(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m Monitors) Shutdown() {
+	for _, s := range m {
+		s.Shutdown()
+	}
+}
+
+// Refresh refreshes every monitor, the first error is returned once they are all done
+func (m Monitors) Refresh() error {
+	var firstErr error
+	for _, s := range m {
+		if err := s.Refresh(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+func (m Monitors) Resync() error {
+	var firstErr error
+	for _, s := range m {
+		if err := s.Resync(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// RefreshDashboard asks every monitor at once to sync the build types it owns on the dashboard and adds up their
+// summaries. The summary of the monitors that synced is returned with the first error of the others.
+func (m Monitors) RefreshDashboard(dashboardId string) (ci.SyncSummary, error) {
+	summaries := make([]ci.SyncSummary, len(m))
+	errs := make([]error, len(m))
+
+	forEach(len(m), len(m), func(i int) {
+		summaries[i], errs[i] = m[i].RefreshDashboard(dashboardId)
+	})
+
+	merged := ci.SyncSummary{Errors: []ci.SyncError{}}
+	var firstErr error
+	for i, err := range errs {
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+
+			continue
+		}
+
+		merged = mergeSummaries(merged, summaries[i])
+	}
+
+	return merged, firstErr
+}
+
+// RefreshBuildType asks the monitor that owns the build type
+func (m Monitors) RefreshBuildType(buildTypeId string) (ci.SyncSummary, error) {
+	owner := m.owner(buildTypeId)
+	if owner == nil {
+		return ci.SyncSummary{}, ci.ErrBuildTypeNotFound
+	}
+
+	return owner.RefreshBuildType(buildTypeId)
+}
+
+func (m Monitors) Status() []ci.MonitorStatus {
+	statuses := []ci.MonitorStatus{}
+	for _, s := range m {
+		statuses = append(statuses, s.Status())
+	}
+
+	return statuses
+}
+
+// Webhook hands a pushed payload to the monitor that owns the source it was sent for
+func (m Monitors) Webhook(source string, payload []byte, signature string) error {
+	for _, s := range m {
+		if s.Source == source {
+			return s.Webhook(payload, signature)
+		}
+	}
+
+	return ci.ErrWebhooksDisabled
+}
+
+// BuildDetails asks the monitor that owns the build type
+func (m Monitors) BuildDetails(buildTypeId string, buildId int) (ci.BuildDetails, error) {
+	owner := m.owner(buildTypeId)
+	if owner == nil {
+		return ci.BuildDetails{}, ci.ErrNoBuildDetails
+	}
+
+	return owner.BuildDetails(buildTypeId, buildId)
+}
+
+// BuildChain asks the monitor that owns the build type
+func (m Monitors) BuildChain(buildTypeId string, buildId int) (ci.BuildChain, error) {
+	owner := m.owner(buildTypeId)
+	if owner == nil {
+		return ci.BuildChain{}, ci.ErrNoBuildChain
+	}
+
+	return owner.BuildChain(buildTypeId, buildId)
+}
+
+func mergeSummaries(a, b ci.SyncSummary) ci.SyncSummary {
+	if a.StartedAt.IsZero() || b.StartedAt.Before(a.StartedAt) {
+		a.StartedAt = b.StartedAt
+	}
+
+	if b.FinishedAt.After(a.FinishedAt) {
+		a.FinishedAt = b.FinishedAt
+	}
+
+	a.BuildTypes += b.BuildTypes
+	a.Synced += b.Synced
+	a.Errors = append(a.Errors, b.Errors...)
+	sort.Sort(syncErrorsById(a.Errors))
+
+	return a
+}
+
+// owner finds the monitor of a build type, named sources prefix their ids with "<source>:"
+// and everything else belongs to the default TeamCity server
+func (m Monitors) owner(buildTypeId string) *Server {
+	var owner *Server
+	for _, s := range m {
+		if s.Source == "" && owner == nil {
+			owner = s
+		} else if s.Source != "" && strings.HasPrefix(buildTypeId, s.Source+":") {
+			return s
+		}
+	}
+
+	return owner
+}
@@ -0,0 +1,166 @@
+package tc_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/tc"
+
+	"github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMonitors(t *testing.T) {
+	Convey("Given a TeamCity and a Jenkins monitor", t, func() {
+		log := logrus.WithField("test", "TestMonitors")
+
+		tcMonitor := tc.Server{Provider: new(IProviderMock), Log: log, TcPollInterval: time.Hour, TcRunningBuildPollInterval: time.Hour}
+		jenkinsMonitor := tc.Server{Source: "jenkins", Provider: new(IProviderMock), Log: log, TcPollInterval: time.Hour, TcRunningBuildPollInterval: time.Hour}
+		monitors := tc.Monitors{&tcMonitor, &jenkinsMonitor}
+
+		refreshed := make(chan string, 10)
+
+		oldRefreshProjects := tc.RefreshProjects
+		tc.RefreshProjects = func(s *tc.Server) error {
+			refreshed <- s.Source
+			return nil
+		}
+		defer func() { tc.RefreshProjects = oldRefreshProjects }()
+
+		oldRefreshBuildTypes := tc.RefreshBuildTypes
+		tc.RefreshBuildTypes = func(s *tc.Server) error { return nil }
+		defer func() { tc.RefreshBuildTypes = oldRefreshBuildTypes }()
+
+		oldGetBuildHistory := tc.GetBuildHistory
+		tc.GetBuildHistory = func(s *tc.Server) error { return nil }
+		defer func() { tc.GetBuildHistory = oldGetBuildHistory }()
+
+		oldGetRunningBuilds := tc.GetRunningBuilds
+		tc.GetRunningBuilds = func(s *tc.Server, lastBuilds []ci.Build) []ci.Build { return lastBuilds }
+		defer func() { tc.GetRunningBuilds = oldGetRunningBuilds }()
+
+		Convey("When they are started, refreshed and shutdown", func() {
+			err := monitors.Start()
+			So(err, ShouldBeNil)
+
+			monitors.Refresh()
+			monitors.Shutdown()
+
+			Convey("It should send every call to every monitor", func() {
+				So(<-refreshed, ShouldEqual, "")
+				So(<-refreshed, ShouldEqual, "jenkins")
+
+				sources := []string{<-refreshed, <-refreshed}
+				So(sources, ShouldContain, "")
+				So(sources, ShouldContain, "jenkins")
+			})
+		})
+
+		Convey("When a dashboard is refreshed", func() {
+			oldGetDashboardHistory := tc.GetDashboardHistory
+			tc.GetDashboardHistory = func(s *tc.Server, dashboardId string) (ci.SyncSummary, error) {
+				if s.Source == "jenkins" {
+					return ci.SyncSummary{BuildTypes: 1, Errors: []ci.SyncError{{BuildTypeId: "jenkins:job", Message: "down"}}}, nil
+				}
+
+				return ci.SyncSummary{BuildTypes: 2, Synced: 2, Errors: []ci.SyncError{}}, nil
+			}
+			defer func() { tc.GetDashboardHistory = oldGetDashboardHistory }()
+
+			oldGetBuildTypeHistory := tc.GetBuildTypeHistory
+			tc.GetBuildTypeHistory = func(s *tc.Server, buildTypeId string) (ci.SyncSummary, error) {
+				refreshed <- s.Source
+				return ci.SyncSummary{BuildTypes: 1, Synced: 1, Errors: []ci.SyncError{}}, nil
+			}
+			defer func() { tc.GetBuildTypeHistory = oldGetBuildTypeHistory }()
+
+			So(monitors.Start(), ShouldBeNil)
+			<-refreshed
+			<-refreshed
+
+			summary, err := monitors.RefreshDashboard("d1")
+			_, buildTypeErr := monitors.RefreshBuildType("jenkins:job")
+			monitors.Shutdown()
+
+			Convey("It should add up the summaries of every monitor", func() {
+				So(err, ShouldBeNil)
+				So(summary.BuildTypes, ShouldEqual, 3)
+				So(summary.Synced, ShouldEqual, 2)
+				So(summary.Errors, ShouldResemble, []ci.SyncError{{BuildTypeId: "jenkins:job", Message: "down"}})
+			})
+
+			Convey("It should sync a build type on the monitor that owns it", func() {
+				So(buildTypeErr, ShouldBeNil)
+				So(<-refreshed, ShouldEqual, "jenkins")
+			})
+		})
+
+		Convey("When a monitor fails to start", func() {
+			expectedErr := errors.New("jenkins is down")
+			tc.RefreshProjects = func(s *tc.Server) error {
+				refreshed <- s.Source
+				if s.Source == "jenkins" {
+					return expectedErr
+				}
+
+				return nil
+			}
+
+			err := monitors.Start()
+			defer tcMonitor.Shutdown()
+
+			Convey("It should return the error", func() {
+				So(err, ShouldEqual, expectedErr)
+			})
+		})
+
+		Convey("When the details of a build are asked for", func() {
+			tcProvider := new(IDetailProviderMock)
+			tcProvider.On("GetBuildDetails", ci.Build{Id: 7, BuildTypeId: "bt1"}).Return(ci.BuildDetails{Id: 7, BuildTypeId: "bt1"}, nil)
+			tcMonitor.Provider = tcProvider
+
+			jenkinsProvider := new(IDetailProviderMock)
+			jenkinsProvider.On("GetBuildDetails", ci.Build{Id: 8, BuildTypeId: "jenkins:job"}).Return(ci.BuildDetails{Id: 8, BuildTypeId: "jenkins:job"}, nil)
+			jenkinsMonitor.Provider = jenkinsProvider
+
+			tcDetails, tcErr := monitors.BuildDetails("bt1", 7)
+			jenkinsDetails, jenkinsErr := monitors.BuildDetails("jenkins:job", 8)
+
+			Convey("It should ask the monitor that owns the build type", func() {
+				So(tcErr, ShouldBeNil)
+				So(jenkinsErr, ShouldBeNil)
+				So(tcDetails.Id, ShouldEqual, 7)
+				So(jenkinsDetails.Id, ShouldEqual, 8)
+			})
+		})
+
+		Convey("When the chain of a build is asked for", func() {
+			tcProvider := new(IChainProviderMock)
+			tcProvider.On("GetBuildChain", ci.Build{Id: 7, BuildTypeId: "bt1"}).Return(ci.BuildChain{Build: ci.ChainBuild{Id: 7, BuildTypeId: "bt1"}}, nil)
+			tcMonitor.Provider = tcProvider
+
+			chain, err := monitors.BuildChain("bt1", 7)
+			_, jenkinsErr := monitors.BuildChain("jenkins:job", 8)
+
+			Convey("It should ask the monitor that owns the build type", func() {
+				So(err, ShouldBeNil)
+				So(chain.Build.Id, ShouldEqual, 7)
+				So(jenkinsErr, ShouldEqual, ci.ErrNoBuildChain)
+			})
+		})
+
+		Convey("When a webhook arrives", func() {
+			tcMonitor.Provider = &tc.TeamCity{Tc: new(ITcClientMock)}
+			tcMonitor.WebhookSecret = "s3cret"
+			payload := []byte(`{"build": {"notifyType": "buildStarted", "buildId": "1"}}`)
+
+			Convey("It should go to the monitor for its source", func() {
+				So(monitors.Webhook("", payload, "sha256=00"), ShouldEqual, ci.ErrBadSignature)
+				So(monitors.Webhook("jenkins", payload, "sha256=00"), ShouldEqual, ci.ErrWebhooksDisabled)
+				So(monitors.Webhook("gitlab", payload, "sha256=00"), ShouldEqual, ci.ErrWebhooksDisabled)
+			})
+		})
+	})
+}
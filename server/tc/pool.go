@@ -0,0 +1,71 @@
+package tc
+
+import (
+	"sync"
+	"time"
+)
+
+// forEach calls work for 0..n-1 on at most concurrency goroutines and waits for all of them.
+// Jobs are handed out in order so a concurrency of one behaves like a plain loop.
+func forEach(concurrency, n int, work func(i int)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	if concurrency > n {
+		concurrency = n
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				work(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+
+	wg.Wait()
+}
+
+// rateLimiter spaces requests out evenly, it is shared by every worker talking to the same server
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newRateLimiter returns nil, which never waits, when there is no limit
+func newRateLimiter(requestsPerSecond int) *rateLimiter {
+	if requestsPerSecond <= 0 {
+		return nil
+	}
+
+	return &rateLimiter{interval: time.Second / time.Duration(requestsPerSecond)}
+}
+
+func (l *rateLimiter) wait() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	time.Sleep(delay)
+}
@@ -2,34 +2,30 @@ package tc
 
 import (
 	"build-monitor-v2/server/db"
-
-	"github.com/pstuart2/go-teamcity"
 )
 
 var RefreshProjects = func(c *Server) error {
-	projects, err := c.Tc.GetProjects()
+	projects, err := c.Provider.GetProjects()
 	if err != nil {
-		c.Log.Errorf("Failed to get projects from Team city: %v", err)
+		c.Log.Errorf("Failed to get projects from the provider: %v", err)
 		return err
 	}
 
-	dbProjectMap, pmErr := projectMap(c.Db)
+	dbProjectMap, pmErr := projectMap(c.Db, c.Source)
 	if pmErr != nil {
 		c.Log.Errorf("Failed to get projects from Team city: %v", err)
 		return pmErr
 	}
 
 	for _, project := range projects {
-		if project.ID != "_Root" {
-			dbProject := ProjectToDb(project)
+		project.Source = c.Source
 
-			_, dbErr := c.Db.UpsertProject(dbProject)
-			if dbErr != nil {
-				c.Log.Errorf("Failed to upsert project. Id: %s, Name: %s", dbProject.Id, dbProject.Name)
-			}
-
-			delete(dbProjectMap, dbProject.Id)
+		_, dbErr := c.Db.UpsertProject(project)
+		if dbErr != nil {
+			c.Log.Errorf("Failed to upsert project. Id: %s, Name: %s", project.Id, project.Name)
 		}
+
+		delete(dbProjectMap, project.Id)
 	}
 
 	for _, project := range dbProjectMap {
@@ -39,7 +35,7 @@ var RefreshProjects = func(c *Server) error {
 	return nil
 }
 
-func projectMap(appDb IDb) (map[string]db.Project, error) {
+func projectMap(appDb IDb, source string) (map[string]db.Project, error) {
 	projects, err := appDb.ProjectList()
 	if err != nil {
 		return nil, err
@@ -47,17 +43,10 @@ func projectMap(appDb IDb) (map[string]db.Project, error) {
 
 	projectMap := make(map[string]db.Project)
 	for _, v := range projects {
-		projectMap[v.Id] = v
+		if v.Source == source {
+			projectMap[v.Id] = v
+		}
 	}
 
 	return projectMap, nil
 }
-
-func ProjectToDb(p teamcity.Project) db.Project {
-	return db.Project{
-		Id:              p.ID,
-		Name:            p.Name,
-		Description:     p.Description,
-		ParentProjectID: p.ParentProjectID,
-	}
-}
@@ -9,7 +9,6 @@ import (
 
 	"build-monitor-v2/server/db"
 	"errors"
-	"github.com/pstuart2/go-teamcity"
 	"github.com/sirupsen/logrus"
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -17,20 +16,20 @@ import (
 func TestServer_RefreshProjects(t *testing.T) {
 	Convey("Given a server", t, func() {
 		log := logrus.WithField("test", "TestServer_RefreshProjects")
-		serverMock := new(ITcClientMock)
+		providerMock := new(IProviderMock)
 		dbMock := new(IDbMock)
 
 		c := tc.Server{
-			Tc:             serverMock,
+			Provider:       providerMock,
 			Db:             dbMock,
 			Log:            log,
 			TcPollInterval: time.Millisecond * 500,
 		}
 
 		Convey("When there are no projects", func() {
-			projects := []teamcity.Project{}
+			projects := []db.Project{}
 
-			serverMock.On("GetProjects").Times(1).Return(projects, nil)
+			providerMock.On("GetProjects").Times(1).Return(projects, nil)
 
 			dbProjects := []db.Project{}
 			dbMock.On("ProjectList").Return(dbProjects, nil)
@@ -38,30 +37,29 @@ func TestServer_RefreshProjects(t *testing.T) {
 			Convey("It should not update the db", func() {
 				tc.RefreshProjects(&c)
 
-				serverMock.AssertExpectations(t)
+				providerMock.AssertExpectations(t)
 				dbMock.AssertExpectations(t)
 			})
 		})
 
 		Convey("When there are projects", func() {
-			projects := []teamcity.Project{
-				{ID: "_Root", Name: "Root Project", Description: "I am _Root"},
-				{ID: "p1", Name: "1 Project", Description: "Something here _", ParentProjectID: "_Root"},
-				{ID: "p2", Name: "2 Project", Description: "Something here 1", ParentProjectID: "_Root"},
-				{ID: "p3", Name: "3 Project", Description: "Something here 2", ParentProjectID: "p1"},
-				{ID: "p4", Name: "4 Project", Description: "Something here 3", ParentProjectID: "p3"},
+			projects := []db.Project{
+				{Id: "p1", Name: "1 Project", Description: "Something here _", ParentProjectID: "_Root"},
+				{Id: "p2", Name: "2 Project", Description: "Something here 1", ParentProjectID: "_Root"},
+				{Id: "p3", Name: "3 Project", Description: "Something here 2", ParentProjectID: "p1"},
+				{Id: "p4", Name: "4 Project", Description: "Something here 3", ParentProjectID: "p3"},
 			}
 
-			serverMock.On("GetProjects").Times(1).Return(projects, nil)
+			providerMock.On("GetProjects").Times(1).Return(projects, nil)
 
 			Convey("And the get ProjectList is successful", func() {
 				dbProjects := []db.Project{}
 				dbMock.On("ProjectList").Return(dbProjects, nil)
 
-				db1 := tc.ProjectToDb(projects[1])
-				db2 := tc.ProjectToDb(projects[2])
-				db3 := tc.ProjectToDb(projects[3])
-				db4 := tc.ProjectToDb(projects[4])
+				db1 := projects[0]
+				db2 := projects[1]
+				db3 := projects[2]
+				db4 := projects[3]
 
 				Convey("And the upsert is successful", func() {
 					dbMock.On("UpsertProject", db1).Return(&db1, nil)
@@ -69,10 +67,10 @@ func TestServer_RefreshProjects(t *testing.T) {
 					dbMock.On("UpsertProject", db3).Return(&db3, nil)
 					dbMock.On("UpsertProject", db4).Return(&db4, nil)
 
-					Convey("It should call the db for each project that is not _Root", func() {
+					Convey("It should call the db for each project", func() {
 						tc.RefreshProjects(&c)
 
-						serverMock.AssertExpectations(t)
+						providerMock.AssertExpectations(t)
 						dbMock.AssertExpectations(t)
 					})
 				})
@@ -85,10 +83,10 @@ func TestServer_RefreshProjects(t *testing.T) {
 					dbMock.On("UpsertProject", db3).Return(nil, someError)
 					dbMock.On("UpsertProject", db4).Return(&db4, nil)
 
-					Convey("It should call the db for each project that is not _Root", func() {
+					Convey("It should call the db for each project", func() {
 						tc.RefreshProjects(&c)
 
-						serverMock.AssertExpectations(t)
+						providerMock.AssertExpectations(t)
 						dbMock.AssertExpectations(t)
 					})
 				})
@@ -106,23 +104,22 @@ func TestServer_RefreshProjects(t *testing.T) {
 			})
 		})
 
-		Convey("When there are projects in the database that are no longer in Tc", func() {
-			projects := []teamcity.Project{
-				{ID: "_Root", Name: "Root Project", Description: "I am _Root"},
-				{ID: "p1", Name: "1 Project", Description: "Something here _", ParentProjectID: "_Root"},
-				{ID: "p2", Name: "2 Project", Description: "Something here 1", ParentProjectID: "_Root"},
-				{ID: "p3", Name: "3 Project", Description: "Something here 2", ParentProjectID: "p1"},
-				{ID: "p4", Name: "4 Project", Description: "Something here 3", ParentProjectID: "p3"},
+		Convey("When there are projects in the database that are no longer in the provider", func() {
+			projects := []db.Project{
+				{Id: "p1", Name: "1 Project", Description: "Something here _", ParentProjectID: "_Root"},
+				{Id: "p2", Name: "2 Project", Description: "Something here 1", ParentProjectID: "_Root"},
+				{Id: "p3", Name: "3 Project", Description: "Something here 2", ParentProjectID: "p1"},
+				{Id: "p4", Name: "4 Project", Description: "Something here 3", ParentProjectID: "p3"},
 			}
 
-			serverMock.On("GetProjects").Times(1).Return(projects, nil)
+			providerMock.On("GetProjects").Times(1).Return(projects, nil)
 
-			db1 := tc.ProjectToDb(projects[1])
-			db2 := tc.ProjectToDb(projects[2])
-			db3 := tc.ProjectToDb(projects[3])
-			db4 := tc.ProjectToDb(projects[4])
-			db5 := tc.ProjectToDb(teamcity.Project{ID: "p5", Name: "5 Project", Description: "Something here 5", ParentProjectID: "p1"})
-			db6 := tc.ProjectToDb(teamcity.Project{ID: "p6", Name: "6 Project", Description: "Something here 6", ParentProjectID: "p3"})
+			db1 := projects[0]
+			db2 := projects[1]
+			db3 := projects[2]
+			db4 := projects[3]
+			db5 := db.Project{Id: "p5", Name: "5 Project", Description: "Something here 5", ParentProjectID: "p1"}
+			db6 := db.Project{Id: "p6", Name: "6 Project", Description: "Something here 6", ParentProjectID: "p3"}
 
 			dbProjects := []db.Project{db1, db2, db3, db4, db5, db6}
 			dbMock.On("ProjectList").Return(dbProjects, nil)
@@ -134,24 +131,24 @@ func TestServer_RefreshProjects(t *testing.T) {
 			dbMock.On("UpsertProject", db3).Return(&db3, nil)
 			dbMock.On("UpsertProject", db4).Return(&db4, nil)
 
-			Convey("It should call the db for each project that is not _Root", func() {
+			Convey("It should call the db for each project", func() {
 				tc.RefreshProjects(&c)
 
-				serverMock.AssertExpectations(t)
+				providerMock.AssertExpectations(t)
 				dbMock.AssertExpectations(t)
 			})
 		})
 
-		Convey("When we fail to get the tc projects", func() {
+		Convey("When we fail to get the provider projects", func() {
 			expectedErr := errors.New("this was expected")
-			serverMock.On("GetProjects").Times(1).Return(nil, expectedErr)
+			providerMock.On("GetProjects").Times(1).Return(nil, expectedErr)
 
-			Convey("It should call the db for each project that is not _Root", func() {
+			Convey("It should return the error", func() {
 				err := tc.RefreshProjects(&c)
 
 				So(err, ShouldEqual, expectedErr)
 
-				serverMock.AssertExpectations(t)
+				providerMock.AssertExpectations(t)
 				dbMock.AssertExpectations(t)
 			})
 		})
@@ -0,0 +1,116 @@
+package tc
+
+import (
+	"sort"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+)
+
+// GetQueuedBuilds stores the queued builds of every build type on a dashboard with its branches.
+// The queue it read is returned so the next poll also clears the build types that left the queue.
+var GetQueuedBuilds = func(c *Server, lastQueued []ci.QueuedBuild) []ci.QueuedBuild {
+	p, ok := c.Provider.(ci.BuildQueue)
+	if !ok {
+		return lastQueued
+	}
+
+	queued, err := p.GetQueuedBuilds()
+	if err != nil {
+		c.Log.Errorf("Failed to get the build queue, Error: %v", err)
+		return lastQueued
+	}
+
+	byBuildType := make(map[string][]ci.QueuedBuild)
+	for _, q := range lastQueued {
+		byBuildType[q.BuildTypeId] = nil
+	}
+
+	for _, q := range queued {
+		byBuildType[q.BuildTypeId] = append(byBuildType[q.BuildTypeId], q)
+	}
+
+	buildTypeIds := []string{}
+	for id := range byBuildType {
+		buildTypeIds = append(buildTypeIds, id)
+	}
+	sort.Strings(buildTypeIds)
+
+	for _, id := range buildTypeIds {
+		bt, btErr := c.Db.FindBuildTypeById(id)
+		if btErr != nil {
+			c.Log.Errorf("Failed to get build type for: %s, Error: %v", id, btErr)
+			continue
+		}
+
+		if len(bt.DashboardIds) == 0 {
+			continue
+		}
+
+		branches, changed := queueOnBranches(bt.Branches, byBuildType[id])
+		if !changed {
+			continue
+		}
+
+		if _, updErr := c.Db.UpdateBuildTypeBuilds(bt.Id, branches); updErr != nil {
+			c.Log.Errorf("Failed to update the queue for buildType: %s, Error: %v", bt.Id, updErr)
+		}
+	}
+
+	return queued
+}
+
+// queueOnBranches sets the queued builds of each branch, adding the branches that have never been built.
+// It reports whether anything changed so an unchanged queue is not written on every poll.
+func queueOnBranches(branches []db.Branch, queued []ci.QueuedBuild) ([]db.Branch, bool) {
+	byBranch := make(map[string][]db.QueuedBuild)
+	names := []string{}
+	for _, q := range queued {
+		if _, ok := byBranch[q.BranchName]; !ok {
+			names = append(names, q.BranchName)
+		}
+
+		byBranch[q.BranchName] = append(byBranch[q.BranchName], QueuedBuildToDb(q))
+	}
+
+	changed := false
+	for i, branch := range branches {
+		q := byBranch[branch.Name]
+		if !sameQueue(branch.Queued, q) {
+			branches[i].Queued = q
+			changed = true
+		}
+	}
+
+	for _, name := range names {
+		if indexOfBranch(name, branches) == -1 {
+			branches = append(branches, db.Branch{Name: name, Builds: []db.Build{}, Queued: byBranch[name]})
+			changed = true
+		}
+	}
+
+	return branches, changed
+}
+
+func sameQueue(a, b []db.QueuedBuild) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].Id != b[i].Id || a[i].Position != b[i].Position || a[i].WaitReason != b[i].WaitReason || !a[i].QueuedDate.Equal(b[i].QueuedDate) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func QueuedBuildToDb(q ci.QueuedBuild) db.QueuedBuild {
+	return db.QueuedBuild{
+		Id:         q.Id,
+		Position:   q.Position,
+		WaitReason: q.WaitReason,
+		QueuedDate: q.QueuedDate,
+	}
+}
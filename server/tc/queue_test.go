@@ -0,0 +1,115 @@
+package tc_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+	"build-monitor-v2/server/tc"
+
+	"github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestServer_GetQueuedBuilds(t *testing.T) {
+	Convey("Given a monitor for a provider with a build queue", t, func() {
+		log := logrus.WithField("test", "TestServer_GetQueuedBuilds")
+		dbMock := new(IDbMock)
+		providerMock := new(IQueueProviderMock)
+
+		c := tc.Server{Provider: providerMock, Db: dbMock, Log: log}
+
+		queuedDate := time.Date(2017, 10, 4, 18, 30, 0, 0, time.UTC)
+		bt1 := db.BuildType{
+			Id:           "bt1",
+			DashboardIds: []string{"d1"},
+			Branches:     []db.Branch{{Name: "master", Builds: []db.Build{{Id: 500}}}},
+		}
+
+		Convey("When builds of a build type on a dashboard are queued", func() {
+			queue := []ci.QueuedBuild{
+				{Id: 601, BuildTypeId: "bt1", BranchName: "master", Position: 1, WaitReason: "Waiting for an agent", QueuedDate: queuedDate},
+				{Id: 602, BuildTypeId: "bt2", BranchName: "master", Position: 2},
+				{Id: 603, BuildTypeId: "bt1", BranchName: "feature/new", Position: 3},
+			}
+			providerMock.On("GetQueuedBuilds").Return(queue, nil)
+
+			dbMock.On("FindBuildTypeById", "bt1").Return(&bt1, nil)
+			dbMock.On("FindBuildTypeById", "bt2").Return(&db.BuildType{Id: "bt2"}, nil)
+			dbMock.On("UpdateBuildTypeBuilds", "bt1", mock.Anything).Return(&bt1, nil)
+
+			result := tc.GetQueuedBuilds(&c, []ci.QueuedBuild{})
+
+			Convey("It should store them on their branches", func() {
+				dbMock.AssertExpectations(t)
+				So(result, ShouldResemble, queue)
+
+				branches := dbMock.Calls[1].Arguments.Get(1).([]db.Branch)
+				So(len(branches), ShouldEqual, 2)
+				So(branches[0].Builds[0].Id, ShouldEqual, 500)
+				So(branches[0].Queued, ShouldResemble, []db.QueuedBuild{{Id: 601, Position: 1, WaitReason: "Waiting for an agent", QueuedDate: queuedDate}})
+				So(branches[1].Name, ShouldEqual, "feature/new")
+				So(branches[1].Queued[0].Id, ShouldEqual, 603)
+			})
+
+			Convey("It should leave the build types that are not on a dashboard alone", func() {
+				dbMock.AssertNotCalled(t, "UpdateBuildTypeBuilds", "bt2", mock.Anything)
+			})
+		})
+
+		Convey("When the stored queue has not changed", func() {
+			bt1.Branches[0].Queued = []db.QueuedBuild{{Id: 601, Position: 1, QueuedDate: queuedDate.Local()}}
+			providerMock.On("GetQueuedBuilds").Return([]ci.QueuedBuild{{Id: 601, BuildTypeId: "bt1", BranchName: "master", Position: 1, QueuedDate: queuedDate}}, nil)
+			dbMock.On("FindBuildTypeById", "bt1").Return(&bt1, nil)
+
+			tc.GetQueuedBuilds(&c, []ci.QueuedBuild{})
+
+			Convey("It should not write it again", func() {
+				dbMock.AssertNotCalled(t, "UpdateBuildTypeBuilds", mock.Anything, mock.Anything)
+			})
+		})
+
+		Convey("When a build type has left the queue", func() {
+			bt1.Branches[0].Queued = []db.QueuedBuild{{Id: 601, Position: 1}}
+			providerMock.On("GetQueuedBuilds").Return([]ci.QueuedBuild{}, nil)
+			dbMock.On("FindBuildTypeById", "bt1").Return(&bt1, nil)
+			dbMock.On("UpdateBuildTypeBuilds", "bt1", mock.Anything).Return(&bt1, nil)
+
+			result := tc.GetQueuedBuilds(&c, []ci.QueuedBuild{{Id: 601, BuildTypeId: "bt1", BranchName: "master", Position: 1}})
+
+			Convey("It should clear its queue", func() {
+				dbMock.AssertExpectations(t)
+				So(len(result), ShouldEqual, 0)
+
+				branches := dbMock.Calls[1].Arguments.Get(1).([]db.Branch)
+				So(len(branches[0].Queued), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When the queue can not be read", func() {
+			providerMock.On("GetQueuedBuilds").Return(nil, errors.New("503 Service Unavailable"))
+			last := []ci.QueuedBuild{{Id: 601, BuildTypeId: "bt1"}}
+
+			result := tc.GetQueuedBuilds(&c, last)
+
+			Convey("It should keep the last queue", func() {
+				So(result, ShouldResemble, last)
+				dbMock.AssertNotCalled(t, "FindBuildTypeById", mock.Anything)
+			})
+		})
+
+		Convey("When the provider has no build queue", func() {
+			c.Provider = new(IProviderMock)
+
+			result := tc.GetQueuedBuilds(&c, []ci.QueuedBuild{})
+
+			Convey("It should do nothing", func() {
+				So(len(result), ShouldEqual, 0)
+				dbMock.AssertNotCalled(t, "FindBuildTypeById", mock.Anything)
+			})
+		})
+	})
+}
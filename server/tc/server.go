@@ -3,22 +3,15 @@ package tc
 import (
 	"build-monitor-v2/server/cfg"
 
+	"sync/atomic"
 	"time"
 
+	"build-monitor-v2/server/ci"
 	"build-monitor-v2/server/db"
 
-	"github.com/pstuart2/go-teamcity"
 	"github.com/sirupsen/logrus"
 )
 
-type ITcClient interface {
-	GetProjects() ([]teamcity.Project, error)
-	GetBuildTypes() ([]teamcity.BuildType, error)
-	GetBuildsForBuildType(id string, count int) ([]teamcity.Build, error)
-	GetRunningBuilds() ([]teamcity.Build, error)
-	GetBuildByID(id int) (teamcity.Build, error)
-}
-
 type IDb interface {
 	UpsertProject(r db.Project) (*db.Project, error)
 	ProjectList() ([]db.Project, error)
@@ -26,49 +19,120 @@ type IDb interface {
 
 	UpsertBuildType(r db.BuildType) (*db.BuildType, error)
 	UpdateBuildTypeBuilds(buildTypeId string, branches []db.Branch) (*db.BuildType, error)
+	UpdateBuildTypeHistory(buildTypeId string, branches []db.Branch, lastBuildId int) (*db.BuildType, error)
+	UpdateBuildTypeInvestigation(buildTypeId string, investigation *db.Investigation, mutedTests []db.MutedTest) error
 	BuildTypeList() ([]db.BuildType, error)
 	DeleteBuildType(id string) error
 	DashboardList() ([]db.Dashboard, error)
 	FindBuildTypeById(id string) (*db.BuildType, error)
+
+	UpsertAgent(r db.Agent) (*db.Agent, error)
+	AgentList() ([]db.Agent, error)
+	DeleteAgent(id string) error
 }
 
 type Server struct {
-	Tc                         ITcClient
+	// Source names the provider this monitor owns in the database, it is blank for the default TeamCity server
+	Source                     string
+	Provider                   ci.Provider
 	Db                         IDb
 	Log                        *logrus.Entry
 	TcPollInterval             time.Duration
 	TcRunningBuildPollInterval time.Duration
-	commands                   chan string
-	stopped                    chan bool
+	// WebhookSecret enables pushed build events for providers that can parse them
+	WebhookSecret string
+	// SyncConcurrency is how many build types are synced at once, one when it is not set
+	SyncConcurrency int
+	// Breaker backs the monitor off while its server can not be reached, it is always closed when not set
+	Breaker *Breaker
+	// DegradedStart keeps the monitor starting when its server can not be reached, the api serves the stored builds until it can
+	DegradedStart bool
+	// HistoryDepth is how many builds are kept per branch, dashboards can ask for more. It is 12 when not set.
+	HistoryDepth int
+	// BranchRetention drops the branches whose last build is older than it, branches are kept forever when it is not set
+	BranchRetention time.Duration
+	commands        chan command
+	webhooks        chan ci.Build
+	stopped         chan bool
+	cache           *buildCache
+	historyDepths   map[string]int
+	// deepened are the build types whose history depth grew, their whole history is read on their next sync
+	deepened map[string]bool
+
+	// lastSync holds the *ci.SyncSummary of the last history sync, the api reads it while the monitor writes it
+	lastSync atomic.Value
 }
 
-func NewServer(log *logrus.Entry, c *cfg.Config, appDb IDb) Server {
+// NewTeamCityServer creates the monitor for one of the TcServers, the server name is its source
+func NewTeamCityServer(log *logrus.Entry, s cfg.TeamCityServer, appDb IDb) Server {
+	pollInterval, runningBuildPollInterval := s.PollInterval, s.RunningBuildPollInterval
+	if s.WebhookSecret != "" && s.WebhookPollInterval != "" {
+		// Webhooks push the running builds so polling only has to catch the events that went missing
+		pollInterval, runningBuildPollInterval = s.WebhookPollInterval, s.WebhookPollInterval
+	}
+
 	return Server{
-		Tc:                         teamcity.NewClient(c.TcUrl, teamcity.GuestAuth()),
+		Source:                     s.Name,
+		Provider:                   NewTeamCity(s),
 		Db:                         appDb,
 		Log:                        log,
-		TcPollInterval:             getIntervalDuration(log, "TcPollInterval", c.TcPollInterval),
-		TcRunningBuildPollInterval: getIntervalDuration(log, "TcBuildPollInterval", c.TcRunningBuildPollInterval),
+		TcPollInterval:             getIntervalDuration(log, "TcPollInterval", pollInterval),
+		TcRunningBuildPollInterval: getIntervalDuration(log, "TcBuildPollInterval", runningBuildPollInterval),
+		WebhookSecret:              s.WebhookSecret,
+		SyncConcurrency:            s.SyncConcurrency,
+		cache:                      newBuildCache(),
+	}
+}
+
+// NewProviderServer creates a monitor for any other ci.Provider, the source must be unique across all monitors
+func NewProviderServer(log *logrus.Entry, source string, p ci.Provider, appDb IDb, pollInterval, runningBuildPollInterval string) Server {
+	return Server{
+		Source:                     source,
+		Provider:                   p,
+		Db:                         appDb,
+		Log:                        log,
+		TcPollInterval:             getIntervalDuration(log, source+" PollInterval", pollInterval),
+		TcRunningBuildPollInterval: getIntervalDuration(log, source+" RunningBuildPollInterval", runningBuildPollInterval),
+		cache:                      newBuildCache(),
 	}
 }
 
 func (c *Server) Start() error {
+	if a, ok := c.Provider.(ci.Authenticator); ok {
+		if err := a.CheckAuth(); err != nil {
+			// Rejected credentials will not fix themselves, only an unreachable server can start degraded
+			if _, rejected := err.(*ci.AuthError); rejected || !c.DegradedStart {
+				c.Log.Errorf("Failed to authenticate: %v", err)
+				return err
+			}
+		}
+	}
+
 	// Refresh projects on start to ensure we are able to connect and read from the server
-	if err := refresh(c); err != nil {
-		return err
+	refreshErr := refresh(c, false)
+	if refreshErr != nil {
+		if !c.DegradedStart {
+			return refreshErr
+		}
+
+		c.Log.Warnf("Starting degraded, serving the stored builds until the server can be reached, Error: %v", refreshErr)
 	}
 
-	c.commands = make(chan string)
+	c.commands = make(chan command)
+	c.webhooks = make(chan ci.Build, 64)
 	c.stopped = make(chan bool)
 
 	// Now start our monitor
-	go monitor(c)
+	go monitor(c, refreshErr != nil)
 
 	return nil
 }
 
 func (c *Server) Shutdown() {
-	c.commands <- "shutdown"
+	if _, err := c.send(shutdownCommand, ""); err != nil {
+		c.Log.Warn("The monitor is not running")
+		return
+	}
 
 	select {
 	case <-c.stopped:
@@ -79,8 +143,37 @@ func (c *Server) Shutdown() {
 	}
 }
 
-func (c *Server) Refresh() {
-	c.commands <- "refresh"
+// Refresh reads the projects, build types and new builds of every dashboard and waits until it is done
+func (c *Server) Refresh() error {
+	_, err := c.send(refreshCommand, "")
+	return err
+}
+
+// Resync is a refresh that reads the whole build history again instead of only the new builds
+func (c *Server) Resync() error {
+	_, err := c.send(resyncCommand, "")
+	return err
+}
+
+// RefreshDashboard only syncs the new builds of the build types on one dashboard
+func (c *Server) RefreshDashboard(dashboardId string) (ci.SyncSummary, error) {
+	return c.send(syncDashboardCommand, dashboardId)
+}
+
+// RefreshBuildType only syncs the new builds of one build type
+func (c *Server) RefreshBuildType(buildTypeId string) (ci.SyncSummary, error) {
+	return c.send(syncBuildTypeCommand, buildTypeId)
+}
+
+// Status reports the last build history sync and the circuit breaker
+func (c *Server) Status() ci.MonitorStatus {
+	lastSync, _ := c.lastSync.Load().(*ci.SyncSummary)
+
+	return ci.MonitorStatus{Source: c.Source, LastSync: lastSync, Breaker: c.Breaker.Status()}
+}
+
+func (c *Server) setLastSync(summary ci.SyncSummary) {
+	c.lastSync.Store(&summary)
 }
 
 func getIntervalDuration(log *logrus.Entry, name, interval string) time.Duration {
@@ -92,46 +185,111 @@ func getIntervalDuration(log *logrus.Entry, name, interval string) time.Duration
 	return d
 }
 
-func monitor(c *Server) {
-	c.Log.Info("Starting Teamcity monitor")
+// monitor polls the running builds, pendingRefresh is set when the server could not be refreshed on start
+// so the first poll it is allowed to make refreshes everything instead
+func monitor(c *Server, pendingRefresh bool) {
+	c.Log.Info("Starting monitor")
 	shouldStop := false
 
 	currentPollInterval := c.TcPollInterval
-	runningBuilds := []teamcity.Build{}
+	runningBuilds := []ci.Build{}
+	queuedBuilds := []ci.QueuedBuild{}
+	var investigationsSynced, agentsSynced time.Time
+
+	// The poll timer is kept across webhook events so a busy server can not hold off the reconciliation
+	poll := time.After(currentPollInterval)
 
 	for shouldStop == false {
 		select {
-		case command := <-c.commands:
-			switch command {
-			case "shutdown":
+		case cmd := <-c.commands:
+			if cmd.kind == shutdownCommand {
 				c.Log.Info("Stopping")
 				shouldStop = true
+				cmd.result <- commandResult{}
 				break
-			case "refresh":
-				refresh(c)
 			}
 
-		case <-time.After(currentPollInterval):
-			runningBuilds = GetRunningBuilds(c, runningBuilds)
-			if len(runningBuilds) == 0 {
+			summary, err := runCommand(c, cmd)
+			if err == nil && (cmd.kind == refreshCommand || cmd.kind == resyncCommand) {
+				pendingRefresh = false
+			}
+
+			cmd.result <- commandResult{summary: summary, err: err}
+
+		case b := <-c.webhooks:
+			runningBuilds = ProcessWebhookBuild(c, b, runningBuilds)
+
+		case <-poll:
+			// An open circuit is left alone until its backoff is over
+			if c.Breaker.Allow() {
+				if pendingRefresh {
+					pendingRefresh = refresh(c, false) != nil
+				} else {
+					runningBuilds = GetRunningBuilds(c, runningBuilds)
+					queuedBuilds = GetQueuedBuilds(c, queuedBuilds)
+
+					if time.Since(investigationsSynced) >= investigationSyncInterval {
+						if err := SyncInvestigations(c); err != nil {
+							c.Log.Errorf("Failed to sync investigations, Error: %v", err)
+						}
+
+						investigationsSynced = time.Now()
+					}
+
+					if time.Since(agentsSynced) >= agentSyncInterval {
+						if err := SyncAgents(c); err != nil {
+							c.Log.Errorf("Failed to sync agents, Error: %v", err)
+						}
+
+						agentsSynced = time.Now()
+					}
+				}
+			}
+
+			// Queued builds are about to start so they are watched as closely as running ones
+			if len(runningBuilds) == 0 && len(queuedBuilds) == 0 {
 				currentPollInterval = c.TcPollInterval
 			} else {
 				currentPollInterval = c.TcRunningBuildPollInterval
 			}
+
+			poll = time.After(c.Breaker.Delay(currentPollInterval))
 		}
 	}
 
-	c.stopped <- true
+	close(c.stopped)
 }
 
-func refresh(c *Server) error {
+func refresh(c *Server, fullHistory bool) error {
 	if err := RefreshProjects(c); err != nil {
+		c.failed(err)
 		return err
 	}
 
 	if err := RefreshBuildTypes(c); err != nil {
+		c.failed(err)
 		return err
 	}
 
+	c.succeeded()
+
+	if fullHistory {
+		return GetFullBuildHistory(c)
+	}
+
 	return GetBuildHistory(c)
 }
+
+// failed counts a call the server did not answer against the breaker
+func (c *Server) failed(err error) {
+	if c.Breaker.Failure(err) {
+		status := c.Breaker.Status()
+		c.Log.Warnf("Opened the circuit after %d failures, backing off until %v", status.Failures, *status.RetryAt)
+	}
+}
+
+func (c *Server) succeeded() {
+	if c.Breaker.Success() {
+		c.Log.Info("Closed the circuit, the server can be reached again")
+	}
+}
@@ -2,6 +2,7 @@ package tc_test
 
 import (
 	"errors"
+	"sync/atomic"
 	"testing"
 
 	"build-monitor-v2/server/cfg"
@@ -10,7 +11,7 @@ import (
 
 	"time"
 
-	teamcity "github.com/pstuart2/go-teamcity"
+	"build-monitor-v2/server/ci"
 	"github.com/sirupsen/logrus"
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -28,7 +29,10 @@ func TestNewServer(t *testing.T) {
 			dbMock := new(IDbMock)
 
 			Convey("It should return a new s object", func() {
-				server := tc.NewServer(log, &conf, dbMock)
+				servers, err := conf.TeamCityServers()
+				So(err, ShouldBeNil)
+
+				server := tc.NewTeamCityServer(log, servers[0], dbMock)
 
 				So(server, ShouldNotBeNil)
 				So(server.Log, ShouldEqual, log)
@@ -46,20 +50,62 @@ func TestNewServer(t *testing.T) {
 			dbMock := new(IDbMock)
 
 			Convey("It should return a new s object", func() {
-				So(func() { tc.NewServer(log, &conf, dbMock) }, ShouldPanic)
+				servers, err := conf.TeamCityServers()
+				So(err, ShouldBeNil)
+
+				So(func() { tc.NewTeamCityServer(log, servers[0], dbMock) }, ShouldPanic)
 			})
 		})
 	})
 
 }
 
+func TestNewTeamCityServer(t *testing.T) {
+	Convey("Given a named TeamCity server", t, func() {
+		log := logrus.WithField("test", "TestNewTeamCityServer")
+		dbMock := new(IDbMock)
+
+		s := cfg.TeamCityServer{Name: "cloud", Url: "https://acme.teamcity.com", PollInterval: "1m", RunningBuildPollInterval: "10s"}
+
+		Convey("It should return a monitor that owns that server's source", func() {
+			server := tc.NewTeamCityServer(log, s, dbMock)
+
+			So(server.Source, ShouldEqual, "cloud")
+			So(server.Provider.(*tc.TeamCity).Source, ShouldEqual, "cloud")
+			So(server.TcPollInterval, ShouldEqual, time.Minute)
+			So(server.TcRunningBuildPollInterval, ShouldEqual, time.Second*10)
+		})
+	})
+}
+
+func TestNewProviderServer(t *testing.T) {
+	Convey("Given a logger and a provider", t, func() {
+		log := logrus.WithField("test", "TestNewProviderServer")
+		providerMock := new(IProviderMock)
+		dbMock := new(IDbMock)
+
+		Convey("It should return a monitor for that source", func() {
+			server := tc.NewProviderServer(log, "jenkins", providerMock, dbMock, "30s", "2s")
+
+			So(server.Source, ShouldEqual, "jenkins")
+			So(server.Provider, ShouldEqual, providerMock)
+			So(server.TcPollInterval, ShouldEqual, time.Second*30)
+			So(server.TcRunningBuildPollInterval, ShouldEqual, time.Second*2)
+		})
+
+		Convey("It should panic on a bad interval", func() {
+			So(func() { tc.NewProviderServer(log, "jenkins", providerMock, dbMock, "x", "2s") }, ShouldPanic)
+		})
+	})
+}
+
 func TestServer_Start_Shutdown(t *testing.T) {
 	Convey("Given a logger and tcServer", t, func() {
 		log := logrus.WithField("test", "TestServer_Start_Shutdown")
-		serverMock := new(ITcClientMock)
+		providerMock := new(IProviderMock)
 
 		c := tc.Server{
-			Tc:                         serverMock,
+			Provider:                   providerMock,
 			Log:                        log,
 			TcPollInterval:             time.Millisecond * 500,
 			TcRunningBuildPollInterval: time.Millisecond * 500,
@@ -92,10 +138,10 @@ func TestServer_Start_Shutdown(t *testing.T) {
 
 			Convey("And there are no running builds", func() {
 				oldGetRunningBuilds := tc.GetRunningBuilds
-				getRunningBuildsCallCount := 0
-				tc.GetRunningBuilds = func(tcs *tc.Server, lastBuilds []teamcity.Build) []teamcity.Build {
-					getRunningBuildsCallCount++
-					return []teamcity.Build{}
+				var getRunningBuildsCallCount int32
+				tc.GetRunningBuilds = func(tcs *tc.Server, lastBuilds []ci.Build) []ci.Build {
+					atomic.AddInt32(&getRunningBuildsCallCount, 1)
+					return []ci.Build{}
 				}
 				defer func() { tc.GetRunningBuilds = oldGetRunningBuilds }()
 
@@ -106,7 +152,7 @@ func TestServer_Start_Shutdown(t *testing.T) {
 					So(refreshProjectsCallCount, ShouldEqual, 1)
 					So(refreshBuildTypesCallCount, ShouldEqual, 1)
 					So(refreshBuildHistoryCallCount, ShouldEqual, 1)
-					So(getRunningBuildsCallCount, ShouldEqual, 0)
+					So(atomic.LoadInt32(&getRunningBuildsCallCount), ShouldEqual, 0)
 
 					Convey("And call GetRunningBuilds after the timeout", func() {
 						<-time.After(time.Millisecond * 750)
@@ -114,7 +160,7 @@ func TestServer_Start_Shutdown(t *testing.T) {
 						So(refreshProjectsCallCount, ShouldEqual, 1)
 						So(refreshBuildTypesCallCount, ShouldEqual, 1)
 						So(refreshBuildHistoryCallCount, ShouldEqual, 1)
-						So(getRunningBuildsCallCount, ShouldEqual, 1)
+						So(atomic.LoadInt32(&getRunningBuildsCallCount), ShouldEqual, 1)
 
 						c.Shutdown()
 					})
@@ -215,5 +261,180 @@ func TestServer_Start_Shutdown(t *testing.T) {
 				So(refreshBuildHistoryCallCount, ShouldEqual, 1)
 			})
 		})
+
+		Convey("When we fail to start the monitor because the credentials are rejected", func() {
+			expectedError := &ci.AuthError{Provider: "TeamCity", Url: "http://tc.local", Status: "401 Unauthorized"}
+
+			authMock := new(IAuthProviderMock)
+			authMock.On("CheckAuth").Return(expectedError)
+			c.Provider = authMock
+
+			oldRefreshProjects := tc.RefreshProjects
+			refreshProjectsCallCount := 0
+			tc.RefreshProjects = func(tcs *tc.Server) error {
+				refreshProjectsCallCount++
+				return nil
+			}
+			defer func() { tc.RefreshProjects = oldRefreshProjects }()
+
+			Convey("It should return the auth error before refreshing anything", func() {
+
+				err := c.Start()
+				So(err, ShouldEqual, expectedError)
+				So(refreshProjectsCallCount, ShouldEqual, 0)
+				authMock.AssertExpectations(t)
+			})
+
+			Convey("It should not start degraded either", func() {
+				c.DegradedStart = true
+
+				err := c.Start()
+				So(err, ShouldEqual, expectedError)
+			})
+		})
+
+		Convey("When the server can not be reached and we may start degraded", func() {
+			expectedError := errors.New("dial tcp: connection refused")
+
+			c.DegradedStart = true
+			c.Breaker = tc.NewBreaker(3, time.Millisecond*100, time.Second)
+
+			authMock := new(IAuthProviderMock)
+			authMock.On("CheckAuth").Return(expectedError)
+			c.Provider = authMock
+
+			oldRefreshProjects := tc.RefreshProjects
+			// The monitor goroutine retries the refresh while the test reads the count
+			var refreshProjectsCallCount int32
+			tc.RefreshProjects = func(tcs *tc.Server) error {
+				atomic.AddInt32(&refreshProjectsCallCount, 1)
+				return expectedError
+			}
+			defer func() { tc.RefreshProjects = oldRefreshProjects }()
+
+			Convey("It should start anyway and report the failure", func() {
+
+				err := c.Start()
+				So(err, ShouldBeNil)
+				So(atomic.LoadInt32(&refreshProjectsCallCount), ShouldEqual, 1)
+
+				status := c.Status()
+				So(status.Breaker.State, ShouldEqual, ci.BreakerClosed)
+				So(status.Breaker.Failures, ShouldEqual, 1)
+				So(status.Breaker.LastError, ShouldEqual, expectedError.Error())
+
+				Convey("And retry the refresh instead of polling the running builds", func() {
+					<-time.After(time.Millisecond * 750)
+
+					So(atomic.LoadInt32(&refreshProjectsCallCount), ShouldEqual, 2)
+					So(c.Status().Breaker.Failures, ShouldEqual, 2)
+
+					c.Shutdown()
+				})
+			})
+		})
+	})
+}
+
+func TestServer_Commands(t *testing.T) {
+	Convey("Given a monitor", t, func() {
+		log := logrus.WithField("test", "TestServer_Commands")
+
+		c := tc.Server{
+			Provider:                   new(IProviderMock),
+			Log:                        log,
+			TcPollInterval:             time.Hour,
+			TcRunningBuildPollInterval: time.Hour,
+		}
+
+		oldRefreshProjects := tc.RefreshProjects
+		tc.RefreshProjects = func(tcs *tc.Server) error { return nil }
+		defer func() { tc.RefreshProjects = oldRefreshProjects }()
+
+		oldRefreshBuildTypes := tc.RefreshBuildTypes
+		tc.RefreshBuildTypes = func(tcs *tc.Server) error { return nil }
+		defer func() { tc.RefreshBuildTypes = oldRefreshBuildTypes }()
+
+		getBuildHistoryCallCount := 0
+		oldGetBuildHistory := tc.GetBuildHistory
+		tc.GetBuildHistory = func(tcs *tc.Server) error {
+			getBuildHistoryCallCount++
+			return nil
+		}
+		defer func() { tc.GetBuildHistory = oldGetBuildHistory }()
+
+		oldGetDashboardHistory := tc.GetDashboardHistory
+		tc.GetDashboardHistory = func(tcs *tc.Server, dashboardId string) (ci.SyncSummary, error) {
+			if dashboardId != "d1" {
+				return ci.SyncSummary{}, ci.ErrDashboardNotFound
+			}
+
+			return ci.SyncSummary{BuildTypes: 2, Synced: 2, Errors: []ci.SyncError{}}, nil
+		}
+		defer func() { tc.GetDashboardHistory = oldGetDashboardHistory }()
+
+		oldGetBuildTypeHistory := tc.GetBuildTypeHistory
+		tc.GetBuildTypeHistory = func(tcs *tc.Server, buildTypeId string) (ci.SyncSummary, error) {
+			return ci.SyncSummary{BuildTypes: 1, Synced: 1, Errors: []ci.SyncError{}}, nil
+		}
+		defer func() { tc.GetBuildTypeHistory = oldGetBuildTypeHistory }()
+
+		Convey("When it was not started", func() {
+			_, err := c.RefreshDashboard("d1")
+
+			Convey("It should say it is not running", func() {
+				So(err, ShouldEqual, ci.ErrNotRunning)
+				So(c.Refresh(), ShouldEqual, ci.ErrNotRunning)
+			})
+		})
+
+		Convey("When it is running", func() {
+			So(c.Start(), ShouldBeNil)
+			getBuildHistoryCallCount = 0
+
+			Convey("It should only sync the dashboard that was asked for and report the result", func() {
+				summary, err := c.RefreshDashboard("d1")
+				So(err, ShouldBeNil)
+				So(summary.Synced, ShouldEqual, 2)
+
+				_, err = c.RefreshDashboard("gone")
+				So(err, ShouldEqual, ci.ErrDashboardNotFound)
+
+				summary, err = c.RefreshBuildType("bt1")
+				So(err, ShouldBeNil)
+				So(summary.Synced, ShouldEqual, 1)
+
+				So(getBuildHistoryCallCount, ShouldEqual, 0)
+				c.Shutdown()
+			})
+
+			Convey("It should wait for a full refresh to finish", func() {
+				So(c.Refresh(), ShouldBeNil)
+				So(getBuildHistoryCallCount, ShouldEqual, 1)
+				c.Shutdown()
+			})
+
+			Convey("It should say it is not running once it is shutdown", func() {
+				c.Shutdown()
+
+				_, err := c.RefreshBuildType("bt1")
+				So(err, ShouldEqual, ci.ErrNotRunning)
+			})
+		})
+
+		Convey("When the circuit is open", func() {
+			So(c.Start(), ShouldBeNil)
+			c.Breaker = tc.NewBreaker(1, time.Hour, time.Hour)
+			c.Breaker.Failure(errors.New("dial tcp: connection refused"))
+
+			_, err := c.RefreshDashboard("d1")
+			refreshErr := c.Refresh()
+			c.Shutdown()
+
+			Convey("It should skip the command and say why", func() {
+				So(err, ShouldEqual, ci.ErrCircuitOpen)
+				So(refreshErr, ShouldEqual, ci.ErrCircuitOpen)
+			})
+		})
 	})
 }
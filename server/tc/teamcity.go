@@ -0,0 +1,310 @@
+package tc
+
+import (
+	"strings"
+
+	"build-monitor-v2/server/cfg"
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+
+	"github.com/pstuart2/go-teamcity"
+)
+
+type ITcClient interface {
+	GetProjects() ([]teamcity.Project, error)
+	GetBuildTypes() ([]teamcity.BuildType, error)
+	GetBuildsForBuildType(id string, count int) ([]ci.Build, error)
+	GetBuildsForBuildTypeSince(id string, sinceBuildId int, count int) ([]ci.Build, error)
+	GetRunningBuilds() ([]ci.Build, error)
+	GetBuildByID(id int) (ci.Build, error)
+	GetBuildQueue() ([]ci.QueuedBuild, error)
+	GetChangesForBuild(id int) ([]ci.Change, error)
+	GetTestCounts(id int) (ci.TestCounts, error)
+	GetBuildDetails(id int) (ci.BuildDetails, error)
+	GetInvestigations() ([]ci.Investigation, error)
+	GetMutedTests() ([]ci.MutedTest, error)
+	GetAgents() ([]ci.Agent, error)
+	GetPausedBuildTypeIds() ([]string, error)
+	GetSnapshotDependencies() (map[string][]string, error)
+	GetBuildChain(id int) (ci.BuildChain, error)
+}
+
+// TeamCity is the ci.Provider for a TeamCity server. When Source is set every project and build type
+// id is prefixed with it so several servers can share the collections.
+type TeamCity struct {
+	Tc     ITcClient
+	Source string
+}
+
+func NewTeamCity(s cfg.TeamCityServer) *TeamCity {
+	return &TeamCity{
+		Tc:     NewClient(s),
+		Source: s.Name,
+	}
+}
+
+// CheckAuth lets the monitor fail fast when the server rejects our credentials
+func (t *TeamCity) CheckAuth() error {
+	if a, ok := t.Tc.(ci.Authenticator); ok {
+		return a.CheckAuth()
+	}
+
+	return nil
+}
+
+func (t *TeamCity) GetProjects() ([]db.Project, error) {
+	projects, err := t.Tc.GetProjects()
+	if err != nil {
+		return nil, err
+	}
+
+	dbProjects := []db.Project{}
+	for _, project := range projects {
+		if project.ID != "_Root" {
+			dbProjects = append(dbProjects, t.projectToDb(project))
+		}
+	}
+
+	return dbProjects, nil
+}
+
+func (t *TeamCity) GetBuildTypes() ([]db.BuildType, error) {
+	buildTypes, err := t.Tc.GetBuildTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	// go-teamcity has no paused flag so it is read on its own
+	pausedIds, pausedErr := t.Tc.GetPausedBuildTypeIds()
+	if pausedErr != nil {
+		return nil, pausedErr
+	}
+
+	paused := make(map[string]bool)
+	for _, id := range pausedIds {
+		paused[id] = true
+	}
+
+	dependencies, depErr := t.Tc.GetSnapshotDependencies()
+	if depErr != nil {
+		return nil, depErr
+	}
+
+	dbBuildTypes := []db.BuildType{}
+	for _, buildType := range buildTypes {
+		dbBuildType := t.buildTypeToDb(buildType)
+		dbBuildType.Paused = paused[buildType.ID]
+
+		for _, id := range dependencies[buildType.ID] {
+			dbBuildType.Dependencies = append(dbBuildType.Dependencies, t.id(id))
+		}
+
+		dbBuildTypes = append(dbBuildTypes, dbBuildType)
+	}
+
+	return dbBuildTypes, nil
+}
+
+func (t *TeamCity) GetBuildsForBuildType(id string, count int) ([]ci.Build, error) {
+	builds, err := t.Tc.GetBuildsForBuildType(t.nativeId(id), count)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.buildsToCi(builds), nil
+}
+
+// GetBuildsSince uses the sinceBuild locator so an hourly sync only reads what is new
+func (t *TeamCity) GetBuildsSince(id string, sinceBuildId int, count int) ([]ci.Build, error) {
+	builds, err := t.Tc.GetBuildsForBuildTypeSince(t.nativeId(id), sinceBuildId, count)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.buildsToCi(builds), nil
+}
+
+func (t *TeamCity) GetRunningBuilds() ([]ci.Build, error) {
+	builds, err := t.Tc.GetRunningBuilds()
+	if err != nil {
+		return nil, err
+	}
+
+	return t.buildsToCi(builds), nil
+}
+
+// GetQueuedBuilds reads the build queue, the build type ids are namespaced like the builds
+func (t *TeamCity) GetQueuedBuilds() ([]ci.QueuedBuild, error) {
+	queued, err := t.Tc.GetBuildQueue()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range queued {
+		queued[i].BuildTypeId = t.id(queued[i].BuildTypeId)
+	}
+
+	return queued, nil
+}
+
+func (t *TeamCity) GetChanges(b ci.Build) ([]ci.Change, error) {
+	return t.Tc.GetChangesForBuild(b.Id)
+}
+
+func (t *TeamCity) GetTestCounts(b ci.Build) (ci.TestCounts, error) {
+	return t.Tc.GetTestCounts(b.Id)
+}
+
+// GetBuildDetails only returns a build of the build type it was asked for
+func (t *TeamCity) GetBuildDetails(b ci.Build) (ci.BuildDetails, error) {
+	details, err := t.Tc.GetBuildDetails(b.Id)
+	if err != nil {
+		return ci.BuildDetails{}, err
+	}
+
+	details.BuildTypeId = t.id(details.BuildTypeId)
+	if details.BuildTypeId != b.BuildTypeId {
+		return ci.BuildDetails{}, ci.ErrBuildNotFound
+	}
+
+	return details, nil
+}
+
+func (t *TeamCity) GetInvestigations() ([]ci.Investigation, error) {
+	investigations, err := t.Tc.GetInvestigations()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range investigations {
+		investigations[i].BuildTypeId = t.id(investigations[i].BuildTypeId)
+	}
+
+	return investigations, nil
+}
+
+func (t *TeamCity) GetMutedTests() ([]ci.MutedTest, error) {
+	muted, err := t.Tc.GetMutedTests()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range muted {
+		muted[i].BuildTypeId = t.id(muted[i].BuildTypeId)
+	}
+
+	return muted, nil
+}
+
+// GetAgents namespaces the agent and pool ids, agents of different servers share a collection
+func (t *TeamCity) GetAgents() ([]ci.Agent, error) {
+	agents, err := t.Tc.GetAgents()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range agents {
+		agents[i].Id = t.id(agents[i].Id)
+		agents[i].PoolId = t.id(agents[i].PoolId)
+		agents[i].BuildTypeId = t.id(agents[i].BuildTypeId)
+	}
+
+	return agents, nil
+}
+
+// GetBuildChain only returns the chain of a build of the build type it was asked for
+func (t *TeamCity) GetBuildChain(b ci.Build) (ci.BuildChain, error) {
+	chain, err := t.Tc.GetBuildChain(b.Id)
+	if err != nil {
+		return ci.BuildChain{}, err
+	}
+
+	chain.Build.BuildTypeId = t.id(chain.Build.BuildTypeId)
+	if chain.Build.BuildTypeId != b.BuildTypeId {
+		return ci.BuildChain{}, ci.ErrBuildNotFound
+	}
+
+	for i := range chain.Upstream {
+		chain.Upstream[i].BuildTypeId = t.id(chain.Upstream[i].BuildTypeId)
+	}
+
+	for i := range chain.Downstream {
+		chain.Downstream[i].BuildTypeId = t.id(chain.Downstream[i].BuildTypeId)
+	}
+
+	return chain, nil
+}
+
+func (t *TeamCity) GetBuild(b ci.Build) (ci.Build, error) {
+	build, err := t.Tc.GetBuildByID(b.Id)
+	if err != nil {
+		return ci.Build{}, err
+	}
+
+	return t.buildToCi(build), nil
+}
+
+func ProjectToDb(p teamcity.Project) db.Project {
+	return db.Project{
+		Id:              p.ID,
+		Name:            p.Name,
+		Description:     p.Description,
+		ParentProjectID: p.ParentProjectID,
+	}
+}
+
+func BuildTypeToDb(p teamcity.BuildType) db.BuildType {
+	return db.BuildType{
+		Id:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		ProjectID:   p.ProjectID,
+	}
+}
+
+func (t *TeamCity) projectToDb(p teamcity.Project) db.Project {
+	project := ProjectToDb(p)
+	project.Id = t.id(project.Id)
+	project.ParentProjectID = t.id(project.ParentProjectID)
+
+	return project
+}
+
+func (t *TeamCity) buildTypeToDb(bt teamcity.BuildType) db.BuildType {
+	buildType := BuildTypeToDb(bt)
+	buildType.Id = t.id(buildType.Id)
+	buildType.ProjectID = t.id(buildType.ProjectID)
+
+	return buildType
+}
+
+func (t *TeamCity) buildToCi(b ci.Build) ci.Build {
+	b.BuildTypeId = t.id(b.BuildTypeId)
+
+	return b
+}
+
+func (t *TeamCity) buildsToCi(builds []ci.Build) []ci.Build {
+	ciBuilds := []ci.Build{}
+	for _, b := range builds {
+		ciBuilds = append(ciBuilds, t.buildToCi(b))
+	}
+
+	return ciBuilds
+}
+
+func (t *TeamCity) id(nativeId string) string {
+	if t.Source == "" || nativeId == "" {
+		return nativeId
+	}
+
+	return t.Source + ":" + nativeId
+}
+
+func (t *TeamCity) nativeId(id string) string {
+	if t.Source == "" {
+		return id
+	}
+
+	return strings.TrimPrefix(id, t.Source+":")
+}
@@ -0,0 +1,382 @@
+package tc_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+	"build-monitor-v2/server/tc"
+
+	"github.com/pstuart2/go-teamcity"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTeamCity_GetProjects(t *testing.T) {
+	Convey("Given a TeamCity provider", t, func() {
+		tcMock := new(ITcClientMock)
+		p := tc.TeamCity{Tc: tcMock}
+
+		Convey("When TeamCity returns projects", func() {
+			projects := []teamcity.Project{
+				{ID: "_Root", Name: "Root Project", Description: "I am _Root"},
+				{ID: "p1", Name: "1 Project", Description: "Something here _", ParentProjectID: "_Root"},
+				{ID: "p2", Name: "2 Project", Description: "Something here 1", ParentProjectID: "p1"},
+			}
+
+			tcMock.On("GetProjects").Return(projects, nil)
+
+			result, err := p.GetProjects()
+
+			Convey("It should map every project that is not _Root", func() {
+				tcMock.AssertExpectations(t)
+
+				So(err, ShouldBeNil)
+				So(len(result), ShouldEqual, 2)
+				So(result[0], ShouldResemble, db.Project{Id: "p1", Name: "1 Project", Description: "Something here _", ParentProjectID: "_Root"})
+				So(result[1], ShouldResemble, db.Project{Id: "p2", Name: "2 Project", Description: "Something here 1", ParentProjectID: "p1"})
+			})
+		})
+
+		Convey("When TeamCity fails", func() {
+			expectedErr := errors.New("this was expected")
+			tcMock.On("GetProjects").Return(nil, expectedErr)
+
+			_, err := p.GetProjects()
+
+			Convey("It should return the error", func() {
+				So(err, ShouldEqual, expectedErr)
+			})
+		})
+	})
+}
+
+func TestTeamCity_GetBuildTypes(t *testing.T) {
+	Convey("Given a TeamCity provider", t, func() {
+		tcMock := new(ITcClientMock)
+		p := tc.TeamCity{Tc: tcMock}
+
+		Convey("When TeamCity returns build types", func() {
+			buildTypes := []teamcity.BuildType{
+				{ID: "bt1", Name: "1 BuildType", Description: "Something here _", ProjectID: "p1"},
+				{ID: "bt2", Name: "2 BuildType", Description: "Something here 1", ProjectID: "p3"},
+			}
+
+			tcMock.On("GetBuildTypes").Return(buildTypes, nil)
+			tcMock.On("GetPausedBuildTypeIds").Return([]string{"bt1"}, nil)
+			tcMock.On("GetSnapshotDependencies").Return(map[string][]string{"bt2": {"bt1"}}, nil)
+
+			result, err := p.GetBuildTypes()
+
+			Convey("It should map them to the db model", func() {
+				So(err, ShouldBeNil)
+				So(len(result), ShouldEqual, 2)
+				So(result[1], ShouldResemble, db.BuildType{Id: "bt2", Name: "2 BuildType", Description: "Something here 1", ProjectID: "p3", Dependencies: []string{"bt1"}})
+			})
+
+			Convey("It should mark the paused ones", func() {
+				So(result[0].Paused, ShouldBeTrue)
+				So(result[1].Paused, ShouldBeFalse)
+			})
+		})
+
+		Convey("When the paused build types can not be read", func() {
+			expectedErr := errors.New("this was expected")
+			tcMock.On("GetBuildTypes").Return([]teamcity.BuildType{{ID: "bt1"}}, nil)
+			tcMock.On("GetPausedBuildTypeIds").Return(nil, expectedErr)
+
+			_, err := p.GetBuildTypes()
+
+			Convey("It should return the error", func() {
+				So(err, ShouldEqual, expectedErr)
+			})
+		})
+
+		Convey("When the snapshot dependencies can not be read", func() {
+			expectedErr := errors.New("this was expected")
+			tcMock.On("GetBuildTypes").Return([]teamcity.BuildType{{ID: "bt1"}}, nil)
+			tcMock.On("GetPausedBuildTypeIds").Return([]string{}, nil)
+			tcMock.On("GetSnapshotDependencies").Return(nil, expectedErr)
+
+			_, err := p.GetBuildTypes()
+
+			Convey("It should return the error", func() {
+				So(err, ShouldEqual, expectedErr)
+			})
+		})
+
+		Convey("When TeamCity fails", func() {
+			expectedErr := errors.New("this was expected")
+			tcMock.On("GetBuildTypes").Return(nil, expectedErr)
+
+			_, err := p.GetBuildTypes()
+
+			Convey("It should return the error", func() {
+				So(err, ShouldEqual, expectedErr)
+			})
+		})
+	})
+}
+
+func TestTeamCity_Builds(t *testing.T) {
+	Convey("Given a TeamCity provider", t, func() {
+		tcMock := new(ITcClientMock)
+		p := tc.TeamCity{Tc: tcMock}
+
+		tcBuild := ci.Build{
+			Id:          801,
+			BuildTypeId: "bt-id-801",
+			BranchName:  "this is a b-name",
+			Number:      "tc-build-number",
+			Status:      db.StatusRunning,
+			StatusText:  "this will show up some place",
+			Progress:    22,
+			StartDate:   time.Unix(1507141495, 0),
+			FinishDate:  time.Unix(1507141496, 0),
+			Kind:        db.KindPersonal,
+		}
+
+		expected := ci.Build{
+			Id:          801,
+			BuildTypeId: "bt-id-801",
+			BranchName:  "this is a b-name",
+			Number:      "tc-build-number",
+			Status:      db.StatusRunning,
+			StatusText:  "this will show up some place",
+			Progress:    22,
+			StartDate:   time.Unix(1507141495, 0),
+			FinishDate:  time.Unix(1507141496, 0),
+			Kind:        db.KindPersonal,
+		}
+
+		Convey("When GetBuildsForBuildType is called", func() {
+			tcMock.On("GetBuildsForBuildType", "bt-id-801", 50).Return([]ci.Build{tcBuild}, nil)
+
+			result, err := p.GetBuildsForBuildType("bt-id-801", 50)
+
+			Convey("It should map the builds", func() {
+				So(err, ShouldBeNil)
+				So(result, ShouldResemble, []ci.Build{expected})
+			})
+		})
+
+		Convey("When GetRunningBuilds is called", func() {
+			tcMock.On("GetRunningBuilds").Return([]ci.Build{tcBuild}, nil)
+
+			result, err := p.GetRunningBuilds()
+
+			Convey("It should map the builds", func() {
+				So(err, ShouldBeNil)
+				So(result, ShouldResemble, []ci.Build{expected})
+			})
+		})
+
+		Convey("When GetBuild is called", func() {
+			tcMock.On("GetBuildByID", 801).Return(tcBuild, nil)
+
+			result, err := p.GetBuild(ci.Build{Id: 801, BuildTypeId: "bt-id-801"})
+
+			Convey("It should look the build up by id", func() {
+				tcMock.AssertExpectations(t)
+
+				So(err, ShouldBeNil)
+				So(result, ShouldResemble, expected)
+			})
+		})
+
+		Convey("When GetBuild fails", func() {
+			expectedErr := errors.New("api failed")
+			tcMock.On("GetBuildByID", 801).Return(ci.Build{}, expectedErr)
+
+			_, err := p.GetBuild(ci.Build{Id: 801})
+
+			Convey("It should return the error", func() {
+				So(err, ShouldEqual, expectedErr)
+			})
+		})
+	})
+}
+
+func TestTeamCity_Namespaced(t *testing.T) {
+	Convey("Given a named TeamCity server", t, func() {
+		tcMock := new(ITcClientMock)
+		p := tc.TeamCity{Tc: tcMock, Source: "cloud"}
+
+		Convey("When projects and build types are loaded", func() {
+			tcMock.On("GetProjects").Return([]teamcity.Project{{ID: "MyProject", Name: "My Project", ParentProjectID: "_Root"}}, nil)
+			tcMock.On("GetBuildTypes").Return([]teamcity.BuildType{{ID: "MyProject_Build", Name: "Build", ProjectID: "MyProject"}}, nil)
+			tcMock.On("GetPausedBuildTypeIds").Return([]string{"MyProject_Build"}, nil)
+			tcMock.On("GetSnapshotDependencies").Return(map[string][]string{"MyProject_Build": {"MyProject_Compile"}}, nil)
+
+			projects, pErr := p.GetProjects()
+			buildTypes, btErr := p.GetBuildTypes()
+
+			Convey("It should prefix their ids with the server name", func() {
+				So(pErr, ShouldBeNil)
+				So(btErr, ShouldBeNil)
+				So(projects, ShouldResemble, []db.Project{{Id: "cloud:MyProject", Name: "My Project", ParentProjectID: "cloud:_Root"}})
+				So(buildTypes, ShouldResemble, []db.BuildType{{Id: "cloud:MyProject_Build", Name: "Build", ProjectID: "cloud:MyProject", Paused: true, Dependencies: []string{"cloud:MyProject_Compile"}}})
+			})
+		})
+
+		Convey("When builds are loaded for a build type", func() {
+			tcMock.On("GetBuildsForBuildType", "MyProject_Build", 10).Return([]ci.Build{{Id: 7, BuildTypeId: "MyProject_Build"}}, nil)
+			tcMock.On("GetRunningBuilds").Return([]ci.Build{{Id: 8, BuildTypeId: "MyProject_Build"}}, nil)
+
+			builds, err := p.GetBuildsForBuildType("cloud:MyProject_Build", 10)
+			running, runningErr := p.GetRunningBuilds()
+
+			Convey("It should ask TeamCity for its own id and namespace the builds", func() {
+				tcMock.AssertExpectations(t)
+
+				So(err, ShouldBeNil)
+				So(runningErr, ShouldBeNil)
+				So(builds[0].BuildTypeId, ShouldEqual, "cloud:MyProject_Build")
+				So(running[0].BuildTypeId, ShouldEqual, "cloud:MyProject_Build")
+			})
+		})
+
+		Convey("When the build queue is loaded", func() {
+			tcMock.On("GetBuildQueue").Return([]ci.QueuedBuild{{Id: 9, BuildTypeId: "MyProject_Build", Position: 1}}, nil)
+
+			queued, err := p.GetQueuedBuilds()
+
+			Convey("It should namespace the build types", func() {
+				So(err, ShouldBeNil)
+				So(queued[0].BuildTypeId, ShouldEqual, "cloud:MyProject_Build")
+			})
+		})
+
+		Convey("When the changes of a build are loaded", func() {
+			tcMock.On("GetChangesForBuild", 7).Return([]ci.Change{{Revision: "abc", Author: "jdoe"}}, nil)
+
+			changes, err := p.GetChanges(ci.Build{Id: 7, BuildTypeId: "cloud:MyProject_Build"})
+
+			Convey("It should ask TeamCity by build id", func() {
+				tcMock.AssertExpectations(t)
+
+				So(err, ShouldBeNil)
+				So(changes[0].Author, ShouldEqual, "jdoe")
+			})
+		})
+
+		Convey("When the tests of a build are counted", func() {
+			tcMock.On("GetTestCounts", 7).Return(ci.TestCounts{Total: 10, Failed: 3, NewFailed: 3}, nil)
+
+			counts, err := p.GetTestCounts(ci.Build{Id: 7, BuildTypeId: "cloud:MyProject_Build"})
+
+			Convey("It should ask TeamCity by build id", func() {
+				tcMock.AssertExpectations(t)
+
+				So(err, ShouldBeNil)
+				So(counts.NewFailed, ShouldEqual, 3)
+			})
+		})
+
+		Convey("When the details of a build are loaded", func() {
+			tcMock.On("GetBuildDetails", 7).Return(ci.BuildDetails{Id: 7, BuildTypeId: "MyProject_Build", StatusText: "Tests failed: 1"}, nil)
+
+			details, err := p.GetBuildDetails(ci.Build{Id: 7, BuildTypeId: "cloud:MyProject_Build"})
+			_, otherErr := p.GetBuildDetails(ci.Build{Id: 7, BuildTypeId: "cloud:Other_Build"})
+
+			Convey("It should namespace the build type", func() {
+				So(err, ShouldBeNil)
+				So(details.BuildTypeId, ShouldEqual, "cloud:MyProject_Build")
+			})
+
+			Convey("It should not return a build of another build type", func() {
+				So(otherErr, ShouldEqual, ci.ErrBuildNotFound)
+			})
+		})
+
+		Convey("When the chain of a build is loaded", func() {
+			tcMock.On("GetBuildChain", 7).Return(ci.BuildChain{
+				Build:      ci.ChainBuild{Id: 7, BuildTypeId: "MyProject_Build"},
+				Upstream:   []ci.ChainBuild{{Id: 6, BuildTypeId: "MyProject_Compile"}},
+				Downstream: []ci.ChainBuild{{Id: 8, BuildTypeId: "MyProject_Deploy"}},
+			}, nil)
+
+			chain, err := p.GetBuildChain(ci.Build{Id: 7, BuildTypeId: "cloud:MyProject_Build"})
+			_, otherErr := p.GetBuildChain(ci.Build{Id: 7, BuildTypeId: "cloud:Other_Build"})
+
+			Convey("It should namespace the build types of the whole chain", func() {
+				So(err, ShouldBeNil)
+				So(chain.Build.BuildTypeId, ShouldEqual, "cloud:MyProject_Build")
+				So(chain.Upstream[0].BuildTypeId, ShouldEqual, "cloud:MyProject_Compile")
+				So(chain.Downstream[0].BuildTypeId, ShouldEqual, "cloud:MyProject_Deploy")
+			})
+
+			Convey("It should not return the chain of a build of another build type", func() {
+				So(otherErr, ShouldEqual, ci.ErrBuildNotFound)
+			})
+		})
+
+		Convey("When investigations and muted tests are loaded", func() {
+			tcMock.On("GetInvestigations").Return([]ci.Investigation{{BuildTypeId: "MyProject_Build", Assignee: "Alice"}}, nil)
+			tcMock.On("GetMutedTests").Return([]ci.MutedTest{{BuildTypeId: "MyProject_Build", Name: "ClockTest"}}, nil)
+
+			investigations, invErr := p.GetInvestigations()
+			muted, mutedErr := p.GetMutedTests()
+
+			Convey("It should namespace their build types", func() {
+				So(invErr, ShouldBeNil)
+				So(mutedErr, ShouldBeNil)
+				So(investigations[0].BuildTypeId, ShouldEqual, "cloud:MyProject_Build")
+				So(muted[0].BuildTypeId, ShouldEqual, "cloud:MyProject_Build")
+			})
+		})
+
+		Convey("When the agents are loaded", func() {
+			tcMock.On("GetAgents").Return([]ci.Agent{
+				{Id: "1", PoolId: "0", BuildId: 512, BuildTypeId: "MyProject_Build"},
+				{Id: "2", PoolId: "0"},
+			}, nil)
+
+			agents, err := p.GetAgents()
+
+			Convey("It should namespace the agent, pool and build type ids", func() {
+				So(err, ShouldBeNil)
+				So(agents[0].Id, ShouldEqual, "cloud:1")
+				So(agents[0].PoolId, ShouldEqual, "cloud:0")
+				So(agents[0].BuildTypeId, ShouldEqual, "cloud:MyProject_Build")
+				So(agents[1].BuildTypeId, ShouldEqual, "")
+			})
+		})
+
+		Convey("When the builds since another are loaded", func() {
+			tcMock.On("GetBuildsForBuildTypeSince", "MyProject_Build", 6, 1000).Return([]ci.Build{{Id: 7, BuildTypeId: "MyProject_Build"}}, nil)
+
+			builds, err := p.GetBuildsSince("cloud:MyProject_Build", 6, 1000)
+
+			Convey("It should ask TeamCity for its own id and namespace the builds", func() {
+				tcMock.AssertExpectations(t)
+
+				So(err, ShouldBeNil)
+				So(builds[0].BuildTypeId, ShouldEqual, "cloud:MyProject_Build")
+			})
+		})
+	})
+}
+
+func TestStatusToDb(t *testing.T) {
+	Convey("Given TeamCity build statuses", t, func() {
+		Convey("It should map them to the db statuses", func() {
+			So(tc.StatusToDb("finished", "SUCCESS"), ShouldEqual, db.StatusSuccess)
+			So(tc.StatusToDb("running", "SUCCESS"), ShouldEqual, db.StatusRunning)
+			So(tc.StatusToDb("finished", "FAILURE"), ShouldEqual, db.StatusFailure)
+			So(tc.StatusToDb("finished", "ERROR"), ShouldEqual, db.StatusFailure)
+			So(tc.StatusToDb("finished", "UNKNOWN"), ShouldEqual, db.StatusUnknown)
+		})
+	})
+}
+
+func TestTeamCity_CheckAuth(t *testing.T) {
+	Convey("Given a TeamCity client that cannot check its credentials", t, func() {
+		p := tc.TeamCity{Tc: new(ITcClientMock)}
+
+		Convey("It should assume they are fine", func() {
+			So(p.CheckAuth(), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,52 @@
+package tc
+
+import (
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+)
+
+// addTestCounts fills in the test counts of the newest finished build, the one a tile shows. The counts of
+// a running build are still changing and older builds keep the counts they got when they were the newest.
+func addTestCounts(c *Server, buildTypeId string, builds []db.Build) {
+	p, ok := c.Provider.(ci.TestReporter)
+	if !ok {
+		return
+	}
+
+	for i, b := range builds {
+		if b.Status == db.StatusRunning {
+			continue
+		}
+
+		if b.Tests != nil {
+			return
+		}
+
+		if tests, ok := c.cache.getTests(b.Id); ok {
+			builds[i].Tests = &tests
+			return
+		}
+
+		counts, err := p.GetTestCounts(ci.Build{Id: b.Id, BuildTypeId: buildTypeId})
+		if err != nil {
+			c.Log.Errorf("Failed to get the test counts for build: %d, Error: %v", b.Id, err)
+			return
+		}
+
+		tests := TestCountsToDb(counts)
+		builds[i].Tests = &tests
+		c.cache.putTests(b.Id, tests)
+		return
+	}
+}
+
+func TestCountsToDb(t ci.TestCounts) db.TestCounts {
+	return db.TestCounts{
+		Total:     t.Total,
+		Passed:    t.Passed,
+		Failed:    t.Failed,
+		NewFailed: t.NewFailed,
+		Ignored:   t.Ignored,
+		Muted:     t.Muted,
+	}
+}
@@ -0,0 +1,87 @@
+package tc_test
+
+import (
+	"errors"
+	"testing"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+	"build-monitor-v2/server/tc"
+
+	"github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestServer_TestCounts(t *testing.T) {
+	Convey("Given a monitor for a provider that counts the tests of a build", t, func() {
+		log := logrus.WithField("test", "TestServer_TestCounts")
+		dbMock := new(IDbMock)
+		providerMock := new(ITestProviderMock)
+
+		c := tc.NewProviderServer(log, "jenkins", providerMock, dbMock, "20s", "5s")
+
+		newBuildType := func() *db.BuildType {
+			return &db.BuildType{
+				Id: "bt1",
+				Branches: []db.Branch{{Name: "master", Builds: []db.Build{
+					{Id: 12, Status: db.StatusRunning},
+					{Id: 11, Status: db.StatusSuccess, Tests: &db.TestCounts{Total: 140, Passed: 140}},
+				}}},
+			}
+		}
+
+		counts := ci.TestCounts{Total: 142, Passed: 139, Failed: 3, NewFailed: 3}
+		dbMock.On("UpdateBuildTypeBuilds", "bt1", mock.Anything).Return(nil, nil)
+
+		Convey("When a build finishes", func() {
+			providerMock.On("GetTestCounts", ci.Build{Id: 12, BuildTypeId: "bt1"}).Return(counts, nil)
+
+			err := tc.ProcessRunningBuild(&c, ci.Build{Id: 12, BuildTypeId: "bt1", BranchName: "master", Status: db.StatusFailure}, newBuildType())
+
+			Convey("It should store its test counts and leave the older builds alone", func() {
+				So(err, ShouldBeNil)
+				providerMock.AssertNumberOfCalls(t, "GetTestCounts", 1)
+
+				builds := dbMock.Calls[0].Arguments.Get(1).([]db.Branch)[0].Builds
+				So(*builds[0].Tests, ShouldResemble, db.TestCounts{Total: 142, Passed: 139, Failed: 3, NewFailed: 3})
+				So(builds[1].Tests.Total, ShouldEqual, 140)
+			})
+
+			Convey("It should not count them again", func() {
+				tc.ProcessRunningBuild(&c, ci.Build{Id: 12, BuildTypeId: "bt1", BranchName: "master", Status: db.StatusFailure}, newBuildType())
+
+				providerMock.AssertNumberOfCalls(t, "GetTestCounts", 1)
+
+				builds := dbMock.Calls[1].Arguments.Get(1).([]db.Branch)[0].Builds
+				So(builds[0].Tests.NewFailed, ShouldEqual, 3)
+			})
+		})
+
+		Convey("When a build is still running", func() {
+			err := tc.ProcessRunningBuild(&c, ci.Build{Id: 12, BuildTypeId: "bt1", BranchName: "master", Status: db.StatusRunning}, newBuildType())
+
+			Convey("It should not count its tests yet", func() {
+				So(err, ShouldBeNil)
+				providerMock.AssertNotCalled(t, "GetTestCounts", mock.Anything)
+
+				builds := dbMock.Calls[0].Arguments.Get(1).([]db.Branch)[0].Builds
+				So(builds[0].Tests, ShouldBeNil)
+			})
+		})
+
+		Convey("When the tests can not be counted", func() {
+			providerMock.On("GetTestCounts", mock.Anything).Return(ci.TestCounts{}, errors.New("502 Bad Gateway"))
+
+			err := tc.ProcessRunningBuild(&c, ci.Build{Id: 12, BuildTypeId: "bt1", BranchName: "master", Status: db.StatusFailure}, newBuildType())
+
+			Convey("It should still store the build without counts", func() {
+				So(err, ShouldBeNil)
+
+				builds := dbMock.Calls[0].Arguments.Get(1).([]db.Branch)[0].Builds
+				So(builds[0].Id, ShouldEqual, 12)
+				So(builds[0].Tests, ShouldBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,234 @@
+package tc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+)
+
+// The tcWebHooks plugin sends these dates without a layout we can ask for, so try the ones it is known to use
+var webhookDateLayouts = []string{"2006-01-02T15:04:05.000-0700", tcDateLayout, time.RFC3339}
+
+// webhookPayload is the build part of the tcWebHooks JSON payload format
+type webhookPayload struct {
+	Build struct {
+		NotifyType          string `json:"notifyType"`
+		BuildResult         string `json:"buildResult"`
+		BuildStatus         string `json:"buildStatus"`
+		BuildId             string `json:"buildId"`
+		BuildTypeId         string `json:"buildTypeId"`
+		BuildExternalTypeId string `json:"buildExternalTypeId"`
+		BuildNumber         string `json:"buildNumber"`
+		BranchName          string `json:"branchName"`
+		BuildStartTime      string `json:"buildStartTime"`
+		CurrentTime         string `json:"currentTime"`
+		BuildIsPersonal     bool   `json:"buildIsPersonal"`
+	} `json:"build"`
+}
+
+// ParseWebhook maps a tcWebHooks build event onto a build, every other notification is ignored
+func (t *TeamCity) ParseWebhook(payload []byte) (ci.Build, bool, error) {
+	var p webhookPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return ci.Build{}, false, fmt.Errorf("the webhook payload is not valid json: %v", err)
+	}
+
+	var running bool
+	switch p.Build.NotifyType {
+	case "buildStarted", "changesLoaded", "statusChanged", "beforeBuildFinish":
+		running = true
+	case "buildFinished", "buildSuccessful", "buildFailed", "buildBroken", "buildFixed", "buildInterrupted":
+		running = false
+	default:
+		return ci.Build{}, false, nil
+	}
+
+	id, err := strconv.Atoi(p.Build.BuildId)
+	if err != nil {
+		return ci.Build{}, false, fmt.Errorf("the webhook payload has no build id")
+	}
+
+	buildTypeId := p.Build.BuildExternalTypeId
+	if buildTypeId == "" {
+		buildTypeId = p.Build.BuildTypeId
+	}
+
+	b := ci.Build{
+		Id:          id,
+		BuildTypeId: t.id(buildTypeId),
+		BranchName:  p.Build.BranchName,
+		Number:      p.Build.BuildNumber,
+		Status:      db.StatusRunning,
+		StatusText:  p.Build.BuildStatus,
+		StartDate:   parseWebhookDate(p.Build.BuildStartTime),
+	}
+
+	if !running {
+		b.Status = webhookResultToDb(p.Build.NotifyType, p.Build.BuildResult)
+		b.FinishDate = parseWebhookDate(p.Build.CurrentTime)
+
+		if p.Build.NotifyType == "buildInterrupted" {
+			b.Kind = db.KindCanceled
+		}
+	}
+
+	if p.Build.BuildIsPersonal {
+		b.Kind = db.KindPersonal
+	}
+
+	return b, true, nil
+}
+
+// webhookResultToDb leaves interrupted builds unknown like the REST api does for canceled builds
+func webhookResultToDb(notifyType, result string) db.BuildStatus {
+	if notifyType == "buildInterrupted" {
+		return db.StatusUnknown
+	}
+
+	switch strings.ToLower(result) {
+	case "success":
+		return db.StatusSuccess
+	case "failure", "error":
+		return db.StatusFailure
+	}
+
+	return db.StatusUnknown
+}
+
+func parseWebhookDate(s string) time.Time {
+	for _, layout := range webhookDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// Webhook checks a pushed payload was signed with the shared secret, or sent with it as a bearer token,
+// and hands the build it describes to the monitor
+func (c *Server) Webhook(payload []byte, signature string) error {
+	parser, ok := c.Provider.(ci.WebhookParser)
+	if !ok || c.WebhookSecret == "" {
+		return ci.ErrWebhooksDisabled
+	}
+
+	if !ValidSignature(c.WebhookSecret, payload, signature) && !ValidToken(c.WebhookSecret, signature) {
+		return ci.ErrBadSignature
+	}
+
+	b, ok, err := parser.ParseWebhook(payload)
+	if err != nil || !ok {
+		return err
+	}
+
+	if c.webhooks == nil {
+		return ci.ErrNotRunning
+	}
+
+	select {
+	case <-c.stopped:
+		return ci.ErrNotRunning
+	default:
+	}
+
+	// A webhook never waits on the monitor, a build that does not fit is picked up by the next poll
+	select {
+	case c.webhooks <- b:
+		return nil
+	default:
+		return ci.ErrWebhooksBusy
+	}
+}
+
+// ValidSignature checks a "sha256=<hex hmac of the payload>" signature made with the shared secret
+func ValidSignature(secret string, payload []byte, signature string) bool {
+	sent, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+
+	return hmac.Equal(sent, mac.Sum(nil))
+}
+
+// ValidToken checks a "Bearer <secret>" authorization, tcWebHooks can send one but can not sign the payload
+func ValidToken(secret string, authorization string) bool {
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	if token == authorization {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+// ProcessWebhookBuild merges a pushed build like a polled one and keeps the running list
+// the monitor reconciles against in step with it
+var ProcessWebhookBuild = func(c *Server, b ci.Build, runningBuilds []ci.Build) []ci.Build {
+	bt, btErr := c.Db.FindBuildTypeById(b.BuildTypeId)
+	if btErr != nil {
+		c.Log.Errorf("Failed to get build type for: %s, Error: %v", b.BuildTypeId, btErr)
+		return runningBuilds
+	}
+
+	if len(bt.DashboardIds) == 0 {
+		return runningBuilds
+	}
+
+	b = keepStoredDetails(b, bt)
+	if err := ProcessRunningBuild(c, b, bt); err != nil {
+		c.Log.Errorf("Failed to update builds for buildType: %s, Error: %v", bt.Id, err)
+	}
+
+	stillRunning := []ci.Build{}
+	for _, rb := range runningBuilds {
+		if !isBuildInList(rb, []ci.Build{b}) {
+			stillRunning = append(stillRunning, rb)
+		}
+	}
+
+	if b.Status == db.StatusRunning {
+		stillRunning = append(stillRunning, b)
+	}
+
+	return stillRunning
+}
+
+// keepStoredDetails fills in the start date, progress and estimate the webhook payload does not carry
+func keepStoredDetails(b ci.Build, bt *db.BuildType) ci.Build {
+	index := indexOfBranch(b.BranchName, bt.Branches)
+	if index == -1 {
+		return b
+	}
+
+	for _, stored := range bt.Branches[index].Builds {
+		if stored.Id != b.Id {
+			continue
+		}
+
+		if b.StartDate.IsZero() {
+			b.StartDate = stored.StartDate
+		}
+
+		if b.Status == db.StatusRunning && b.Progress == 0 {
+			b.Progress = stored.Progress
+		}
+
+		if b.Status == db.StatusRunning && b.EstimatedFinishDate.IsZero() {
+			b.EstimatedFinishDate = stored.EstimatedFinishDate
+		}
+	}
+
+	return b
+}
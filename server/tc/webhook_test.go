@@ -0,0 +1,317 @@
+package tc_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"build-monitor-v2/server/ci"
+	"build-monitor-v2/server/db"
+	"build-monitor-v2/server/tc"
+
+	"github.com/sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+)
+
+func sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestTeamCity_ParseWebhook(t *testing.T) {
+	Convey("Given a named TeamCity provider", t, func() {
+		p := tc.TeamCity{Tc: new(ITcClientMock), Source: "cloud"}
+
+		Convey("When a build started event arrives", func() {
+			payload, _ := ioutil.ReadFile("testdata/webhook-started.json")
+			b, ok, err := p.ParseWebhook(payload)
+
+			Convey("It should return the running build under the namespaced build type", func() {
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeTrue)
+				So(b.Id, ShouldEqual, 512)
+				So(b.BuildTypeId, ShouldEqual, "cloud:BuildMonitor_Server")
+				So(b.BranchName, ShouldEqual, "feature/auth")
+				So(b.Number, ShouldEqual, "88")
+				So(b.Status, ShouldEqual, db.StatusRunning)
+				So(b.StatusText, ShouldEqual, "Running")
+				So(b.StartDate.Equal(time.Date(2017, 10, 4, 18, 25, 0, 0, time.UTC)), ShouldBeTrue)
+				So(b.FinishDate.IsZero(), ShouldBeTrue)
+			})
+		})
+
+		Convey("When a build failed event arrives", func() {
+			payload, _ := ioutil.ReadFile("testdata/webhook-failed.json")
+			b, ok, err := p.ParseWebhook(payload)
+
+			Convey("It should return the finished build", func() {
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeTrue)
+				So(b.Status, ShouldEqual, db.StatusFailure)
+				So(b.StatusText, ShouldEqual, "Tests failed: 2 (1 new), passed: 140")
+				So(b.FinishDate.Equal(time.Date(2017, 10, 4, 18, 29, 30, 0, time.UTC)), ShouldBeTrue)
+			})
+		})
+
+		Convey("When a build is interrupted", func() {
+			b, ok, err := p.ParseWebhook([]byte(`{"build": {"notifyType": "buildInterrupted", "buildResult": "failure", "buildId": "513", "buildTypeId": "BuildMonitor_Server"}}`))
+
+			Convey("It should finish it as unknown like a canceled build", func() {
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeTrue)
+				So(b.Status, ShouldEqual, db.StatusUnknown)
+				So(b.Kind, ShouldEqual, db.KindCanceled)
+			})
+		})
+
+		Convey("When a personal build finishes", func() {
+			b, ok, err := p.ParseWebhook([]byte(`{"build": {"notifyType": "buildFailed", "buildResult": "failure", "buildId": "514", "buildTypeId": "BuildMonitor_Server", "buildIsPersonal": true}}`))
+
+			Convey("It should mark it as personal", func() {
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeTrue)
+				So(b.Status, ShouldEqual, db.StatusFailure)
+				So(b.Kind, ShouldEqual, db.KindPersonal)
+			})
+		})
+
+		Convey("When an event that is not about a build arrives", func() {
+			_, ok, err := p.ParseWebhook([]byte(`{"build": {"notifyType": "responsibilityChanged", "buildTypeId": "BuildMonitor_Server"}}`))
+
+			Convey("It should be ignored", func() {
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("When the payload is not valid", func() {
+			_, _, jsonErr := p.ParseWebhook([]byte(`<build/>`))
+			_, _, idErr := p.ParseWebhook([]byte(`{"build": {"notifyType": "buildStarted"}}`))
+
+			Convey("It should return an error", func() {
+				So(jsonErr, ShouldNotBeNil)
+				So(idErr, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestValidSignature(t *testing.T) {
+	Convey("Given a payload", t, func() {
+		payload := []byte(`{"build": {}}`)
+
+		Convey("It should only accept a signature made with the same secret", func() {
+			So(tc.ValidSignature("s3cret", payload, sign("s3cret", payload)), ShouldBeTrue)
+			So(tc.ValidSignature("s3cret", payload, sign("other", payload)), ShouldBeFalse)
+			So(tc.ValidSignature("s3cret", payload, "sha256=not-hex"), ShouldBeFalse)
+			So(tc.ValidSignature("s3cret", payload, ""), ShouldBeFalse)
+		})
+	})
+}
+
+func TestValidToken(t *testing.T) {
+	Convey("Given a bearer authorization", t, func() {
+		Convey("It should only accept the secret itself", func() {
+			So(tc.ValidToken("s3cret", "Bearer s3cret"), ShouldBeTrue)
+			So(tc.ValidToken("s3cret", "Bearer other"), ShouldBeFalse)
+			So(tc.ValidToken("s3cret", "s3cret"), ShouldBeFalse)
+			So(tc.ValidToken("s3cret", "Bearer "), ShouldBeFalse)
+			So(tc.ValidToken("s3cret", ""), ShouldBeFalse)
+		})
+	})
+}
+
+func TestServer_Webhook(t *testing.T) {
+	Convey("Given a TeamCity monitor with a webhook secret", t, func() {
+		log := logrus.WithField("test", "TestServer_Webhook")
+		payload, _ := ioutil.ReadFile("testdata/webhook-started.json")
+
+		c := tc.Server{
+			Provider:                   &tc.TeamCity{Tc: new(ITcClientMock)},
+			Log:                        log,
+			TcPollInterval:             time.Hour,
+			TcRunningBuildPollInterval: time.Hour,
+			WebhookSecret:              "s3cret",
+		}
+
+		Convey("When the secret is not set", func() {
+			c.WebhookSecret = ""
+
+			Convey("It should refuse the payload", func() {
+				So(c.Webhook(payload, sign("", payload)), ShouldEqual, ci.ErrWebhooksDisabled)
+			})
+		})
+
+		Convey("When the provider can not parse webhooks", func() {
+			c.Provider = new(IProviderMock)
+
+			Convey("It should refuse the payload", func() {
+				So(c.Webhook(payload, sign("s3cret", payload)), ShouldEqual, ci.ErrWebhooksDisabled)
+			})
+		})
+
+		Convey("When the signature is wrong", func() {
+			Convey("It should refuse the payload", func() {
+				So(c.Webhook(payload, sign("guess", payload)), ShouldEqual, ci.ErrBadSignature)
+			})
+		})
+
+		Convey("When the bearer token is wrong", func() {
+			Convey("It should refuse the payload", func() {
+				So(c.Webhook(payload, "Bearer guess"), ShouldEqual, ci.ErrBadSignature)
+			})
+		})
+
+		Convey("When the monitor has not started", func() {
+			Convey("It should say so", func() {
+				So(c.Webhook(payload, sign("s3cret", payload)), ShouldEqual, ci.ErrNotRunning)
+				So(c.Webhook(payload, "Bearer s3cret"), ShouldEqual, ci.ErrNotRunning)
+			})
+		})
+
+		Convey("When the monitor is running", func() {
+			oldRefreshProjects := tc.RefreshProjects
+			tc.RefreshProjects = func(s *tc.Server) error { return nil }
+			defer func() { tc.RefreshProjects = oldRefreshProjects }()
+
+			oldRefreshBuildTypes := tc.RefreshBuildTypes
+			tc.RefreshBuildTypes = func(s *tc.Server) error { return nil }
+			defer func() { tc.RefreshBuildTypes = oldRefreshBuildTypes }()
+
+			oldGetBuildHistory := tc.GetBuildHistory
+			tc.GetBuildHistory = func(s *tc.Server) error { return nil }
+			defer func() { tc.GetBuildHistory = oldGetBuildHistory }()
+
+			processed := make(chan ci.Build, 1)
+			oldProcessWebhookBuild := tc.ProcessWebhookBuild
+			tc.ProcessWebhookBuild = func(s *tc.Server, b ci.Build, runningBuilds []ci.Build) []ci.Build {
+				processed <- b
+				return runningBuilds
+			}
+			defer func() { tc.ProcessWebhookBuild = oldProcessWebhookBuild }()
+
+			So(c.Start(), ShouldBeNil)
+			err := c.Webhook(payload, sign("s3cret", payload))
+
+			Convey("It should hand the build to the monitor", func() {
+				So(err, ShouldBeNil)
+
+				select {
+				case b := <-processed:
+					So(b.Id, ShouldEqual, 512)
+				case <-time.After(time.Second):
+					So("the build was not processed", ShouldBeEmpty)
+				}
+
+				c.Shutdown()
+			})
+
+			Convey("It should refuse the payload once the monitor is shutdown", func() {
+				<-processed
+				c.Shutdown()
+
+				So(c.Webhook(payload, sign("s3cret", payload)), ShouldEqual, ci.ErrNotRunning)
+			})
+
+			Convey("It should refuse the payload instead of waiting when the monitor can not keep up", func() {
+				// The monitor holds on to the second build until the test reads the first
+				busyErr := err
+				for i := 0; i < 100 && busyErr == nil; i++ {
+					busyErr = c.Webhook(payload, sign("s3cret", payload))
+				}
+
+				So(busyErr, ShouldEqual, ci.ErrWebhooksBusy)
+
+				go func() {
+					for range processed {
+					}
+				}()
+				c.Shutdown()
+				close(processed)
+			})
+		})
+	})
+}
+
+func TestProcessWebhookBuild(t *testing.T) {
+	Convey("Given a monitor and a build type on a dashboard", t, func() {
+		log := logrus.WithField("test", "TestProcessWebhookBuild")
+		dbMock := new(IDbMock)
+
+		c := tc.Server{Db: dbMock, Log: log}
+
+		start := time.Date(2017, 10, 4, 18, 25, 0, 0, time.UTC)
+		bt := db.BuildType{
+			Id:           "bt1",
+			DashboardIds: []string{"d1"},
+			Branches: []db.Branch{
+				{Name: "master", Builds: []db.Build{{Id: 512, Status: db.StatusRunning, Progress: 40, StartDate: start, EstimatedFinishDate: start.Add(5 * time.Minute)}}},
+			},
+		}
+
+		Convey("When a build we are already showing reports its status", func() {
+			dbMock.On("FindBuildTypeById", "bt1").Return(&bt, nil)
+			dbMock.On("UpdateBuildTypeBuilds", "bt1", mock.Anything).Return(&bt, nil)
+
+			b := ci.Build{Id: 512, BuildTypeId: "bt1", BranchName: "master", Status: db.StatusRunning, StatusText: "Running tests"}
+			running := tc.ProcessWebhookBuild(&c, b, []ci.Build{})
+
+			Convey("It should keep the start date, progress and estimate the payload does not carry", func() {
+				dbMock.AssertExpectations(t)
+
+				branches := dbMock.Calls[1].Arguments.Get(1).([]db.Branch)
+				So(branches[0].Builds[0].StatusText, ShouldEqual, "Running tests")
+				So(branches[0].Builds[0].StartDate, ShouldResemble, start)
+				So(branches[0].Builds[0].Progress, ShouldEqual, 40)
+				So(branches[0].Builds[0].EstimatedFinishDate, ShouldResemble, start.Add(5*time.Minute))
+			})
+
+			Convey("It should track it as running", func() {
+				So(len(running), ShouldEqual, 1)
+				So(running[0].Id, ShouldEqual, 512)
+			})
+		})
+
+		Convey("When a running build finishes", func() {
+			dbMock.On("FindBuildTypeById", "bt1").Return(&bt, nil)
+			dbMock.On("UpdateBuildTypeBuilds", "bt1", mock.Anything).Return(&bt, nil)
+
+			b := ci.Build{Id: 512, BuildTypeId: "bt1", BranchName: "master", Status: db.StatusSuccess, FinishDate: start.Add(time.Minute)}
+			other := ci.Build{Id: 600, BuildTypeId: "bt2", BranchName: "master", Status: db.StatusRunning}
+			running := tc.ProcessWebhookBuild(&c, b, []ci.Build{b, other})
+
+			Convey("It should stop tracking it", func() {
+				So(running, ShouldResemble, []ci.Build{other})
+			})
+		})
+
+		Convey("When no dashboard shows the build type", func() {
+			bt.DashboardIds = []string{}
+			dbMock.On("FindBuildTypeById", "bt1").Return(&bt, nil)
+
+			running := tc.ProcessWebhookBuild(&c, ci.Build{Id: 512, BuildTypeId: "bt1", Status: db.StatusRunning}, []ci.Build{})
+
+			Convey("It should ignore it", func() {
+				dbMock.AssertNotCalled(t, "UpdateBuildTypeBuilds", mock.Anything, mock.Anything)
+				So(len(running), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When the build type can not be found", func() {
+			dbMock.On("FindBuildTypeById", "bt9").Return(nil, errors.New("not found"))
+
+			running := tc.ProcessWebhookBuild(&c, ci.Build{Id: 1, BuildTypeId: "bt9"}, []ci.Build{})
+
+			Convey("It should leave the running builds alone", func() {
+				So(len(running), ShouldEqual, 0)
+			})
+		})
+	})
+}